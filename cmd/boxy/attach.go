@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/arnab2001/boxy/internal/attach"
+	"github.com/arnab2001/boxy/internal/client"
+	"github.com/arnab2001/boxy/internal/config"
+	"github.com/arnab2001/boxy/internal/exitcode"
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/cio"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "attach <name>",
+		Short: "Attach the terminal to a running container's console",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			keysFlag, _ := cmd.Flags().GetString("detach-keys")
+			keys, err := attach.ParseKeys(keysFlag)
+			if err != nil {
+				return err
+			}
+			noStdin, _ := cmd.Flags().GetBool("no-stdin")
+
+			ctx := client.Default()
+			c, err := client.Instance()
+			if err != nil {
+				return err
+			}
+			cont, err := loadContainer(ctx, c, name)
+			if err != nil {
+				return err
+			}
+			spec, err := cont.Spec(ctx)
+			if err != nil {
+				return err
+			}
+			tty := spec.Process != nil && spec.Process.Terminal
+			if tty && !noStdin && !isTerminal(os.Stdin) {
+				return fmt.Errorf("the input device is not a TTY (use --no-stdin)")
+			}
+
+			var (
+				in       io.Reader
+				stdin    *attach.StdinCloser
+				detached <-chan struct{}
+			)
+			if !noStdin {
+				if tty {
+					d := attach.NewDetachReader(os.Stdin, keys)
+					in, detached = d, d.Detached()
+				} else {
+					// end of input is passed on to the container, as in boxy start -a
+					stdin = attach.NewStdinCloser(os.Stdin)
+					in = stdin
+				}
+			}
+			// keep the monitor from draining the fifos while attached
+			release, err := attach.Hold(filepath.Join(config.RunDir(), cont.ID()))
+			if err != nil {
+				return err
+			}
+			defer release()
+			task, err := cont.Task(ctx, cio.NewAttach(cio.WithStreams(in, os.Stdout, os.Stderr)))
+			if errdefs.IsNotFound(err) {
+				return fmt.Errorf("%s is not running", name)
+			}
+			if err != nil {
+				return err
+			}
+			if task.IO().Config().Stdout == "" {
+				return fmt.Errorf("%s was started with -d and has no console to attach to", name)
+			}
+			st, err := task.Status(ctx)
+			if err != nil {
+				return err
+			}
+			if st.Status == containerd.Stopped {
+				return fmt.Errorf("%s is not running", name)
+			}
+			if stdin != nil {
+				stdin.SetClose(func() { _ = task.CloseIO(ctx, containerd.WithStdinCloser) })
+			}
+
+			exitCh, err := task.Wait(ctx)
+			if err != nil {
+				return err
+			}
+			sigCh := make(chan os.Signal, 1)
+			signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+			defer signal.Stop(sigCh)
+
+			cmd.SilenceUsage = true
+			code, exited, err := streamTask(ctx, task, tty, tty && !noStdin, exitCh, sigCh, detached)
+			if err != nil {
+				return err
+			}
+			if !exited {
+				fmt.Fprintf(os.Stderr, "\n⏏ detached from %s\n", name)
+				return nil
+			}
+			if code != 0 {
+				return &exitcode.Error{Code: int(code)}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().String("detach-keys", attach.DefaultDetachKeys, "key sequence that detaches and leaves the container running (empty disables)")
+	cmd.Flags().Bool("no-stdin", false, "do not attach stdin")
+	rootCmd.AddCommand(cmd)
+}
+
+// streamTask runs the terminal side of an attached task until it exits or
+// detach is closed (exited is false then): raw mode and resizes with a TTY,
+// signals forwarded into the container
+func streamTask(ctx context.Context, task containerd.Task, tty, rawStdin bool, exitCh <-chan containerd.ExitStatus, sigCh <-chan os.Signal, detach <-chan struct{}) (code uint32, exited bool, err error) {
+	// ── TTY: raw mode (with stdin) + resize forwarding ──────────
+	if cons := currentConsole(); tty && cons != nil {
+		if rawStdin {
+			if err := cons.SetRaw(); err != nil {
+				return 0, false, err
+			}
+			defer cons.Reset()
+		}
+
+		if sz, err := cons.Size(); err == nil { // initial resize
+			_ = task.Resize(ctx, uint32(sz.Width), uint32(sz.Height))
+		}
+		winsz := make(chan os.Signal, 1)
+		signal.Notify(winsz, syscall.SIGWINCH)
+		defer signal.Stop(winsz)
+		go func() { // later resizes
+			for range winsz {
+				if s, err := cons.Size(); err == nil {
+					_ = task.Resize(ctx, uint32(s.Width), uint32(s.Height))
+				}
+			}
+		}()
+	}
+
+	// forward Ctrl-C / TERM / HUP into the container, every time
+	go func() {
+		for s := range sigCh {
+			_ = task.Kill(ctx, s.(syscall.Signal))
+		}
+	}()
+
+	select {
+	case st := <-exitCh:
+		code, _, err := st.Result()
+		if err != nil {
+			return 0, true, err
+		}
+		// let the last output be copied before reporting
+		task.IO().Wait()
+		return code, true, nil
+	case <-detach:
+		// leave the fifos to the shim for the next attach
+		task.IO().Cancel()
+		return 0, false, nil
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/arnab2001/boxy/internal/build"
+	"github.com/arnab2001/boxy/internal/client"
+	"github.com/containerd/containerd"
+	refdocker "github.com/containerd/containerd/reference/docker"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "build -t <name[:tag]> [-f Dockerfile] <context>",
+		Short: "Build an image from a Dockerfile",
+		Args:  cobra.ExactArgs(1),
+		RunE:  buildE,
+	}
+	cmd.Flags().StringP("tag", "t", "", "image name and optional tag (required)")
+	cmd.Flags().StringP("file", "f", "", "path to the Dockerfile (default <context>/Dockerfile)")
+	cmd.Flags().StringArray("build-arg", nil, "build-time variable KEY=VALUE (KEY alone uses the host env)")
+	cmd.Flags().String("target", "", "build up to the named stage")
+	cmd.MarkFlagRequired("tag")
+	rootCmd.AddCommand(cmd)
+}
+
+func buildE(cmd *cobra.Command, args []string) error {
+	tag, _ := cmd.Flags().GetString("tag")
+	file, _ := cmd.Flags().GetString("file")
+	target, _ := cmd.Flags().GetString("target")
+	rawArgs, _ := cmd.Flags().GetStringArray("build-arg")
+
+	named, err := refdocker.ParseDockerRef(tag)
+	if err != nil {
+		return err
+	}
+	ref := named.String()
+
+	buildArgs := map[string]string{}
+	for _, a := range rawArgs {
+		k, v, ok := strings.Cut(a, "=")
+		if !ok {
+			if v, ok = os.LookupEnv(k); !ok {
+				continue
+			}
+		}
+		buildArgs[k] = v
+	}
+
+	if fi, err := os.Stat(args[0]); err != nil || !fi.IsDir() {
+		return fmt.Errorf("build context %s is not a directory", args[0])
+	}
+
+	ctx := client.Default()
+	c, err := client.Instance()
+	if err != nil {
+		return err
+	}
+
+	img, err := build.Build(ctx, c, build.Options{
+		ContextDir: args[0],
+		Dockerfile: build.ResolveDockerfile(args[0], file),
+		Tag:        ref,
+		BuildArgs:  buildArgs,
+		Target:     target,
+		GetImage: func(ctx context.Context, base string) (containerd.Image, error) {
+			named, err := refdocker.ParseDockerRef(base)
+			if err != nil {
+				return nil, err
+			}
+			return ensureImage(ctx, c, named.String())
+		},
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	})
+	if err != nil {
+		fmt.Printf("✖ build failed: %v\n", err)
+		return err
+	}
+	fmt.Printf("✔ built %s (%s)\n", ref, img.Target().Digest)
+	return nil
+}
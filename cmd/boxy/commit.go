@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/arnab2001/boxy/internal/build"
+	"github.com/arnab2001/boxy/internal/client"
+	"github.com/arnab2001/boxy/internal/image"
+	boxylabels "github.com/arnab2001/boxy/internal/labels"
+	"github.com/arnab2001/boxy/internal/snapshot"
+	"github.com/arnab2001/boxy/internal/userns"
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/errdefs"
+	refdocker "github.com/containerd/containerd/reference/docker"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "commit <name> <image[:tag]>",
+		Short: "Create a new image from a container's filesystem changes",
+		Args:  cobra.ExactArgs(2),
+		RunE:  commitE,
+	}
+	cmd.Flags().StringArrayP("change", "c", nil, "apply a Dockerfile instruction, e.g. 'CMD [\"app\"]' or CMD=app")
+	cmd.Flags().StringP("message", "m", "", "commit message recorded in the image history")
+	cmd.Flags().StringP("author", "a", "", "author recorded in the image config")
+	cmd.Flags().BoolP("pause", "p", true, "pause the container while committing")
+	rootCmd.AddCommand(cmd)
+}
+
+func commitE(cmd *cobra.Command, args []string) error {
+	changes, _ := cmd.Flags().GetStringArray("change")
+	message, _ := cmd.Flags().GetString("message")
+	author, _ := cmd.Flags().GetString("author")
+	pause, _ := cmd.Flags().GetBool("pause")
+
+	named, err := refdocker.ParseDockerRef(args[1])
+	if err != nil {
+		return err
+	}
+	ref := named.String()
+
+	ctx := client.Default()
+	c, err := client.Instance()
+	if err != nil {
+		return err
+	}
+
+	cont, err := loadContainer(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+	info, err := cont.Info(ctx)
+	if err != nil {
+		return err
+	}
+	if info.SnapshotKey == "" {
+		return fmt.Errorf("container %s has no snapshot to commit", args[0])
+	}
+
+	img, err := cont.Image(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load image of %s: %v", args[0], err)
+	}
+
+	ctx, done, err := c.WithLease(ctx)
+	if err != nil {
+		return err
+	}
+	defer done(ctx)
+
+	state, err := image.Load(ctx, img)
+	if err != nil {
+		return err
+	}
+	if err := build.ApplyChanges(state, changes); err != nil {
+		return err
+	}
+	if author != "" {
+		state.Config.Author = author
+	}
+
+	// freeze a running container so the diff is consistent; exited ones
+	// cannot be paused and paused ones stay paused afterwards
+	if taskObj, err := cont.Task(ctx, nil); err == nil && pause {
+		st, err := taskObj.Status(ctx)
+		if err != nil {
+			return err
+		}
+		if st.Status == containerd.Running {
+			if err := taskObj.Pause(ctx); err != nil {
+				return fmt.Errorf("failed to pause %s: %v", args[0], err)
+			}
+			defer taskObj.Resume(ctx)
+		}
+	} else if err != nil && !errdefs.IsNotFound(err) {
+		return err
+	}
+
+	mapping, err := userns.FromLabels(info.Labels[boxylabels.UIDMap], info.Labels[boxylabels.GIDMap])
+	if err != nil {
+		return err
+	}
+	if mapping == nil {
+		err = state.AddLayer(ctx, c, info.Snapshotter, info.SnapshotKey, "boxy commit "+args[0], message)
+	} else {
+		// the snapshot is owned by host ids; the image must carry the
+		// container's own
+		err = state.AddTarLayer(ctx, c, info.SnapshotKey, func(w io.Writer) error {
+			return userns.Unshifted(w, *mapping, func(w io.Writer) error {
+				return snapshot.WriteDiff(ctx, c, info, w)
+			})
+		}, "boxy commit "+args[0], message)
+	}
+	if err != nil {
+		return err
+	}
+
+	newImg, err := state.Write(ctx, c, ref, nil)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✔ committed %s as %s (%s)\n", args[0], ref, newImg.Target().Digest)
+	return nil
+}
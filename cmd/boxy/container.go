@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/arnab2001/boxy/internal/client"
+	boxylabels "github.com/arnab2001/boxy/internal/labels"
+	"github.com/arnab2001/boxy/internal/selector"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "container",
+		Short: "Manage containers",
+	}
+
+	prune := &cobra.Command{
+		Use:   "prune [-f] [--filter key=value]",
+		Short: "Remove all created and exited containers",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			force, _ := cmd.Flags().GetBool("force")
+			filters, _ := cmd.Flags().GetStringArray("filter")
+			filter, err := selector.ParseFilters(filters)
+			if err != nil {
+				return err
+			}
+			if _, ok := filter["status"]; ok {
+				return fmt.Errorf("prune takes no status filter (it removes created and exited containers)")
+			}
+			filter["status"] = []string{selector.Created, selector.Exited}
+			cmd.SilenceUsage = true
+
+			if !force && !confirm("This will remove all stopped containers.") {
+				return nil
+			}
+
+			ctx := client.Default()
+			c, err := client.Instance()
+			if err != nil {
+				return err
+			}
+			refs, err := matchingContainers(ctx, c, filter)
+			if err != nil {
+				return err
+			}
+			return selector.Each(refs, maxParallel, func(ref string) error {
+				cont, err := loadContainer(ctx, c, ref)
+				if err != nil {
+					return err
+				}
+				// keep a monitor from restarting the container meanwhile
+				if _, err := cont.SetLabels(ctx, map[string]string{boxylabels.Stopped: "true"}); err != nil {
+					return err
+				}
+				if err := removeContainer(ctx, cont); err != nil {
+					return err
+				}
+				fmt.Println(ref)
+				return nil
+			})
+		},
+	}
+	prune.Flags().BoolP("force", "f", false, "Do not ask for confirmation")
+	prune.Flags().StringArray("filter", nil, "Only prune containers matching a filter (label=K[=V], ancestor=)")
+
+	cmd.AddCommand(prune)
+	rootCmd.AddCommand(cmd)
+}
+
+// confirm asks a y/N question on the terminal; anything but y or yes,
+// including end of input, means no
+func confirm(warning string) bool {
+	fmt.Fprintf(os.Stderr, "WARNING! %s\nAre you sure you want to continue? [y/N] ", warning)
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
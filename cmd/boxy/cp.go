@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/arnab2001/boxy/internal/client"
+	"github.com/arnab2001/boxy/internal/snapshot"
+	"github.com/arnab2001/boxy/internal/userns"
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/continuity/fs"
+	"github.com/spf13/cobra"
+)
+
+// taskRootDir is where the containerd v2 shim mounts a task's rootfs
+const taskRootDir = "/run/containerd/io.containerd.runtime.v2.task"
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "cp <name>:<path> <local> | <local> <name>:<path>",
+		Short: "Copy files between a container and the host",
+		Args:  cobra.ExactArgs(2),
+		RunE:  cpE,
+	}
+	cmd.Flags().BoolP("archive", "a", false, "preserve uid/gid of copied files")
+	cmd.Flags().BoolP("follow-link", "L", false, "follow a symlink given as the source path")
+	rootCmd.AddCommand(cmd)
+}
+
+// splitContainerPath splits "name:/path"; local paths never match because a
+// container name can't contain '/'
+func splitContainerPath(arg string) (string, string, bool) {
+	name, p, ok := strings.Cut(arg, ":")
+	if !ok || name == "" || strings.Contains(name, "/") {
+		return "", "", false
+	}
+	if p == "" {
+		p = "/"
+	}
+	return name, p, true
+}
+
+func cpE(cmd *cobra.Command, args []string) error {
+	archive, _ := cmd.Flags().GetBool("archive")
+	follow, _ := cmd.Flags().GetBool("follow-link")
+
+	srcName, srcPath, srcIsCont := splitContainerPath(args[0])
+	dstName, dstPath, dstIsCont := splitContainerPath(args[1])
+	if srcIsCont == dstIsCont {
+		return fmt.Errorf("exactly one of source and destination must be <name>:<path>")
+	}
+
+	ctx := client.Default()
+	c, err := client.Instance()
+	if err != nil {
+		return err
+	}
+
+	if srcIsCont {
+		cont, err := loadContainer(ctx, c, srcName)
+		if err != nil {
+			return err
+		}
+		if err := copyFromContainer(ctx, c, cont, srcPath, args[1], archive, follow); err != nil {
+			return err
+		}
+	} else {
+		cont, err := loadContainer(ctx, c, dstName)
+		if err != nil {
+			return err
+		}
+		if err := copyToContainer(ctx, c, cont, args[0], dstPath, archive, follow); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("✓ copied %s to %s\n", args[0], args[1])
+	return nil
+}
+
+// withContainerRoot runs fn with the container's root filesystem: through
+// the task's mount namespace when running, otherwise by mounting its snapshot
+func withContainerRoot(ctx context.Context, c *containerd.Client, cont containerd.Container, write bool, fn func(root string) error) error {
+	taskObj, err := cont.Task(ctx, nil)
+	if err == nil {
+		st, err := taskObj.Status(ctx)
+		if err != nil {
+			return err
+		}
+		if st.Status == containerd.Running || st.Status == containerd.Paused {
+			return fn(fmt.Sprintf("/proc/%d/root", taskObj.Pid()))
+		}
+		// a stopped task keeps its rootfs mounted by the shim
+		return fn(filepath.Join(taskRootDir, "boxy", cont.ID(), "rootfs"))
+	}
+	if !errdefs.IsNotFound(err) {
+		return err
+	}
+
+	info, err := cont.Info(ctx)
+	if err != nil {
+		return err
+	}
+	if write {
+		return snapshot.WithRoot(ctx, c, info, fn)
+	}
+	return snapshot.WithReadonlyRoot(ctx, c, info, fn)
+}
+
+// copyTarget decides where an archived source lands, docker cp style:
+// "src/." copies contents, an existing directory receives src by name,
+// anything else is (re)named to dst
+func copyTarget(src, dst string, dstIsDir bool) (dir, name string) {
+	switch {
+	case strings.HasSuffix(src, "/."):
+		return dst, "."
+	case dstIsDir:
+		name = path.Base(path.Clean("/" + filepath.ToSlash(src)))
+		if name == "/" {
+			name = "."
+		}
+		return dst, name
+	default:
+		return filepath.Dir(dst), filepath.Base(dst)
+	}
+}
+
+func copyFromContainer(ctx context.Context, c *containerd.Client, cont containerd.Container, src, dst string, archive, follow bool) error {
+	fi, err := os.Stat(dst)
+	dir, name := copyTarget(src, dst, err == nil && fi.IsDir())
+	if name == "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(withContainerRoot(ctx, c, cont, false, func(root string) error {
+			return snapshot.WriteTar(pw, root, src, name, snapshot.TarOptions{FollowLink: follow})
+		}))
+	}()
+
+	err = snapshot.ExtractTar(pr, dir, snapshot.TarOptions{PreserveOwner: archive})
+	pr.CloseWithError(err)
+	return err
+}
+
+func copyToContainer(ctx context.Context, c *containerd.Client, cont containerd.Container, src, dst string, archive, follow bool) error {
+	abs, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(src, "/.") {
+		abs += "/."
+	}
+	if _, err := os.Lstat(strings.TrimSuffix(abs, "/.")); err != nil {
+		return err
+	}
+
+	// new files belong to the container's root, which --userns remaps
+	opts := snapshot.TarOptions{PreserveOwner: archive, ChownEntries: !archive}
+	if spec, err := cont.Spec(ctx); err == nil && spec.Linux != nil {
+		if uid, ok := userns.ToHost(spec.Linux.UIDMappings, 0); ok {
+			opts.UID = int(uid)
+		}
+		if gid, ok := userns.ToHost(spec.Linux.GIDMappings, 0); ok {
+			opts.GID = int(gid)
+		}
+	}
+
+	return withContainerRoot(ctx, c, cont, true, func(root string) error {
+		target, err := fs.RootPath(root, dst)
+		if err != nil {
+			return err
+		}
+		fi, err := os.Stat(target)
+		dir, name := copyTarget(abs, dst, err == nil && fi.IsDir())
+
+		// resolve the destination directory inside the container's root
+		if dir, err = fs.RootPath(root, dir); err != nil {
+			return err
+		}
+		if name == "." {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+		} else if _, err := os.Stat(dir); err != nil {
+			return fmt.Errorf("destination directory %s does not exist in container", path.Dir(dst))
+		}
+
+		pr, pw := io.Pipe()
+		go func() {
+			hostPath := strings.TrimSuffix(abs, "/.")
+			pw.CloseWithError(snapshot.WriteTar(pw, filepath.Dir(hostPath), filepath.Base(hostPath), name,
+				snapshot.TarOptions{FollowLink: follow}))
+		}()
+
+		err = snapshot.ExtractTar(pr, dir, opts)
+		pr.CloseWithError(err)
+		return err
+	})
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/arnab2001/boxy/internal/client"
+	"github.com/arnab2001/boxy/internal/health"
+	boxylabels "github.com/arnab2001/boxy/internal/labels"
+	"github.com/arnab2001/boxy/internal/names"
+	boxyoci "github.com/arnab2001/boxy/internal/oci"
+	"github.com/arnab2001/boxy/internal/policy"
+	"github.com/arnab2001/boxy/internal/runopts"
+	"github.com/arnab2001/boxy/internal/signals"
+	"github.com/arnab2001/boxy/internal/userns"
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/oci"
+	refdocker "github.com/containerd/containerd/reference/docker"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "create [--name <ctr>] <image> [cmd...]",
+		Short: "Create a container without starting it (see boxy start)",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts, err := runopts.ParseCreate(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+
+			ctx := client.Default()
+			c, err := client.Instance()
+			if err != nil {
+				return err
+			}
+			cont, err := createContainer(ctx, c, opts, args, opts.Stdio != nil && opts.Stdio.TTY)
+			if err != nil {
+				return err
+			}
+			fmt.Println(cont.ID())
+			return nil
+		},
+	}
+	runopts.AddFlags(cmd.Flags())
+	cmd.Flags().MarkHidden("detach")
+	cmd.Flags().MarkHidden("detach-keys")
+	rootCmd.AddCommand(cmd)
+}
+
+// createContainer is the first half of boxy run: it checks the policy,
+// resolves (and pulls) the image and creates the container with its
+// snapshot and spec. Everything boxy start needs later is stored in the
+// container's labels and spec. A generated name is stored in opts.Name.
+func createContainer(ctx context.Context, c *containerd.Client, opts *runopts.Options, args []string, tty bool) (containerd.Container, error) {
+	security := opts.Security
+	securityOpts, err := boxyoci.WithSecurity(security)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Name, err = newContainerName(ctx, c, opts.Name); err != nil {
+		return nil, err
+	}
+	id := names.NewID()
+
+	portMappings := opts.Ports
+	if len(portMappings) > 0 {
+		fmt.Fprintf(os.Stderr, "Parsed port mappings: %+v\n", portMappings)
+	}
+
+	// ── run-time policy ────────────────────────────────────────
+	pol, err := policy.Load()
+	if err != nil {
+		return nil, err
+	}
+	if err := policy.Err(pol.CheckRun(opts.PolicyRequest())); err != nil {
+		return nil, err
+	}
+
+	// ── normalise reference ────────────────────────────────────
+	named, err := refdocker.ParseDockerRef(args[0])
+	if err != nil {
+		return nil, err
+	}
+	ref := named.String()
+
+	// ── ensure image exists (auto-pull) ────────────────────────
+	img, err := ensureImage(ctx, c, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	// ── health check ───────────────────────────────────────────
+	imageHealth, err := health.FromImage(ctx, img)
+	if err != nil {
+		return nil, err
+	}
+	healthcheck := opts.Healthcheck(imageHealth)
+	if healthcheck != nil {
+		if _, err := healthcheck.Args(); err != nil {
+			return nil, err
+		}
+	}
+
+	// ── stop signal ────────────────────────────────────────────
+	stopSignal := opts.StopSignal
+	if stopSignal == "" {
+		imageSignal, err := containerd.GetOCIStopSignal(ctx, img, "")
+		if err != nil {
+			return nil, err
+		}
+		if imageSignal != "" {
+			if sig, err := signals.Parse(imageSignal); err == nil {
+				stopSignal = signals.Name(sig)
+			} else {
+				fmt.Fprintf(os.Stderr, "Warning: ignoring image STOPSIGNAL: %v\n", err)
+			}
+		}
+	}
+
+	// ── user namespace ─────────────────────────────────────────
+	// allocation is serialised until the new container records its mapping
+	unlock, err := userns.Lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	mapping, err := usernsMapping(ctx, c, opts.UserNS, security.Privileged)
+	if err != nil {
+		return nil, err
+	}
+	if mapping == nil {
+		unlock()
+	}
+	snapshotOpt := containerd.WithNewSnapshot(id+"-snap", img)
+	if mapping != nil {
+		snapshotOpt = userns.WithRemappedSnapshot(id+"-snap", img, *mapping)
+	}
+
+	// ── build OCI spec ─────────────────────────────────────────
+	specOpts := []oci.SpecOpts{oci.WithImageConfig(img)}
+	if tty {
+		specOpts = append(specOpts, oci.WithTTY)
+	}
+	if len(args) > 1 {
+		specOpts = append(specOpts, oci.WithProcessArgs(args[1:]...))
+	}
+	if len(opts.Mounts) > 0 {
+		specOpts = append(specOpts, oci.WithMounts(opts.Mounts))
+	}
+	if opts.HostNetwork {
+		specOpts = append(specOpts,
+			oci.WithHostNamespace(specs.NetworkNamespace),
+			oci.WithHostHostsFile,
+			oci.WithHostResolvconf,
+		)
+	}
+	specOpts = append(specOpts, boxyoci.WithResources(opts.Resources)...)
+	specOpts = append(specOpts, securityOpts...)
+
+	labels, err := opts.Labels(healthcheck, stopSignal)
+	if err != nil {
+		return nil, err
+	}
+	labels[boxylabels.UserNS] = userns.Host
+	if mapping != nil {
+		specOpts = append(specOpts, oci.WithUserNamespace(mapping.UIDs, mapping.GIDs))
+		labels[boxylabels.UserNS] = mapping.Mode
+		labels[boxylabels.UIDMap] = userns.FormatMappings(mapping.UIDs)
+		labels[boxylabels.GIDMap] = userns.FormatMappings(mapping.GIDs)
+	}
+
+	return c.NewContainer(ctx, id,
+		snapshotOpt,
+		containerd.WithNewSpec(specOpts...),
+		containerd.WithContainerLabels(labels),
+	)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/arnab2001/boxy/internal/client"
+	"github.com/arnab2001/boxy/internal/snapshot"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "diff <name>",
+		Short: "List files added (A), changed (C) or deleted (D) in a container",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			ctx := client.Default()
+			c, err := client.Instance()
+			if err != nil {
+				return err
+			}
+
+			cont, err := loadContainer(ctx, c, args[0])
+			if err != nil {
+				return err
+			}
+			info, err := cont.Info(ctx)
+			if err != nil {
+				return err
+			}
+
+			return snapshot.ContainerChanges(ctx, c, info, func(change snapshot.Change) error {
+				fmt.Println(change)
+				return nil
+			})
+		},
+	}
+	rootCmd.AddCommand(cmd)
+}
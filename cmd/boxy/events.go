@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/arnab2001/boxy/internal/client"
+	"github.com/arnab2001/boxy/internal/events"
+	"github.com/containerd/containerd"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "events",
+		Short: "Stream container, image and snapshot events of the boxy namespace",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			filterFlags, _ := cmd.Flags().GetStringArray("filter")
+			filter, err := events.ParseFilters(filterFlags)
+			if err != nil {
+				return err
+			}
+			format, _ := cmd.Flags().GetString("format")
+			if format != "" && format != "json" {
+				return fmt.Errorf("unsupported --format %q (only json)", format)
+			}
+			var since time.Time
+			if s, _ := cmd.Flags().GetString("since"); s != "" {
+				if since, err = parseSince(s); err != nil {
+					return err
+				}
+			}
+
+			ctx := client.Default()
+			c, err := client.Instance()
+			if err != nil {
+				return err
+			}
+			ch, errs := c.Subscribe(ctx, fmt.Sprintf("namespace==%s", client.Namespace))
+
+			enc := json.NewEncoder(os.Stdout)
+			// names and images are kept for destroyed containers
+			known := events.NewContainers(func(id string) (string, string, error) {
+				cont, err := c.LoadContainer(ctx, id)
+				if err != nil {
+					return "", "", err
+				}
+				info, err := cont.Info(ctx, containerd.WithoutRefreshedMetadata)
+				if err != nil {
+					return "", "", err
+				}
+				return containerName(ctx, cont), info.Image, nil
+			})
+			// known up front so that renames report the old name
+			if containers, err := c.Containers(ctx); err == nil {
+				for _, cont := range containers {
+					if info, err := cont.Info(ctx, containerd.WithoutRefreshedMetadata); err == nil {
+						known.Add(cont.ID(), containerName(ctx, cont), info.Image)
+					}
+				}
+			}
+			for {
+				select {
+				case env := <-ch:
+					e, ok, err := events.Decode(env)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "⚠ %v\n", err)
+						continue
+					}
+					if !ok || !known.Annotate(e) {
+						continue
+					}
+					if e.Time.Before(since) || !filter.Match(e) {
+						continue
+					}
+					if format == "json" {
+						if err := enc.Encode(e); err != nil {
+							return err
+						}
+						continue
+					}
+					fmt.Println(formatEvent(e))
+				case err := <-errs:
+					return err
+				}
+			}
+		},
+	}
+	cmd.Flags().StringArray("filter", nil, "filter events (type=, event=, container=, image=)")
+	cmd.Flags().String("since", "", "only report events from a future time on (RFC 3339); past events cannot be replayed")
+	cmd.Flags().String("format", "", "output format (json: one object per event)")
+	rootCmd.AddCommand(cmd)
+}
+
+// parseSince accepts an RFC 3339 timestamp that is not in the past:
+// containerd keeps no event history that could be replayed
+func parseSince(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --since %q (RFC 3339 time)", s)
+	}
+	if t.Before(time.Now()) {
+		return time.Time{}, fmt.Errorf("--since %s is in the past: containerd keeps no event history to replay", s)
+	}
+	return t, nil
+}
+
+// formatEvent renders an event on one line, docker events style
+func formatEvent(e *events.Event) string {
+	line := fmt.Sprintf("%s %s %s %s", e.Time.Format(time.RFC3339Nano), e.Type, e.Action, e.ID)
+	if len(e.Attributes) == 0 {
+		return line
+	}
+	attrs := make([]string, 0, len(e.Attributes))
+	for k, v := range e.Attributes {
+		attrs = append(attrs, k+"="+v)
+	}
+	sort.Strings(attrs)
+	return line + " (" + strings.Join(attrs, ", ") + ")"
+}
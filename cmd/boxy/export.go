@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/arnab2001/boxy/internal/client"
+	boxylabels "github.com/arnab2001/boxy/internal/labels"
+	"github.com/arnab2001/boxy/internal/snapshot"
+	"github.com/arnab2001/boxy/internal/userns"
+	console "github.com/containerd/console"
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/containers"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "export <name> [-o rootfs.tar]",
+		Short: "Export a container's root filesystem as a tar archive",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			output, _ := cmd.Flags().GetString("output")
+
+			var w io.Writer = os.Stdout
+			if output == "" || output == "-" {
+				if _, err := console.ConsoleFromFile(os.Stdout); err == nil {
+					return fmt.Errorf("refusing to write a tar archive to a terminal; use -o or redirect stdout")
+				}
+			} else {
+				f, err := os.Create(output)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+
+			ctx := client.Default()
+			c, err := client.Instance()
+			if err != nil {
+				return err
+			}
+
+			cont, err := loadContainer(ctx, c, args[0])
+			if err != nil {
+				return err
+			}
+			info, err := cont.Info(ctx)
+			if err != nil {
+				return err
+			}
+
+			mapping, err := userns.FromLabels(info.Labels[boxylabels.UIDMap], info.Labels[boxylabels.GIDMap])
+			if err != nil {
+				return err
+			}
+			if err := exportRoot(ctx, c, info, w, mapping); err != nil {
+				if output != "" && output != "-" {
+					os.Remove(output)
+				}
+				return err
+			}
+			if output != "" && output != "-" {
+				fmt.Printf("✔ exported %s to %s\n", args[0], output)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringP("output", "o", "", "write to a file instead of stdout")
+	rootCmd.AddCommand(cmd)
+}
+
+// exportRoot writes the container's root filesystem as a tar archive,
+// owned by container ids when it is remapped
+func exportRoot(ctx context.Context, c *containerd.Client, info containers.Container, w io.Writer, m *userns.Mapping) error {
+	if m == nil {
+		return snapshot.ExportContainer(ctx, c, info, w)
+	}
+	return userns.Unshifted(w, *m, func(w io.Writer) error {
+		return snapshot.ExportContainer(ctx, c, info, w)
+	})
+}
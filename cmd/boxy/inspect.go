@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/arnab2001/boxy/internal/client"
+	"github.com/arnab2001/boxy/internal/health"
+	boxylabels "github.com/arnab2001/boxy/internal/labels"
+	"github.com/arnab2001/boxy/internal/restart"
+	"github.com/arnab2001/boxy/internal/userns"
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/errdefs"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/spf13/cobra"
+)
+
+// inspectInfo is the JSON document printed by `boxy inspect`
+type inspectInfo struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Image    string            `json:"image"`
+	Created  time.Time         `json:"created"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	State    inspectState      `json:"state"`
+	Process  inspectProcess    `json:"process"`
+	Security inspectSecurity   `json:"security"`
+}
+
+type inspectState struct {
+	Status        string        `json:"status"`
+	Pid           uint32        `json:"pid,omitempty"`
+	ExitCode      *uint32       `json:"exitCode,omitempty"` // last task's, once it exited
+	Health        *health.State `json:"health,omitempty"`
+	RestartPolicy string        `json:"restartPolicy"`
+	Restarts      int           `json:"restarts"`
+}
+
+type inspectProcess struct {
+	Args     []string `json:"args"`
+	Env      []string `json:"env,omitempty"`
+	Cwd      string   `json:"cwd"`
+	UID      uint32   `json:"uid"`
+	GID      uint32   `json:"gid"`
+	Terminal bool     `json:"terminal"`
+}
+
+type inspectSecurity struct {
+	Privileged      bool                `json:"privileged"`
+	Capabilities    inspectCapabilities `json:"capabilities"`
+	NoNewPrivileges bool                `json:"noNewPrivileges"`
+	ReadonlyRootfs  bool                `json:"readonlyRootfs"`
+	Seccomp         string              `json:"seccomp"`
+	Devices         []string            `json:"devices,omitempty"`
+	UserNS          inspectUserNS       `json:"userns"`
+}
+
+type inspectUserNS struct {
+	Mode        string                 `json:"mode"`
+	UIDMappings []specs.LinuxIDMapping `json:"uidMappings,omitempty"`
+	GIDMappings []specs.LinuxIDMapping `json:"gidMappings,omitempty"`
+}
+
+type inspectCapabilities struct {
+	Effective []string `json:"effective"`
+	Bounding  []string `json:"bounding"`
+	Permitted []string `json:"permitted"`
+}
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "inspect <name>...",
+		Short: "Show detailed container information as JSON",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			ctx := client.Default()
+			c, err := client.Instance()
+			if err != nil {
+				return err
+			}
+
+			var out []inspectInfo
+			for _, name := range args {
+				cont, err := loadContainer(ctx, c, name)
+				if err != nil {
+					return err
+				}
+				info, err := inspectContainer(ctx, cont)
+				if err != nil {
+					return err
+				}
+				out = append(out, info)
+			}
+
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(out)
+		},
+	}
+	rootCmd.AddCommand(cmd)
+}
+
+func inspectContainer(ctx context.Context, cont containerd.Container) (inspectInfo, error) {
+	info, err := cont.Info(ctx)
+	if err != nil {
+		return inspectInfo{}, err
+	}
+	spec, err := cont.Spec(ctx)
+	if err != nil {
+		return inspectInfo{}, err
+	}
+
+	out := inspectInfo{
+		ID:      info.ID,
+		Name:    containerName(ctx, cont),
+		Image:   info.Image,
+		Created: info.CreatedAt,
+		Labels:  info.Labels,
+		State:   inspectState{Status: "STOPPED", RestartPolicy: restart.No},
+	}
+	if p := info.Labels[boxylabels.RestartPolicy]; p != "" {
+		out.State.RestartPolicy = p
+	}
+	out.State.Restarts, _ = strconv.Atoi(info.Labels[boxylabels.Restarts])
+
+	taskObj, err := cont.Task(ctx, nil)
+	switch {
+	case err == nil:
+		if st, err := taskObj.Status(ctx); err == nil {
+			out.State.Status = string(st.Status)
+			if st.Status == containerd.Stopped {
+				out.State.ExitCode = &st.ExitStatus
+			}
+		}
+		out.State.Pid = taskObj.Pid()
+		if out.State.Health, err = health.Load(info.ID); err != nil {
+			return inspectInfo{}, err
+		}
+	case !errdefs.IsNotFound(err):
+		return inspectInfo{}, err
+	default:
+		if code, err := strconv.ParseUint(info.Labels[boxylabels.ExitCode], 10, 32); err == nil {
+			exitCode := uint32(code)
+			out.State.ExitCode = &exitCode
+		}
+	}
+
+	if p := spec.Process; p != nil {
+		out.Process = inspectProcess{
+			Args:     p.Args,
+			Env:      p.Env,
+			Cwd:      p.Cwd,
+			UID:      p.User.UID,
+			GID:      p.User.GID,
+			Terminal: p.Terminal,
+		}
+		out.Security.NoNewPrivileges = p.NoNewPrivileges
+		if caps := p.Capabilities; caps != nil {
+			out.Security.Capabilities = inspectCapabilities{
+				Effective: caps.Effective,
+				Bounding:  caps.Bounding,
+				Permitted: caps.Permitted,
+			}
+		}
+	}
+	out.Security.Privileged = info.Labels[boxylabels.Privileged] == "true"
+	out.Security.Seccomp = info.Labels[boxylabels.Seccomp]
+	if spec.Linux == nil || spec.Linux.Seccomp == nil {
+		out.Security.Seccomp = "unconfined"
+	}
+	if spec.Root != nil {
+		out.Security.ReadonlyRootfs = spec.Root.Readonly
+	}
+	if spec.Linux != nil && !out.Security.Privileged { // privileged gets every host device
+		for _, d := range spec.Linux.Devices {
+			out.Security.Devices = append(out.Security.Devices, fmt.Sprintf("%s (%s %d:%d)", d.Path, d.Type, d.Major, d.Minor))
+		}
+	}
+	out.Security.UserNS.Mode = info.Labels[boxylabels.UserNS]
+	if spec.Linux != nil && len(spec.Linux.UIDMappings) > 0 {
+		out.Security.UserNS.UIDMappings = spec.Linux.UIDMappings
+		out.Security.UserNS.GIDMappings = spec.Linux.GIDMappings
+	} else {
+		out.Security.UserNS.Mode = userns.Host
+	}
+	return out, nil
+}
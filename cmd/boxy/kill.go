@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+
+	"github.com/arnab2001/boxy/internal/client"
+	boxylabels "github.com/arnab2001/boxy/internal/labels"
+	"github.com/arnab2001/boxy/internal/selector"
+	"github.com/arnab2001/boxy/internal/signals"
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "kill [-s signal] <name>... | --filter key=value",
+		Short: "Send a signal to running containers (default SIGKILL)",
+		Args:  cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sigFlag, _ := cmd.Flags().GetString("signal")
+			sig, err := signals.Parse(sigFlag)
+			if err != nil {
+				return err
+			}
+			filters, _ := cmd.Flags().GetStringArray("filter")
+
+			ctx := client.Default()
+			c, err := client.Instance()
+			if err != nil {
+				return err
+			}
+			refs, err := selectContainers(ctx, c, args, filters)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+
+			return selector.Each(refs, maxParallel, func(ref string) error {
+				return killContainer(ctx, c, ref, sig)
+			})
+		},
+	}
+	cmd.Flags().StringP("signal", "s", "KILL", "Signal to send (name like SIGHUP or HUP, or number)")
+	cmd.Flags().StringArray("filter", nil, "Signal the containers matching a filter (label=K[=V], status=, ancestor=)")
+	rootCmd.AddCommand(cmd)
+}
+
+// killContainer sends sig to the container's task. SIGKILL and the
+// container's stop signal count as boxy stop for its restart policy.
+func killContainer(ctx context.Context, c *containerd.Client, ref string, sig syscall.Signal) error {
+	cont, err := loadContainer(ctx, c, ref)
+	if err != nil {
+		return err
+	}
+	status, err := containerStatus(ctx, cont)
+	if err != nil {
+		return err
+	}
+	if status != selector.Running && status != selector.Paused {
+		return fmt.Errorf("container is not running")
+	}
+	task, err := cont.Task(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	labels, err := cont.Labels(ctx)
+	if err != nil {
+		return err
+	}
+	if stopSignal, _ := signals.Stop(labels); sig == syscall.SIGKILL || sig == stopSignal {
+		if _, err := cont.SetLabels(ctx, map[string]string{boxylabels.Stopped: "true"}); err != nil {
+			return err
+		}
+	}
+
+	if err := task.Kill(ctx, sig); err != nil {
+		if errdefs.IsNotFound(err) {
+			return fmt.Errorf("container is not running")
+		}
+		return err
+	}
+	if sig == syscall.SIGKILL {
+		resumePaused(ctx, task)
+	}
+	fmt.Printf("✓ sent %s to %s\n", signals.Name(sig), ref)
+	return nil
+}
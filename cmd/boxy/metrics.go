@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/arnab2001/boxy/internal/client"
+	boxylabels "github.com/arnab2001/boxy/internal/labels"
+	"github.com/arnab2001/boxy/internal/metrics"
+	"github.com/arnab2001/boxy/internal/stats"
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "metrics",
+		Short: "Export container metrics",
+	}
+
+	serve := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve Prometheus metrics of the boxy containers on /metrics",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			listen, _ := cmd.Flags().GetString("listen")
+
+			ctx := client.Default()
+			c, err := client.Instance()
+			if err != nil {
+				return err
+			}
+			reg := prometheus.NewRegistry()
+			reg.MustRegister(&metrics.Collector{
+				List: func() ([]metrics.Container, error) { return listMetrics(ctx, c) },
+			})
+
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+			fmt.Printf("▶︎ serving metrics on %s/metrics\n", listen)
+			return http.ListenAndServe(listen, mux)
+		},
+	}
+	serve.Flags().String("listen", ":9323", "address to listen on")
+
+	cmd.AddCommand(serve)
+	rootCmd.AddCommand(cmd)
+}
+
+// listMetrics reads the state and task metrics of every container
+func listMetrics(ctx context.Context, c *containerd.Client) ([]metrics.Container, error) {
+	containers, err := c.Containers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []metrics.Container
+	for _, cont := range containers {
+		info, err := cont.Info(ctx, containerd.WithoutRefreshedMetadata)
+		if errdefs.IsNotFound(err) {
+			continue // removed since listing
+		}
+		if err != nil {
+			return nil, err
+		}
+		m := metrics.Container{Name: containerName(ctx, cont), Image: info.Image}
+		m.Restarts, _ = strconv.Atoi(info.Labels[boxylabels.Restarts])
+		if ports := info.Labels[boxylabels.Ports]; ports != "" {
+			m.Ports = len(strings.Split(ports, ","))
+		}
+
+		task, err := cont.Task(ctx, nil)
+		if errdefs.IsNotFound(err) {
+			out = append(out, m)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		st, err := task.Status(ctx)
+		if err != nil {
+			return nil, err
+		}
+		switch st.Status {
+		case containerd.Stopped:
+			code := st.ExitStatus
+			m.ExitCode = &code
+		case containerd.Running, containerd.Paused, containerd.Pausing:
+			m.Running = st.Status == containerd.Running
+			if metric, err := task.Metrics(ctx); err == nil {
+				if s, err := stats.Decode(metric); err == nil {
+					_ = s.ReadNetDev(task.Pid())
+					m.Sample = &s
+				}
+			}
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
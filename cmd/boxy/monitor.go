@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/arnab2001/boxy/internal/attach"
+	"github.com/arnab2001/boxy/internal/client"
+	"github.com/arnab2001/boxy/internal/cni"
+	"github.com/arnab2001/boxy/internal/config"
+	"github.com/arnab2001/boxy/internal/health"
+	boxylabels "github.com/arnab2001/boxy/internal/labels"
+	"github.com/arnab2001/boxy/internal/ports"
+	"github.com/arnab2001/boxy/internal/restart"
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/cio"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:    "monitor <id>",
+		Short:  "Watch a container in the background (started by boxy run)",
+		Hidden: true,
+		Args:   cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return monitor(client.Default(), args[0])
+		},
+	}
+	rootCmd.AddCommand(cmd)
+}
+
+// startMonitor spawns a detached `boxy monitor` for the container, logging
+// to monitor.log in its run directory. The monitor exits with the task
+// unless the restart policy starts it again.
+func startMonitor(id string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(config.RunDir(), id)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(filepath.Join(dir, "monitor.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(exe, "monitor", id)
+	cmd.Stdout, cmd.Stderr = logFile, logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true} // outlive the terminal
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start monitor: %v", err)
+	}
+	return cmd.Process.Release()
+}
+
+// monitor supervises a container: it runs the health check on schedule,
+// applies the restart policy whenever the task exits, removes --rm
+// containers once they stay down and drains the console fifos while
+// nobody is attached
+func monitor(ctx context.Context, id string) error {
+	c, err := client.Instance()
+	if err != nil {
+		return err
+	}
+	cont, err := c.LoadContainer(ctx, id)
+	if err != nil {
+		return err
+	}
+	labels, err := cont.Labels(ctx)
+	if err != nil {
+		return err
+	}
+	var healthcheck *health.Config
+	if data := labels[boxylabels.Healthcheck]; data != "" {
+		var cfg health.Config
+		if err := json.Unmarshal([]byte(data), &cfg); err != nil {
+			return fmt.Errorf("invalid health check of %s: %v", id, err)
+		}
+		cfg = cfg.WithDefaults()
+		healthcheck = &cfg
+	}
+	policy, err := restart.Parse(labels[boxylabels.RestartPolicy])
+	if err != nil {
+		return err
+	}
+	restarts, _ := strconv.Atoi(labels[boxylabels.Restarts])
+
+	// only the fifo paths are needed; the monitor never copies them itself
+	task, err := cont.Task(ctx, func(fifos *cio.FIFOSet) (cio.IO, error) {
+		return &fifoIO{config: fifos.Config}, nil
+	})
+	if err != nil {
+		return err
+	}
+	var fifos cio.Config
+	if tio := task.IO(); tio != nil { // no IO for a task in unknown state
+		fifos = tio.Config()
+	}
+	if healthcheck == nil && !policy.Enabled() && labels[boxylabels.AutoRemove] != "true" && fifos.Stdout == "" {
+		return fmt.Errorf("%s has no health check, restart policy, --rm or console", id)
+	}
+	if fifos.Stdout != "" {
+		drainCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			dir := filepath.Join(config.RunDir(), id)
+			if err := attach.Drain(drainCtx, dir, fifos.Stdout, fifos.Stderr); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: draining output: %v\n", id, err)
+			}
+		}()
+	}
+	var delay time.Duration
+	for {
+		started := time.Now()
+		status, err := supervise(ctx, id, cont, task, healthcheck)
+		if err != nil {
+			return err
+		}
+		code := status.ExitCode()
+		recordExit(ctx, cont, code)
+
+		if !policy.ShouldRestart(code, restarts, stoppedByUser(ctx, cont)) {
+			fmt.Printf("%s: %s exited with code %d\n", time.Now().Format(time.RFC3339), id, code)
+			// read again: run hands --rm over when detaching from the container
+			if labels, err := cont.Labels(ctx); err == nil && labels[boxylabels.AutoRemove] == "true" {
+				return removeContainer(ctx, cont)
+			}
+			return nil
+		}
+		delay = restart.Backoff(delay, time.Since(started))
+		fmt.Printf("%s: %s exited with code %d, restarting in %s\n", time.Now().Format(time.RFC3339), id, code, delay)
+		time.Sleep(delay)
+
+		unlock, err := lockTask(id)
+		if err != nil {
+			return err
+		}
+		// stopped, removed or started again while backing off: a new start
+		// brings its own monitor
+		if stoppedByUser(ctx, cont) || !isCurrentTask(ctx, cont, task) {
+			unlock()
+			return nil
+		}
+		task, err = restartTask(ctx, id, cont, task)
+		unlock()
+		if err != nil {
+			return err
+		}
+		restarts++
+		if _, err := cont.SetLabels(ctx, map[string]string{boxylabels.Restarts: strconv.Itoa(restarts)}); err != nil {
+			return err
+		}
+	}
+}
+
+// stoppedByUser reports whether the container was stopped with boxy stop
+// or removed
+func stoppedByUser(ctx context.Context, cont containerd.Container) bool {
+	labels, err := cont.Labels(ctx)
+	return err != nil || labels[boxylabels.Stopped] == "true"
+}
+
+// resetHealth marks a container with a health check as starting before
+// its new task runs. The monitor is spawned asynchronously and would
+// otherwise leave the previous task's status to ps and wait meanwhile.
+func resetHealth(id string, labels map[string]string) error {
+	if labels[boxylabels.Healthcheck] == "" {
+		return nil
+	}
+	return (&health.State{Status: health.Starting}).Save(id)
+}
+
+// isCurrentTask reports whether the exited task is still the container's
+// task, i.e. nobody has started a new one
+func isCurrentTask(ctx context.Context, cont containerd.Container, task containerd.Task) bool {
+	cur, err := cont.Task(ctx, nil)
+	if err != nil || cur.Pid() != task.Pid() {
+		return false
+	}
+	st, err := cur.Status(ctx)
+	return err == nil && st.Status == containerd.Stopped
+}
+
+// lockTask serialises replacing the container's task between boxy start,
+// system restore and the monitor. The returned function releases the lock.
+func lockTask(id string) (func(), error) {
+	dir := filepath.Join(config.RunDir(), id)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "task.lock"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() { f.Close() }, nil // closing the file drops the lock
+}
+
+// supervise runs the health check (if any) until the task exits
+func supervise(ctx context.Context, id string, cont containerd.Container, task containerd.Task, healthcheck *health.Config) (containerd.ExitStatus, error) {
+	exitCh, err := task.Wait(ctx)
+	if err != nil {
+		return containerd.ExitStatus{}, err
+	}
+	if healthcheck == nil {
+		return <-exitCh, nil
+	}
+	args, err := healthcheck.Args()
+	if err != nil {
+		return containerd.ExitStatus{}, err
+	}
+
+	started := time.Now()
+	state := &health.State{Status: health.Starting}
+	if err := state.Save(id); err != nil {
+		return containerd.ExitStatus{}, err
+	}
+	ticker := time.NewTicker(healthcheck.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case st := <-exitCh:
+			return st, nil
+		case <-ticker.C:
+			// a frozen task cannot answer; the check resumes on unpause
+			if st, err := task.Status(ctx); err == nil && st.Status != containerd.Running {
+				continue
+			}
+			r := probe(ctx, cont, task, args, healthcheck.Timeout)
+			state.Record(*healthcheck, started, r)
+			if err := state.Save(id); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", id, err)
+			}
+		}
+	}
+}
+
+// restartTask replaces an exited task (nil when there is none) with a new
+// one and publishes the container's ports again. Callers hold lockTask.
+func restartTask(ctx context.Context, id string, cont containerd.Container, old containerd.Task) (containerd.Task, error) {
+	labels, err := cont.Labels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		published []ports.Mapping
+		cniClient *cni.Client
+	)
+	if spec := labels[boxylabels.Ports]; spec != "" {
+		if published, err = ports.Parse(strings.Split(spec, ",")); err != nil {
+			return nil, err
+		}
+		if cniClient, err = cni.NewClient(); err != nil {
+			return nil, fmt.Errorf("failed to initialize CNI: %v", err)
+		}
+		// release the old IP and port mappings. The namespace is gone and
+		// the old PID may belong to another process by now, so no path.
+		if err := cniClient.RemoveNetwork(ctx, id, ""); err != nil {
+			fmt.Printf("Warning: failed to cleanup network: %v\n", err)
+		}
+	}
+
+	if old != nil {
+		st, err := old.Delete(ctx, containerd.WithProcessKill)
+		if err != nil && !errdefs.IsNotFound(err) {
+			return nil, err
+		}
+		if st != nil {
+			recordExit(ctx, cont, st.ExitCode())
+		}
+	}
+	task, err := cont.NewTask(ctx, cio.NullIO)
+	if err != nil {
+		return nil, err
+	}
+	if err := task.Start(ctx); err != nil {
+		task.Delete(ctx)
+		return nil, err
+	}
+	if cniClient != nil {
+		if err := setupNetwork(ctx, cniClient, id, task.Pid(), published); err != nil {
+			task.Kill(ctx, syscall.SIGKILL)
+			return nil, err
+		}
+	}
+	return task, nil
+}
+
+// syncBuffer collects the probe's stdout and stderr, written concurrently
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// probe execs the health check command in the container's task
+func probe(ctx context.Context, cont containerd.Container, task containerd.Task, args []string, timeout time.Duration) health.Result {
+	r := health.Result{Start: time.Now(), ExitCode: -1}
+	defer func() { r.End = time.Now() }()
+
+	spec, err := cont.Spec(ctx)
+	if err != nil {
+		r.Output = err.Error()
+		return r
+	}
+	pspec := *spec.Process
+	pspec.Args = args
+	pspec.Terminal = false
+
+	out := &syncBuffer{}
+	id := fmt.Sprintf("health-%d", r.Start.UnixNano())
+	proc, err := task.Exec(ctx, id, &pspec, cio.NewCreator(cio.WithStreams(nil, out, out)))
+	if err != nil {
+		r.Output = err.Error()
+		return r
+	}
+	statusCh, err := proc.Wait(ctx)
+	if err == nil {
+		err = proc.Start(ctx)
+	}
+	if err != nil {
+		proc.Delete(ctx)
+		r.Output = err.Error()
+		return r
+	}
+
+	select {
+	case st := <-statusCh:
+		code, _, _ := st.Result()
+		r.ExitCode = int(code)
+	case <-time.After(timeout):
+		_ = proc.Kill(ctx, syscall.SIGKILL)
+		<-statusCh
+	}
+	// deleting waits for the output to be copied
+	proc.Delete(ctx)
+
+	out.mu.Lock()
+	r.Output = out.buf.String()
+	out.mu.Unlock()
+	if r.ExitCode == -1 {
+		r.Output += fmt.Sprintf("health check exceeded timeout (%s)", timeout)
+	}
+	return r
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/arnab2001/boxy/internal/client"
+	"github.com/arnab2001/boxy/internal/selector"
+	"github.com/containerd/containerd"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(
+		freezeCommand("pause", "Suspend all processes of containers (cgroup freezer)",
+			selector.Running, "paused", containerd.Task.Pause),
+		freezeCommand("unpause", "Resume the processes of paused containers",
+			selector.Paused, "unpaused", containerd.Task.Resume),
+	)
+}
+
+// freezeCommand builds boxy pause and unpause, which apply fn to the tasks
+// of containers in status want
+func freezeCommand(use, short, want, done string, fn func(containerd.Task, context.Context) error) *cobra.Command {
+	return &cobra.Command{
+		Use:   use + " <name>...",
+		Short: short,
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := client.Default()
+			c, err := client.Instance()
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+
+			return selector.Each(args, maxParallel, func(ref string) error {
+				cont, err := loadContainer(ctx, c, ref)
+				if err != nil {
+					return err
+				}
+				status, err := containerStatus(ctx, cont)
+				if err != nil {
+					return err
+				}
+				if status != want {
+					return fmt.Errorf("container is %s, not %s", status, want)
+				}
+				task, err := cont.Task(ctx, nil)
+				if err != nil {
+					return err
+				}
+				if err := fn(task, ctx); err != nil {
+					return err
+				}
+				fmt.Printf("✓ %s %s\n", done, ref)
+				return nil
+			})
+		},
+	}
+}
+
+// resumePaused thaws a paused task so that a signal just sent to it is
+// delivered
+func resumePaused(ctx context.Context, task containerd.Task) {
+	st, err := task.Status(ctx)
+	if err == nil && (st.Status == containerd.Paused || st.Status == containerd.Pausing) {
+		_ = task.Resume(ctx)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/arnab2001/boxy/internal/policy"
+	"github.com/arnab2001/boxy/internal/runopts"
+	refdocker "github.com/containerd/containerd/reference/docker"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "policy",
+		Short: "Work with the host policy (policy.yaml)",
+	}
+
+	check := &cobra.Command{
+		Use:   "check [run flags] <image> [cmd...]",
+		Short: "Dry-run boxy run arguments against the policy and list the rules that would deny",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts, err := runopts.Parse(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			pol, err := policy.Load()
+			if err != nil {
+				return err
+			}
+			named, err := refdocker.ParseDockerRef(args[0])
+			if err != nil {
+				return err
+			}
+
+			denials := pol.CheckRun(opts.PolicyRequest())
+			var denial *policy.Denial
+			if err := pol.CheckReference(named); errors.As(err, &denial) {
+				denials = append([]*policy.Denial{denial}, denials...)
+			} else if err != nil {
+				return err
+			}
+			if len(denials) == 0 {
+				fmt.Printf("✔ allowed by %s\n", policy.Path())
+				return nil
+			}
+			fmt.Printf("✖ denied by %s\n", policy.Path())
+			for _, d := range denials {
+				fmt.Printf("    %-28s %s\n", d.Rule, d.Reason)
+			}
+			return fmt.Errorf("%d rule(s) would deny this container", len(denials))
+		},
+	}
+	runopts.AddFlags(check.Flags())
+
+	cmd.AddCommand(check)
+	rootCmd.AddCommand(cmd)
+}
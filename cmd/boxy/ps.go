@@ -6,6 +6,8 @@ import (
 	"text/tabwriter"
 
 	"github.com/arnab2001/boxy/internal/client"
+	"github.com/arnab2001/boxy/internal/health"
+	"github.com/containerd/containerd"
 	"github.com/containerd/containerd/errdefs"
 	"github.com/spf13/cobra"
 )
@@ -27,7 +29,7 @@ func init() {
 			}
 
 			w := tabwriter.NewWriter(os.Stdout, 2, 8, 2, ' ', 0)
-			fmt.Fprintln(w, "NAME\tSTATE\tPID\tIMAGE")
+			fmt.Fprintln(w, "CONTAINER ID\tNAME\tSTATE\tPID\tIMAGE")
 
 			for _, cont := range containers {
 				info, _ := cont.Info(ctx)
@@ -42,6 +44,9 @@ func init() {
 					if serr == nil {
 						state = string(st.Status) // RUNNING / STOPPED / etc.
 					}
+					if h, _ := health.Load(info.ID); h != nil && st.Status == containerd.Running {
+						state += " (" + h.Status + ")"
+					}
 					if p := taskObj.Pid(); p != 0 {
 						pid = fmt.Sprint(p)
 					}
@@ -52,8 +57,8 @@ func init() {
 					return err // real error
 				}
 
-				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
-					info.ID, state, pid, info.Image)
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+					shortID(info.ID), containerName(ctx, cont), state, pid, info.Image)
 			}
 			return w.Flush()
 		},
@@ -4,11 +4,20 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/arnab2001/boxy/internal/client"
+	"github.com/arnab2001/boxy/internal/config"
+	boxylabels "github.com/arnab2001/boxy/internal/labels"
+	"github.com/arnab2001/boxy/internal/policy"
+	"github.com/arnab2001/boxy/internal/registry"
 	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/images"
 	refdocker "github.com/containerd/containerd/reference/docker"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/spf13/cobra"
 )
 
@@ -49,7 +58,7 @@ func init() {
 				}
 			}()
 
-			_, err = c.Pull(ctx, ref, containerd.WithPullUnpack)
+			_, err = pullImage(ctx, c, ref)
 			close(done)
 			if err != nil {
 				fmt.Fprintf(os.Stdout, "\r✖ failed to pull %s: %v\n", ref, err)
@@ -61,3 +70,125 @@ func init() {
 	}
 	rootCmd.AddCommand(cmd)
 }
+
+// pullImage pulls & unpacks ref through a resolver honouring boxy's
+// registry configuration (mirrors, hosts.toml, TLS settings). The image trust
+// policy is enforced before any layer is downloaded; signatures it requires
+// are fetched, verified and kept with the image.
+func pullImage(ctx context.Context, c *containerd.Client, ref string) (containerd.Image, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	pol, err := policy.Load()
+	if err != nil {
+		return nil, err
+	}
+	named, err := refdocker.ParseDockerRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	if err := pol.CheckReference(named); err != nil {
+		return nil, err
+	}
+
+	ctx, done, err := c.WithLease(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done(ctx)
+
+	resolver := registry.NewResolver(ctx, cfg)
+	var (
+		sigs     []ocispec.Descriptor
+		verified digest.Digest
+	)
+	if len(pol.SignatureRules(named.Name())) > 0 {
+		_, desc, err := resolver.Resolve(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		sigs, err = registry.FetchSignatures(ctx, resolver, named.Name(), desc.Digest, c.ContentStore())
+		if err != nil {
+			return nil, err
+		}
+		if err := pol.VerifySignatures(ctx, c.ContentStore(), named, desc.Digest, sigs); err != nil {
+			return nil, err
+		}
+		verified = desc.Digest
+	}
+
+	img, err := c.Pull(ctx, ref,
+		containerd.WithPullUnpack,
+		containerd.WithResolver(resolver),
+	)
+	if err != nil || verified == "" {
+		return img, err
+	}
+	if img.Target().Digest != verified {
+		_ = c.ImageService().Delete(ctx, img.Name())
+		return nil, fmt.Errorf("%s changed from %s to %s while pulling; pull again", ref, verified, img.Target().Digest)
+	}
+	return img, attachSignatures(ctx, c, img, sigs)
+}
+
+// ensureImage returns the local image for ref, pulling it when missing.
+// Local images are checked against the trust policy as well.
+func ensureImage(ctx context.Context, c *containerd.Client, ref string) (containerd.Image, error) {
+	img, err := c.GetImage(ctx, ref)
+	if err == nil {
+		return img, checkImagePolicy(ctx, c, img)
+	}
+	if !errdefs.IsNotFound(err) {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "⟳ pulling %s …\n", ref)
+	img, err = pullImage(ctx, c, ref)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "✔ pulled %s\n", ref)
+	return img, nil
+}
+
+// checkImagePolicy enforces the trust policy on a local image using the
+// signatures stored with it at pull time
+func checkImagePolicy(ctx context.Context, c *containerd.Client, img containerd.Image) error {
+	pol, err := policy.Load()
+	if err != nil {
+		return err
+	}
+	named, err := refdocker.ParseDockerRef(img.Name())
+	if err != nil {
+		return err
+	}
+	if err := pol.CheckReference(named); err != nil {
+		return err
+	}
+	var sigs []ocispec.Descriptor
+	for k, v := range img.Labels() {
+		if strings.HasPrefix(k, boxylabels.SignaturePrefix) {
+			sigs = append(sigs, ocispec.Descriptor{Digest: digest.Digest(v)})
+		}
+	}
+	return pol.VerifySignatures(ctx, c.ContentStore(), named, img.Target().Digest, sigs)
+}
+
+// attachSignatures records the signature manifests on the image, replacing
+// those of a previous pull
+func attachSignatures(ctx context.Context, c *containerd.Client, img containerd.Image, sigs []ocispec.Descriptor) error {
+	labels := map[string]string{}
+	for k, v := range img.Labels() {
+		if !strings.HasPrefix(k, boxylabels.SignaturePrefix) {
+			labels[k] = v
+		}
+	}
+	for i, desc := range sigs {
+		labels[fmt.Sprintf("%s%d", boxylabels.SignaturePrefix, i)] = desc.Digest.String()
+	}
+	_, err := c.ImageService().Update(ctx, images.Image{
+		Name:   img.Name(),
+		Labels: labels,
+	}, "labels")
+	return err
+}
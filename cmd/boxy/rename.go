@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/arnab2001/boxy/internal/client"
+	boxylabels "github.com/arnab2001/boxy/internal/labels"
+	"github.com/arnab2001/boxy/internal/names"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "rename <container> <new-name>",
+		Short: "Rename a container",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := names.Validate(args[1]); err != nil {
+				return err
+			}
+
+			ctx := client.Default()
+			c, err := client.Instance()
+			if err != nil {
+				return err
+			}
+			cont, err := loadContainer(ctx, c, args[0])
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+
+			old := containerName(ctx, cont)
+			if args[1] == old {
+				return fmt.Errorf("container is already named %s", old)
+			}
+			name, err := newContainerName(ctx, c, args[1])
+			if err != nil {
+				return err
+			}
+			// the network, run directory and snapshot all go by the ID, so
+			// the name label is all there is to change
+			if _, err := cont.SetLabels(ctx, map[string]string{boxylabels.Name: name}); err != nil {
+				return err
+			}
+			fmt.Printf("✓ renamed %s to %s\n", old, name)
+			return nil
+		},
+	}
+	rootCmd.AddCommand(cmd)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	boxylabels "github.com/arnab2001/boxy/internal/labels"
+	"github.com/arnab2001/boxy/internal/names"
+	"github.com/arnab2001/boxy/internal/selector"
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/errdefs"
+)
+
+// loadContainer finds a container by full ID, name or unique ID prefix
+func loadContainer(ctx context.Context, c *containerd.Client, ref string) (containerd.Container, error) {
+	cont, err := c.LoadContainer(ctx, ref)
+	if err == nil || !errdefs.IsNotFound(err) {
+		return cont, err
+	}
+	containers, err := c.Containers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	known := make([]names.Container, len(containers))
+	for i, cont := range containers {
+		known[i] = names.Container{ID: cont.ID(), Name: containerName(ctx, cont)}
+	}
+	found, err := names.Resolve(ref, known)
+	if err != nil {
+		return nil, err
+	}
+	for _, cont := range containers {
+		if cont.ID() == found.ID {
+			return cont, nil
+		}
+	}
+	return nil, fmt.Errorf("no such container: %s", ref)
+}
+
+// containerName returns the name of a container; containers created before
+// names were stored separately are named by their ID
+func containerName(ctx context.Context, cont containerd.Container) string {
+	info, err := cont.Info(ctx, containerd.WithoutRefreshedMetadata)
+	if err == nil && info.Labels[boxylabels.Name] != "" {
+		return info.Labels[boxylabels.Name]
+	}
+	return cont.ID()
+}
+
+// shortID abbreviates a container ID for listings
+func shortID(id string) string {
+	if len(id) > names.ShortID {
+		return id[:names.ShortID]
+	}
+	return id
+}
+
+// newContainerName validates --name, or generates a name when it is empty,
+// making sure no container already goes by it
+func newContainerName(ctx context.Context, c *containerd.Client, name string) (string, error) {
+	containers, err := c.Containers(ctx)
+	if err != nil {
+		return "", err
+	}
+	taken := map[string]bool{}
+	for _, cont := range containers {
+		taken[cont.ID()] = true
+		taken[containerName(ctx, cont)] = true
+	}
+	if name != "" {
+		if err := names.Validate(name); err != nil {
+			return "", err
+		}
+		if taken[name] {
+			return "", fmt.Errorf("the name %q is already in use by another container", name)
+		}
+		return name, nil
+	}
+	for retry := 0; ; retry++ {
+		if name = names.Generate(retry); !taken[name] {
+			return name, nil
+		}
+	}
+}
+
+// maxParallel bounds how many containers stop, rm and prune handle at once
+const maxParallel = 8
+
+// containerStatus returns the docker-style status of a container
+func containerStatus(ctx context.Context, cont containerd.Container) (string, error) {
+	task, err := cont.Task(ctx, nil)
+	if errdefs.IsNotFound(err) {
+		return selector.Created, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	st, err := task.Status(ctx)
+	if err != nil {
+		return "", err
+	}
+	switch st.Status {
+	case containerd.Running:
+		return selector.Running, nil
+	case containerd.Paused, containerd.Pausing:
+		return selector.Paused, nil
+	case containerd.Created:
+		return selector.Created, nil
+	}
+	return selector.Exited, nil
+}
+
+// selectContainers returns the containers given on the command line or,
+// with --filter flags, the names of all containers matching them
+func selectContainers(ctx context.Context, c *containerd.Client, refs, filterFlags []string) ([]string, error) {
+	if len(filterFlags) == 0 {
+		if len(refs) == 0 {
+			return nil, fmt.Errorf("requires at least one container or --filter")
+		}
+		return refs, nil
+	}
+	if len(refs) > 0 {
+		return nil, fmt.Errorf("give containers or --filter, not both")
+	}
+	filter, err := selector.ParseFilters(filterFlags)
+	if err != nil {
+		return nil, err
+	}
+	return matchingContainers(ctx, c, filter)
+}
+
+// matchingContainers returns the names of the containers passing filter
+func matchingContainers(ctx context.Context, c *containerd.Client, filter selector.Filter) ([]string, error) {
+	containers, err := c.Containers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var selected []string
+	for _, cont := range containers {
+		info, err := cont.Info(ctx, containerd.WithoutRefreshedMetadata)
+		if err != nil {
+			return nil, err
+		}
+		status, err := containerStatus(ctx, cont)
+		if err != nil {
+			return nil, err
+		}
+		name := containerName(ctx, cont)
+		if filter.Match(selector.Container{ID: info.ID, Name: name, Image: info.Image, Status: status, Labels: info.Labels}) {
+			selected = append(selected, name)
+		}
+	}
+	return selected, nil
+}
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"syscall"
 
 	"github.com/arnab2001/boxy/internal/client"
 	"github.com/arnab2001/boxy/internal/cni"
+	"github.com/arnab2001/boxy/internal/config"
+	boxylabels "github.com/arnab2001/boxy/internal/labels"
+	"github.com/arnab2001/boxy/internal/selector"
 	"github.com/containerd/containerd"
 	"github.com/containerd/containerd/errdefs"
 	"github.com/spf13/cobra"
@@ -13,59 +19,102 @@ import (
 
 func init() {
 	cmd := &cobra.Command{
-		Use:   "rm <name>",
-		Short: "Kill & remove a container and its snapshot",
-		Args:  cobra.ExactArgs(1),
-		RunE: func(_ *cobra.Command, args []string) error {
+		Use:   "rm [-f] <name>... | --filter key=value",
+		Short: "Remove containers and their snapshots",
+		Args:  cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			force, _ := cmd.Flags().GetBool("force")
+			filters, _ := cmd.Flags().GetStringArray("filter")
+
 			ctx := client.Default()
 			c, err := client.Instance()
 			if err != nil {
 				return err
 			}
-
-			cont, err := c.LoadContainer(ctx, args[0])
+			refs, err := selectContainers(ctx, c, args, filters)
 			if err != nil {
 				return err
 			}
+			cmd.SilenceUsage = true
 
-			taskObj, err := cont.Task(ctx, nil)
-			if err == nil {
-				// Get PID for CNI cleanup before killing
-				pid := taskObj.Pid()
-				netnsPath := fmt.Sprintf("/proc/%d/ns/net", pid)
-
-				// Clean up CNI networking before killing (while netns is still available)
-				if cniClient, err := cni.NewClient(); err == nil {
-					if err := cniClient.RemoveNetwork(ctx, args[0], netnsPath); err != nil {
-						fmt.Printf("Warning: failed to cleanup network: %v\n", err)
+			return selector.Each(refs, maxParallel, func(ref string) error {
+				cont, err := loadContainer(ctx, c, ref)
+				if err != nil {
+					return err
+				}
+				if !force {
+					status, err := containerStatus(ctx, cont)
+					if err != nil {
+						return err
+					}
+					if status == selector.Running || status == selector.Paused {
+						return fmt.Errorf("container is %s (stop it first or use -f)", status)
 					}
 				}
-
-				// 1) ask the task to die
-				if err := taskObj.Kill(ctx, syscall.SIGKILL); err != nil &&
-					!errdefs.IsNotFound(err) {
+				if _, err := cont.SetLabels(ctx, map[string]string{boxylabels.Stopped: "true"}); err != nil {
 					return err
 				}
-
-				// 2) wait until shim reports exit, idk how shim actually works
-				exitCh, _ := taskObj.Wait(ctx)
-				<-exitCh
-
-				// 3) delete task / shim
-				if _, err := taskObj.Delete(ctx); err != nil &&
-					!errdefs.IsNotFound(err) {
+				if err := removeContainer(ctx, cont); err != nil {
 					return err
 				}
-			} else if !errdefs.IsNotFound(err) {
-				return err // i think this will look up error
-			}
-
-			if err := cont.Delete(ctx, containerd.WithSnapshotCleanup); err != nil {
-				return err
-			}
-			fmt.Printf("✓ removed %s\n", args[0])
-			return nil
+				fmt.Printf("✓ removed %s\n", ref)
+				return nil
+			})
 		},
 	}
+	cmd.Flags().BoolP("force", "f", false, "Kill running containers before removing them")
+	// image VOLUMEs live in the container's snapshot, which rm always
+	// deletes; boxy has no anonymous volumes to keep or remove
+	cmd.Flags().BoolP("volumes", "v", false, "Accepted for docker compatibility; the snapshot is always removed")
+	cmd.Flags().StringArray("filter", nil, "Remove the containers matching a filter (label=K[=V], status=, ancestor=)")
 	rootCmd.AddCommand(cmd)
 }
+
+// removeContainer kills the container's task, releases its network and
+// deletes the container with its snapshot and run directory. A container
+// that is already gone is not an error (the monitor of a --rm container may
+// be removing it at the same time).
+func removeContainer(ctx context.Context, cont containerd.Container) error {
+	id := cont.ID()
+	taskObj, err := cont.Task(ctx, nil)
+	if err == nil {
+		// Get PID for CNI cleanup before killing
+		pid := taskObj.Pid()
+		netnsPath := fmt.Sprintf("/proc/%d/ns/net", pid)
+
+		// Clean up CNI networking before killing (while netns is still available)
+		if cniClient, err := cni.NewClient(); err == nil {
+			if err := cniClient.RemoveNetwork(ctx, id, netnsPath); err != nil {
+				fmt.Printf("Warning: failed to cleanup network: %v\n", err)
+			}
+		}
+
+		// 1) ask the task to die
+		if err := taskObj.Kill(ctx, syscall.SIGKILL); err != nil &&
+			!errdefs.IsNotFound(err) {
+			return err
+		}
+		resumePaused(ctx, taskObj)
+
+		// 2) wait until shim reports exit, idk how shim actually works
+		if exitCh, err := taskObj.Wait(ctx); err == nil {
+			<-exitCh
+		}
+
+		// 3) delete task / shim
+		if _, err := taskObj.Delete(ctx); err != nil &&
+			!errdefs.IsNotFound(err) {
+			return err
+		}
+	} else if !errdefs.IsNotFound(err) {
+		return err // i think this will look up error
+	}
+
+	if err := cont.Delete(ctx, containerd.WithSnapshotCleanup); err != nil &&
+		!errdefs.IsNotFound(err) {
+		return err
+	}
+	// health state and monitor log
+	_ = os.RemoveAll(filepath.Join(config.RunDir(), id))
+	return nil
+}
@@ -1,10 +1,23 @@
 package main
 
-import "github.com/spf13/cobra"
+import (
+	"fmt"
+	"os"
+
+	"github.com/arnab2001/boxy/internal/exitcode"
+	"github.com/spf13/cobra"
+)
 
 var rootCmd = &cobra.Command{
-	Use:   "boxy",
-	Short: "Container runtime with Docker-style port publishing powered by containerd",
+	Use:           "boxy",
+	Short:         "Container runtime with Docker-style port publishing powered by containerd",
+	SilenceErrors: true, // printed by Execute, which knows which errors are silent
 }
 
-func Execute() { _ = rootCmd.Execute() }
+func Execute() {
+	err := rootCmd.Execute()
+	if err != nil && err.Error() != "" {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+	}
+	os.Exit(exitcode.Code(err))
+}
@@ -1,193 +1,238 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
 
+	"github.com/arnab2001/boxy/internal/attach"
 	"github.com/arnab2001/boxy/internal/client"
 	"github.com/arnab2001/boxy/internal/cni"
+	"github.com/arnab2001/boxy/internal/config"
+	"github.com/arnab2001/boxy/internal/exitcode"
+	boxylabels "github.com/arnab2001/boxy/internal/labels"
+	"github.com/arnab2001/boxy/internal/ports"
+	"github.com/arnab2001/boxy/internal/runopts"
+	"github.com/arnab2001/boxy/internal/userns"
 	console "github.com/containerd/console"
 	"github.com/containerd/containerd"
-	"github.com/containerd/containerd/cio"
-	"github.com/containerd/containerd/errdefs"
-	"github.com/containerd/containerd/oci"
-	refdocker "github.com/containerd/containerd/reference/docker"
 	"github.com/spf13/cobra"
 )
 
-var portFlags []string
-
 func init() {
 	cmd := &cobra.Command{
-		Use:   "run --name <ctr> <image> [cmd...]",
-		Short: "Run a container (interactive by default, -d for detached)",
+		Use:   "run [--name <ctr>] <image> [cmd...]",
+		Short: "Run a container (attached by default, -d for detached)",
 		Args:  cobra.MinimumNArgs(1),
 		RunE:  runE,
 	}
-	cmd.Flags().String("name", "", "container name (required)")
-	cmd.Flags().BoolP("detach", "d", false, "run in background (no TTY)")
-	cmd.Flags().StringSliceVarP(&portFlags, "publish", "p", nil, "HOST:CONT[,PROTO]")
-	cmd.MarkFlagRequired("name")
+	runopts.AddFlags(cmd.Flags())
+	cmd.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
+		return exitcode.Wrap(err)
+	})
 	rootCmd.AddCommand(cmd)
 }
 
+// runE exits like docker run: with the container's exit code in the
+// foreground, 125 when boxy fails and 126/127 when the command cannot run
 func runE(cmd *cobra.Command, args []string) error {
-	name, _ := cmd.Flags().GetString("name")
-	detach, _ := cmd.Flags().GetBool("detach")
-
-	// Parse port flags
-	portMappings, err := ParsePorts(portFlags)
+	opts, err := runopts.Parse(cmd.Flags())
 	if err != nil {
-		return err
+		return exitcode.Wrap(err)
 	}
-	if len(portMappings) > 0 {
-		fmt.Printf("Parsed port mappings: %+v\n", portMappings)
-		
-		// Check for port conflicts
-		if err := checkPortConflicts(portMappings); err != nil {
-			return err
-		}
+	if opts.Restart.Enabled() && !opts.Detach {
+		return exitcode.Wrap(fmt.Errorf("--restart %s requires -d", opts.Restart))
 	}
+	cmd.SilenceUsage = true // from here on errors are not about the flags
+	return exitcode.Wrap(run(opts, args))
+}
 
-	// Initialize CNI client if port mappings are specified
-	var cniClient *cni.Client
-	if len(portMappings) > 0 {
-		cniClient, err = cni.NewClient()
-		if err != nil {
-			return fmt.Errorf("failed to initialize CNI: %v", err)
+// run creates the container and starts it, attached unless -d
+func run(opts *runopts.Options, args []string) error {
+	detach := opts.Detach
+	var mode attach.Mode
+	if !detach {
+		var err error
+		if mode, err = attach.Resolve(opts.Stdio, isTerminal(os.Stdin), isTerminal(os.Stdout)); err != nil {
+			return err
 		}
 	}
 
-	// ── normalise reference ────────────────────────────────────
-	named, err := refdocker.ParseDockerRef(args[0])
-	if err != nil {
-		return err
-	}
-	ref := named.String()
-
 	ctx := client.Default()
 	c, err := client.Instance()
 	if err != nil {
 		return err
 	}
 
-	// ── ensure image exists (auto-pull) ────────────────────────
-	img, err := c.GetImage(ctx, ref)
+	// Ctrl-C while the image is pulled or the container is created cancels
+	// and removes what was created; once the task runs it is forwarded to
+	// the task instead, so the container exits (and is removed with --rm)
+	// cleanly
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigCh)
+	createCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	created := make(chan struct{})
+	watched := make(chan struct{})
+	go func() {
+		defer close(watched)
+		select {
+		case <-sigCh:
+			cancel()
+		case <-created:
+		}
+	}()
+
+	var task *startedTask
+	cont, err := createContainer(createCtx, c, opts, args, mode.TTY)
+	if err == nil {
+		task, err = startContainer(createCtx, cont, startOptions{
+			attach:     !detach,
+			stdin:      mode.Stdin,
+			detachKeys: opts.DetachKeys,
+		})
+	}
+	close(created)
+	<-watched
+	if createCtx.Err() != nil {
+		err = fmt.Errorf("interrupted")
+	}
+	name := opts.Name
 	if err != nil {
-		if errdefs.IsNotFound(err) {
-			fmt.Printf("⟳ pulling %s …\n", ref)
-			img, err = c.Pull(ctx, ref, containerd.WithPullUnpack)
-			if err != nil {
-				return err
+		// unlike create + start, a failed run leaves no container behind
+		if task != nil {
+			task.release()
+		}
+		if cont != nil {
+			if err := removeContainer(ctx, cont); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to remove %s: %v\n", name, err)
 			}
-			fmt.Printf("✔ pulled %s\n", ref)
-		} else {
-			return err
 		}
+		return err
 	}
 
-	// ── build OCI spec ─────────────────────────────────────────
-	specOpts := []oci.SpecOpts{oci.WithImageConfig(img)}
+	// attached containers own stdout; boxy's own messages go to stderr
+	status := os.Stdout
 	if !detach {
-		specOpts = append(specOpts, oci.WithTTY)
-	}
-	if len(args) > 1 {
-		specOpts = append(specOpts, oci.WithProcessArgs(args[1:]...))
+		status = os.Stderr
 	}
+	fmt.Fprintf(status, "▶︎ started %s (PID %d)\n", name, task.Pid())
 
-	cont, err := c.NewContainer(ctx, name,
-		containerd.WithNewSnapshot(name+"-snap", img),
-		containerd.WithNewSpec(specOpts...),
-	)
-	if err != nil {
-		return err
-	}
-
-	// choose IO mode
-	var creator cio.Creator
 	if detach {
-		creator = cio.NullIO
-	} else {
-		creator = cio.NewCreator(cio.WithStreams(os.Stdin, os.Stdout, os.Stderr), cio.WithTerminal)
+		// background mode returns immediately
+		return nil
 	}
+	defer task.release()
 
-	task, err := cont.NewTask(ctx, creator)
+	code, exited, err := streamTask(ctx, task, mode.TTY, mode.Stdin, task.exitCh, sigCh, task.detached)
 	if err != nil {
 		return err
 	}
-	if err := task.Start(ctx); err != nil {
-		return err
+	if !exited {
+		fmt.Fprintf(status, "\n⏏ detached from %s (boxy attach %s to reconnect)\n", name, name)
+		if opts.AutoRemove {
+			// hand --rm over to the monitor, which attached tasks always have
+			if _, err := cont.SetLabels(ctx, map[string]string{boxylabels.AutoRemove: "true"}); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
-
-	fmt.Printf("▶︎ started %s (PID %d)\n", name, task.Pid())
-
-	// ── CNI network setup ──────────────────────────────────────
-	if cniClient != nil && len(portMappings) > 0 {
-		netnsPath := fmt.Sprintf("/proc/%d/ns/net", task.Pid())
-		
-		// Convert port mappings to CNI format
-		var cniPortMappings []cni.PortMapping
-		for _, pm := range portMappings {
-			cniPortMappings = append(cniPortMappings, cni.PortMapping{
-				HostPort:      pm.HostPort,
-				ContainerPort: pm.ContainerPort,
-				Protocol:      pm.Protocol,
-				HostIP:        pm.HostIP,
-			})
+	if mode.TTY {
+		fmt.Fprintln(status)
+	}
+	fmt.Fprintf(status, "■ %s exited with code %d\n", name, code)
+	if opts.AutoRemove {
+		if err := removeContainer(ctx, cont); err != nil {
+			return err
 		}
+	}
+	if code != 0 {
+		return &exitcode.Error{Code: int(code)}
+	}
+	return nil
+}
 
-		result, err := cniClient.SetupNetwork(ctx, name, netnsPath, cniPortMappings)
-		if err != nil {
-			// Clean up task if network setup fails
-			task.Kill(ctx, syscall.SIGKILL)
-			task.Delete(ctx)
-			cont.Delete(ctx, containerd.WithSnapshotCleanup)
-			return fmt.Errorf("failed to setup network: %v", err)
+// isTerminal reports whether f is a terminal
+func isTerminal(f *os.File) bool {
+	_, err := console.ConsoleFromFile(f)
+	return err == nil
+}
+
+// currentConsole returns the terminal of stdin or stdout (nil when both are
+// redirected)
+func currentConsole() console.Console {
+	for _, f := range []*os.File{os.Stdin, os.Stdout} {
+		if cons, err := console.ConsoleFromFile(f); err == nil {
+			return cons
 		}
+	}
+	return nil
+}
+
+// setupNetwork attaches the task's network namespace to the CNI bridge and
+// publishes its ports
+func setupNetwork(ctx context.Context, cniClient *cni.Client, name string, pid uint32, portMappings []ports.Mapping) error {
+	netnsPath := fmt.Sprintf("/proc/%d/ns/net", pid)
 
-		fmt.Printf("✔ network configured with IP: %s\n", result.Interfaces["eth0"].IPConfigs[0].IP.String())
+	// Convert port mappings to CNI format
+	var cniPortMappings []cni.PortMapping
+	for _, pm := range portMappings {
+		cniPortMappings = append(cniPortMappings, cni.PortMapping{
+			HostPort:      pm.HostPort,
+			ContainerPort: pm.ContainerPort,
+			Protocol:      pm.Protocol,
+			HostIP:        pm.HostIP,
+		})
 	}
 
-	if detach {
-		// background mode returns immediately
-		return nil
+	result, err := cniClient.SetupNetwork(ctx, name, netnsPath, cniPortMappings)
+	if err != nil {
+		return fmt.Errorf("failed to setup network: %v", err)
 	}
 
-	// ── interactive TTY: raw mode + resize forwarding ──────────
-	if cons := console.Current(); cons != nil {
-		if err := cons.SetRaw(); err != nil {
-			return err
-		}
-		defer cons.Reset()
+	fmt.Printf("✔ network configured with IP: %s\n", result.Interfaces["eth0"].IPConfigs[0].IP.String())
+	return nil
+}
 
-		if sz, err := cons.Size(); err == nil { // initial resize
-			_ = task.Resize(ctx, uint32(sz.Width), uint32(sz.Height))
+// usernsMapping allocates the id mapping for a new container, avoiding the
+// ranges already held by other containers; nil means the host namespace
+func usernsMapping(ctx context.Context, c *containerd.Client, mode string, privileged bool) (*userns.Mapping, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	if privileged {
+		if mode != "" && mode != userns.Host {
+			return nil, fmt.Errorf("--privileged requires --userns=host")
 		}
-		go func() { // later resizes
-			winsz := make(chan os.Signal, 1)
-			signal.Notify(winsz, syscall.SIGWINCH)
-			for range winsz {
-				if s, err := cons.Size(); err == nil {
-					_ = task.Resize(ctx, uint32(s.Width), uint32(s.Height))
-				}
-			}
-		}()
+		return nil, nil // privileged containers always share the host namespace
+	}
+	mode = userns.Effective(mode, cfg.UserNS)
+	if mode == userns.Host {
+		return nil, nil
 	}
 
-	// forward Ctrl-C / TERM into the container
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	go func() {
-		s := <-sigCh
-		_ = task.Kill(ctx, s.(syscall.Signal))
-	}()
-
-	// wait for container exit
-	exitCh, _ := task.Wait(ctx)
-	st := <-exitCh
-	code, _, _ := st.Result()
-	fmt.Printf("\n■ %s exited with code %d\n", name, code)
-	return nil
+	containers, err := c.Containers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var used []userns.Mapping
+	for _, cont := range containers {
+		info, err := cont.Info(ctx, containerd.WithoutRefreshedMetadata)
+		if err != nil {
+			continue
+		}
+		m, err := userns.FromLabels(info.Labels[boxylabels.UIDMap], info.Labels[boxylabels.GIDMap])
+		if err != nil {
+			return nil, fmt.Errorf("container %s: %v", info.ID, err)
+		}
+		if m != nil {
+			used = append(used, *m)
+		}
+	}
+	return userns.Setup(mode, cfg.UserNS, used)
 }
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/arnab2001/boxy/internal/seccomp"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "seccomp",
+		Short: "Work with seccomp profiles",
+	}
+
+	validate := &cobra.Command{
+		Use:   "validate <profile.json>...",
+		Short: "Check seccomp profiles (Docker JSON format) for errors",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			failed := 0
+			for _, path := range args {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					fmt.Printf("✖ %s: %v\n", path, err)
+					failed++
+					continue
+				}
+				errs := seccomp.Validate(data)
+				if len(errs) == 0 {
+					fmt.Printf("✔ %s is valid\n", path)
+					continue
+				}
+				failed++
+				fmt.Printf("✖ %s: %d error(s)\n", path, len(errs))
+				for _, e := range errs {
+					fmt.Printf("    %v\n", e)
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d profile(s) invalid", failed, len(args))
+			}
+			return nil
+		},
+	}
+
+	def := &cobra.Command{
+		Use:   "default",
+		Short: "Print the built-in default seccomp profile",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			_, err := os.Stdout.Write(seccomp.DefaultJSON())
+			return err
+		},
+	}
+
+	cmd.AddCommand(validate, def)
+	rootCmd.AddCommand(cmd)
+}
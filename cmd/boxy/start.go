@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/arnab2001/boxy/internal/attach"
+	"github.com/arnab2001/boxy/internal/client"
+	"github.com/arnab2001/boxy/internal/cni"
+	"github.com/arnab2001/boxy/internal/config"
+	"github.com/arnab2001/boxy/internal/exitcode"
+	boxylabels "github.com/arnab2001/boxy/internal/labels"
+	"github.com/arnab2001/boxy/internal/ports"
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/cio"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "start [-a [-i]] <name>",
+		Short: "Start a created or stopped container",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			attachFlag, _ := cmd.Flags().GetBool("attach")
+			interactive, _ := cmd.Flags().GetBool("interactive")
+			if interactive && !attachFlag {
+				return fmt.Errorf("-i requires -a")
+			}
+			keysFlag, _ := cmd.Flags().GetString("detach-keys")
+			keys, err := attach.ParseKeys(keysFlag)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+
+			ctx := client.Default()
+			c, err := client.Instance()
+			if err != nil {
+				return err
+			}
+			cont, err := loadContainer(ctx, c, args[0])
+			if err != nil {
+				return err
+			}
+			if !attachFlag {
+				task, err := startContainer(ctx, cont, startOptions{})
+				if err != nil {
+					return err
+				}
+				fmt.Printf("▶︎ started %s (PID %d)\n", containerName(ctx, cont), task.Pid())
+				return nil
+			}
+
+			sigCh := make(chan os.Signal, 1)
+			signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+			defer signal.Stop(sigCh)
+
+			task, err := startContainer(ctx, cont, startOptions{attach: true, stdin: interactive, detachKeys: keys})
+			if err != nil {
+				return err
+			}
+			defer task.release()
+			code, exited, err := streamTask(ctx, task, task.tty, interactive, task.exitCh, sigCh, task.detached)
+			if err != nil {
+				return err
+			}
+			if !exited {
+				fmt.Fprintf(os.Stderr, "\n⏏ detached from %s\n", containerName(ctx, cont))
+				return nil
+			}
+			if code != 0 {
+				return &exitcode.Error{Code: int(code)}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolP("attach", "a", false, "attach stdout/stderr and wait for the container to exit")
+	cmd.Flags().BoolP("interactive", "i", false, "attach stdin as well (with -a)")
+	cmd.Flags().String("detach-keys", attach.DefaultDetachKeys, "key sequence that detaches from a TTY container (empty disables)")
+	rootCmd.AddCommand(cmd)
+}
+
+// startOptions say how startContainer connects the new task's stdio
+type startOptions struct {
+	attach     bool // stream stdout/stderr to boxy's
+	stdin      bool // with attach: pass boxy's stdin too
+	detachKeys []byte
+}
+
+// startedTask is a started task and what its terminal side needs
+type startedTask struct {
+	containerd.Task
+	tty      bool
+	exitCh   <-chan containerd.ExitStatus
+	detached <-chan struct{} // closed when the detach keys are typed
+	release  func()          // lets the monitor drain the fifos again
+}
+
+// startContainer is the second half of boxy run: it starts a new task for
+// a created (or stopped) container, publishes its ports and starts its
+// monitor, all from what createContainer stored
+func startContainer(ctx context.Context, cont containerd.Container, o startOptions) (*startedTask, error) {
+	id := cont.ID()
+	labels, err := cont.Labels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := cont.Spec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tty := spec.Process != nil && spec.Process.Terminal
+	if tty && o.stdin && !isTerminal(os.Stdin) {
+		return nil, fmt.Errorf("the input device is not a TTY (drop -i to start without stdin)")
+	}
+
+	var (
+		published []ports.Mapping
+		cniClient *cni.Client
+	)
+	if s := labels[boxylabels.Ports]; s != "" {
+		if published, err = ports.Parse(strings.Split(s, ",")); err != nil {
+			return nil, err
+		}
+	}
+
+	// a task left by an earlier start is replaced, unless a monitor
+	// restarts it first
+	unlock, err := lockTask(id)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if old, err := cont.Task(ctx, nil); err == nil {
+		st, err := old.Status(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if st.Status != containerd.Stopped {
+			return nil, fmt.Errorf("%s is already %s", containerName(ctx, cont), strings.ToLower(string(st.Status)))
+		}
+		if _, err := old.Delete(ctx); err != nil && !errdefs.IsNotFound(err) {
+			return nil, err
+		}
+		recordExit(ctx, cont, st.ExitStatus)
+	} else if !errdefs.IsNotFound(err) {
+		return nil, err
+	}
+
+	if len(published) > 0 {
+		// Check for port conflicts
+		if err := checkPortConflicts(published); err != nil {
+			return nil, err
+		}
+		if cniClient, err = cni.NewClient(); err != nil {
+			return nil, fmt.Errorf("failed to initialize CNI: %v", err)
+		}
+		// release what a previous task may have left behind
+		_ = cniClient.RemoveNetwork(ctx, id, "")
+	}
+	// starting again undoes boxy stop for the restart policy
+	if labels[boxylabels.Stopped] != "" {
+		if _, err := cont.SetLabels(ctx, map[string]string{boxylabels.Stopped: ""}); err != nil {
+			return nil, err
+		}
+	}
+
+	// choose IO mode: attached tasks get fifos in their run directory,
+	// which the shim keeps open for boxy attach after detaching. Without a
+	// TTY stdout and stderr stay separate and the end of stdin is forwarded
+	// once the task runs.
+	var (
+		creator  cio.Creator
+		stdin    *attach.StdinCloser
+		detached <-chan struct{}
+	)
+	dir := filepath.Join(config.RunDir(), id)
+	fifoDir := cio.WithFIFODir(dir)
+	switch {
+	case !o.attach && tty:
+		creator = detachedTTY(dir)
+	case !o.attach:
+		creator = cio.NullIO
+	case tty:
+		var in io.Reader
+		if o.stdin {
+			d := attach.NewDetachReader(os.Stdin, o.detachKeys)
+			in, detached = d, d.Detached()
+		}
+		creator = cio.NewCreator(cio.WithStreams(in, os.Stdout, os.Stderr), cio.WithTerminal, fifoDir)
+	default:
+		var in io.Reader
+		if o.stdin {
+			stdin = attach.NewStdinCloser(os.Stdin)
+			in = stdin
+		}
+		creator = cio.NewCreator(cio.WithStreams(in, os.Stdout, os.Stderr), fifoDir)
+	}
+
+	// while boxy reads the fifos the monitor does not drain them
+	release := func() {}
+	if o.attach {
+		if release, err = attach.Hold(dir); err != nil {
+			return nil, err
+		}
+	}
+	started := false
+	defer func() {
+		if !started {
+			release()
+		}
+	}()
+
+	if err := resetHealth(id, labels); err != nil {
+		return nil, err
+	}
+
+	// a missing or non-executable command fails here with 127/126
+	task, err := cont.NewTask(ctx, creator)
+	if err != nil {
+		return nil, exitcode.FromStart(err)
+	}
+	exitCh, err := task.Wait(ctx)
+	if err != nil {
+		task.Delete(ctx)
+		return nil, err
+	}
+	if err := task.Start(ctx); err != nil {
+		task.Delete(ctx)
+		return nil, exitcode.FromStart(err)
+	}
+	if stdin != nil {
+		stdin.SetClose(func() { _ = task.CloseIO(ctx, containerd.WithStdinCloser) })
+	}
+
+	// ── CNI network setup ──────────────────────────────────────
+	if cniClient != nil {
+		if err := setupNetwork(ctx, cniClient, id, task.Pid(), published); err != nil {
+			// Clean up task if network setup fails
+			task.Kill(ctx, syscall.SIGKILL)
+			task.Delete(ctx, containerd.WithProcessKill)
+			return nil, err
+		}
+	}
+
+	// the monitor also drains the fifos of a task nobody is attached to
+	fifos := o.attach || tty
+	if fifos || labels[boxylabels.Healthcheck] != "" || labels[boxylabels.RestartPolicy] != "" || labels[boxylabels.AutoRemove] == "true" {
+		if err := startMonitor(id); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: health checks, restarts, --rm and output draining disabled: %v\n", err)
+		}
+	}
+	started = true
+	return &startedTask{Task: task, tty: tty, exitCh: exitCh, detached: detached, release: release}, nil
+}
+
+// detachedTTY creates the fifos of a TTY container started in the
+// background without copying them, so boxy attach can connect later
+func detachedTTY(dir string) cio.Creator {
+	return func(id string) (cio.IO, error) {
+		fifos, err := cio.NewFIFOSetInDir(dir, id, true)
+		if err != nil {
+			return nil, err
+		}
+		return &fifoIO{config: fifos.Config}, nil
+	}
+}
+
+// fifoIO is a task IO nobody copies yet
+type fifoIO struct {
+	config cio.Config
+}
+
+func (f *fifoIO) Config() cio.Config { return f.config }
+func (f *fifoIO) Cancel()            {}
+func (f *fifoIO) Wait()              {}
+func (f *fifoIO) Close() error       { return nil }
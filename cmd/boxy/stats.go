@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/arnab2001/boxy/internal/client"
+	"github.com/arnab2001/boxy/internal/stats"
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/spf13/cobra"
+)
+
+// statsInterval is how often boxy stats samples and redraws
+const statsInterval = time.Second
+
+// statsEntry is one line of `boxy stats --format json`
+type statsEntry struct {
+	Name       string  `json:"name"`
+	CPUPercent float64 `json:"cpuPercent"`
+	stats.Sample
+}
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "stats [name...]",
+		Short: "Live CPU, memory, PID and I/O usage of running containers",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			noStream, _ := cmd.Flags().GetBool("no-stream")
+			format, _ := cmd.Flags().GetString("format")
+			if format != "" && format != "json" {
+				return fmt.Errorf("unsupported --format %q (only json)", format)
+			}
+
+			ctx := client.Default()
+			c, err := client.Instance()
+			if err != nil {
+				return err
+			}
+
+			prev := map[string]stats.Sample{}
+			for first := true; ; first = false {
+				entries, err := sampleStats(ctx, c, args, prev)
+				if err != nil {
+					return err
+				}
+				// CPU % needs two samples, so the first round is not shown
+				if !first {
+					if err := printStats(entries, format, !noStream); err != nil {
+						return err
+					}
+					if noStream {
+						return nil
+					}
+				}
+				time.Sleep(statsInterval)
+			}
+		},
+	}
+	cmd.Flags().Bool("no-stream", false, "print a single sample and exit")
+	cmd.Flags().String("format", "", "output format (json: one object per container and sample)")
+	rootCmd.AddCommand(cmd)
+}
+
+// sampleStats reads the metrics of the named containers (every running one
+// when names is empty) and updates prev with the new samples
+func sampleStats(ctx context.Context, c *containerd.Client, names []string, prev map[string]stats.Sample) ([]statsEntry, error) {
+	var containers []containerd.Container
+	if len(names) == 0 {
+		all, err := c.Containers(ctx)
+		if err != nil {
+			return nil, err
+		}
+		containers = all
+	} else {
+		for _, name := range names {
+			cont, err := loadContainer(ctx, c, name)
+			if err != nil {
+				return nil, fmt.Errorf("container %s: %v", name, err)
+			}
+			containers = append(containers, cont)
+		}
+	}
+
+	targets := make([]stats.Target, 0, len(containers))
+	tasks := map[string]containerd.Task{}
+	for _, cont := range containers {
+		t := stats.Target{ID: cont.ID(), Name: containerName(ctx, cont)}
+		task, err := cont.Task(ctx, nil)
+		if err == nil {
+			st, err := task.Status(ctx)
+			if err != nil {
+				return nil, err
+			}
+			t.Status = st.Status
+			tasks[t.ID] = task
+		} else if !errdefs.IsNotFound(err) {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	targets, err := stats.Sampled(targets, len(names) > 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []statsEntry
+	for _, t := range targets {
+		task := tasks[t.ID]
+		metric, err := task.Metrics(ctx)
+		if err != nil {
+			if len(names) == 0 {
+				continue // exited since its status was read
+			}
+			return nil, fmt.Errorf("container %s: %v", t.Name, err)
+		}
+		s, err := stats.Decode(metric)
+		if err != nil {
+			return nil, fmt.Errorf("container %s: %v", t.Name, err)
+		}
+		_ = s.ReadNetDev(task.Pid()) // the task may have just exited
+
+		e := statsEntry{Name: t.Name, Sample: s}
+		if p, ok := prev[t.ID]; ok {
+			e.CPUPercent = stats.CPUPercent(p, s)
+		}
+		prev[t.ID] = s
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// printStats writes one round of samples as a table (redrawing the screen
+// when clear is set) or as JSON lines
+func printStats(entries []statsEntry, format string, clear bool) error {
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		for _, e := range entries {
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if clear {
+		fmt.Print("\033[H\033[2J")
+	}
+	host := stats.HostMemory()
+	w := tabwriter.NewWriter(os.Stdout, 2, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCPU %\tMEM USAGE / LIMIT\tMEM %\tNET I/O\tBLOCK I/O\tPIDS")
+	for _, e := range entries {
+		limit := e.Limit
+		if limit == 0 {
+			limit = host
+		}
+		memPercent := 0.0
+		if limit > 0 {
+			memPercent = float64(e.Memory) / float64(limit) * 100
+		}
+		fmt.Fprintf(w, "%s\t%.2f%%\t%s / %s\t%.2f%%\t%s / %s\t%s / %s\t%d\n",
+			e.Name, e.CPUPercent,
+			stats.Bytes(e.Memory), stats.Bytes(limit), memPercent,
+			stats.Bytes(e.NetRx), stats.Bytes(e.NetTx),
+			stats.Bytes(e.BlkRead), stats.Bytes(e.BlkWrite),
+			e.Pids)
+	}
+	return w.Flush()
+}
@@ -1,85 +1,130 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"syscall"
 	"time"
 
 	"github.com/arnab2001/boxy/internal/client"
 	"github.com/arnab2001/boxy/internal/cni"
+	boxylabels "github.com/arnab2001/boxy/internal/labels"
+	"github.com/arnab2001/boxy/internal/selector"
+	"github.com/arnab2001/boxy/internal/signals"
+	"github.com/containerd/containerd"
 	"github.com/containerd/containerd/errdefs"
 	"github.com/spf13/cobra"
 )
 
-const defaultTimeout = 10 * time.Second // like docker
-
 func init() {
 	cmd := &cobra.Command{
-		Use:   "stop <name> [timeout]",
-		Short: "Gracefully stop a running container",
-		Args:  cobra.RangeArgs(1, 2),
-		RunE: func(_ *cobra.Command, args []string) error {
-			timeout := defaultTimeout
-			if len(args) == 2 {
-				if t, err := time.ParseDuration(args[1]); err == nil {
-					timeout = t
+		Use:   "stop [-t timeout] <name>... | --filter key=value",
+		Short: "Gracefully stop running containers",
+		Args:  cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// nil: each container's --stop-timeout
+			var timeout *time.Duration
+			if cmd.Flags().Changed("time") {
+				s, _ := cmd.Flags().GetString("time")
+				t, err := signals.ParseTimeout(s)
+				if err != nil {
+					return fmt.Errorf("--time: %v", err)
+				}
+				timeout = &t
+			} else if len(args) == 2 {
+				// older form: boxy stop <name> <timeout>
+				if t, err := signals.ParseTimeout(args[1]); err == nil {
+					timeout, args = &t, args[:1]
 				}
 			}
+			filters, _ := cmd.Flags().GetStringArray("filter")
 
 			ctx := client.Default()
 			c, err := client.Instance()
 			if err != nil {
 				return err
 			}
-
-			cont, err := c.LoadContainer(ctx, args[0])
+			refs, err := selectContainers(ctx, c, args, filters)
 			if err != nil {
 				return err
 			}
+			cmd.SilenceUsage = true
 
-			taskObj, err := cont.Task(ctx, nil)
-			if errdefs.IsNotFound(err) {
-				fmt.Printf("✓ %s already stopped\n", args[0])
-				return nil
-			}
-			if err != nil {
-				return err
-			}
+			return selector.Each(refs, maxParallel, func(ref string) error {
+				return stopContainer(ctx, c, ref, timeout)
+			})
+		},
+	}
+	cmd.Flags().StringP("time", "t", "", "Seconds (or a duration like 1m) to wait for the container to exit before killing it (default its --stop-timeout)")
+	cmd.Flags().StringArray("filter", nil, "Stop the containers matching a filter (label=K[=V], status=, ancestor=)")
+	rootCmd.AddCommand(cmd)
+}
+
+// stopContainer sends the container's stop signal and SIGKILL once the
+// timeout passed; a nil timeout means the container's own
+func stopContainer(ctx context.Context, c *containerd.Client, ref string, timeout *time.Duration) error {
+	cont, err := loadContainer(ctx, c, ref)
+	if err != nil {
+		return err
+	}
+	labels, err := cont.Labels(ctx)
+	if err != nil {
+		return err
+	}
+	stopSignal, stopTimeout := signals.Stop(labels)
+	if timeout != nil {
+		stopTimeout = *timeout
+	}
+	// keep the restart policy from bringing it back
+	if _, err := cont.SetLabels(ctx, map[string]string{boxylabels.Stopped: "true"}); err != nil {
+		return err
+	}
 
-			// Get PID for CNI cleanup before stopping
-			pid := taskObj.Pid()
-			netnsPath := fmt.Sprintf("/proc/%d/ns/net", pid)
+	taskObj, err := cont.Task(ctx, nil)
+	if errdefs.IsNotFound(err) {
+		fmt.Printf("✓ %s already stopped\n", ref)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
 
-			// Clean up CNI networking before stopping (while netns is still available)
-			if cniClient, err := cni.NewClient(); err == nil {
-				if err := cniClient.RemoveNetwork(ctx, args[0], netnsPath); err != nil {
-					fmt.Printf("Warning: failed to cleanup network: %v\n", err)
-				}
-			}
+	// Get PID for CNI cleanup before stopping
+	pid := taskObj.Pid()
+	netnsPath := fmt.Sprintf("/proc/%d/ns/net", pid)
 
-			// 1) try SIGTERM
-			if err := taskObj.Kill(ctx, syscall.SIGTERM); err != nil &&
-				!errdefs.IsNotFound(err) {
-				return err
-			}
+	// Clean up CNI networking before stopping (while netns is still available)
+	if cniClient, err := cni.NewClient(); err == nil {
+		if err := cniClient.RemoveNetwork(ctx, cont.ID(), netnsPath); err != nil {
+			fmt.Printf("Warning: failed to cleanup network: %v\n", err)
+		}
+	}
 
-			exitCh, _ := taskObj.Wait(ctx)
+	exitCh, err := taskObj.Wait(ctx)
+	if err != nil {
+		return err
+	}
 
-			select {
-			case <-exitCh:
-				fmt.Printf("✓ stopped %s\n", args[0])
-				return nil
-			case <-time.After(timeout):
-				// 2) escalate to SIGKILL
-				if err := taskObj.Kill(ctx, syscall.SIGKILL); err != nil &&
-					!errdefs.IsNotFound(err) {
-					return err
-				}
-				<-exitCh
-				fmt.Printf("✓ force-stopped %s\n", args[0])
-				return nil
-			}
-		},
+	// 1) try the stop signal
+	if err := taskObj.Kill(ctx, stopSignal); err != nil &&
+		!errdefs.IsNotFound(err) {
+		return err
+	}
+	resumePaused(ctx, taskObj)
+
+	select {
+	case st := <-exitCh:
+		recordExit(ctx, cont, st.ExitCode())
+		fmt.Printf("✓ stopped %s\n", ref)
+		return nil
+	case <-time.After(stopTimeout):
+		// 2) escalate to SIGKILL
+		if err := taskObj.Kill(ctx, syscall.SIGKILL); err != nil &&
+			!errdefs.IsNotFound(err) {
+			return err
+		}
+		recordExit(ctx, cont, (<-exitCh).ExitCode())
+		fmt.Printf("✓ force-stopped %s\n", ref)
+		return nil
 	}
-	rootCmd.AddCommand(cmd)
 }
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/arnab2001/boxy/internal/client"
+	boxylabels "github.com/arnab2001/boxy/internal/labels"
+	"github.com/arnab2001/boxy/internal/restart"
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "system",
+		Short: "Host-level maintenance",
+	}
+
+	restore := &cobra.Command{
+		Use:   "restore",
+		Short: "Start containers with restart policy always/unless-stopped again (e.g. after a reboot)",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			ctx := client.Default()
+			c, err := client.Instance()
+			if err != nil {
+				return err
+			}
+			containers, err := c.Containers(ctx)
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 2, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tPOLICY\tRESULT")
+			failed := 0
+			for _, cont := range containers {
+				labels, err := cont.Labels(ctx)
+				if err != nil {
+					return err
+				}
+				policy, err := restart.Parse(labels[boxylabels.RestartPolicy])
+				if err != nil || !policy.RestoreOnBoot(labels[boxylabels.Stopped] == "true") {
+					continue
+				}
+				result, err := restoreContainer(cont, labels)
+				if err != nil {
+					failed++
+					result = "failed: " + err.Error()
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", containerName(ctx, cont), policy, result)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d container(s) could not be restored", failed)
+			}
+			return nil
+		},
+	}
+
+	unit := &cobra.Command{
+		Use:   "systemd-unit",
+		Short: "Print a systemd unit that runs boxy system restore at boot",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			exe, err := os.Executable()
+			if err != nil {
+				return err
+			}
+			if exe, err = filepath.EvalSymlinks(exe); err != nil {
+				return err
+			}
+			fmt.Print(restart.SystemdUnit(exe, os.Getenv("CONTAINERD_SOCK")))
+			return nil
+		},
+	}
+
+	cmd.AddCommand(restore, unit)
+	rootCmd.AddCommand(cmd)
+}
+
+// restoreContainer starts a new task for a container whose task did not
+// survive, republishes its ports and hands it back to a monitor
+func restoreContainer(cont containerd.Container, labels map[string]string) (string, error) {
+	ctx := client.Default()
+	unlock, err := lockTask(cont.ID())
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	task, err := cont.Task(ctx, nil)
+	switch {
+	case err == nil:
+		st, err := task.Status(ctx)
+		if err != nil {
+			return "", err
+		}
+		// only a stopped task is replaced; a paused one stays paused
+		switch st.Status {
+		case containerd.Running:
+			return "already running", nil
+		case containerd.Paused, containerd.Pausing:
+			return "paused", nil
+		case containerd.Stopped:
+		default:
+			return "", fmt.Errorf("task is %s", st.Status)
+		}
+	case errdefs.IsNotFound(err):
+		task = nil
+	default:
+		return "", err
+	}
+
+	// always brings back containers stopped by hand as well
+	if labels[boxylabels.Stopped] != "" {
+		if _, err := cont.SetLabels(ctx, map[string]string{boxylabels.Stopped: ""}); err != nil {
+			return "", err
+		}
+	}
+	if err := resetHealth(cont.ID(), labels); err != nil {
+		return "", err
+	}
+	if task, err = restartTask(ctx, cont.ID(), cont, task); err != nil {
+		return "", err
+	}
+	if err := startMonitor(cont.ID()); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("started (PID %d)", task.Pid()), nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"text/tabwriter"
+
+	"github.com/arnab2001/boxy/internal/client"
+	"github.com/arnab2001/boxy/internal/stats"
+	"github.com/arnab2001/boxy/internal/top"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "top <name> [ps options]",
+		Short: "List the processes running inside a container",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			ctx := client.Default()
+			c, err := client.Instance()
+			if err != nil {
+				return err
+			}
+			cont, err := loadContainer(ctx, c, args[0])
+			if err != nil {
+				return err
+			}
+			task, err := cont.Task(ctx, nil)
+			if errdefs.IsNotFound(err) {
+				return fmt.Errorf("container %s is not running", args[0])
+			}
+			if err != nil {
+				return err
+			}
+			infos, err := task.Pids(ctx)
+			if err != nil {
+				return err
+			}
+			pids := make([]int, len(infos))
+			for i, info := range infos {
+				pids[i] = int(info.Pid)
+			}
+
+			// with ps options, run the host's ps and keep the container's rows
+			if len(args) > 1 {
+				out, err := exec.Command("ps", args[1:]...).Output()
+				if err != nil {
+					return fmt.Errorf("ps %v: %v", args[1:], err)
+				}
+				out, err = top.FilterPS(out, pids)
+				if err != nil {
+					return err
+				}
+				_, err = os.Stdout.Write(out)
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 2, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "USER\tPID\tPPID\t%CPU\t%MEM\tRSS\tSTAT\tSTART\tTIME\tCOMMAND")
+			for _, pid := range pids {
+				p, err := top.Read("/proc", pid)
+				if err != nil {
+					continue // exited since Pids was called
+				}
+				fmt.Fprintf(w, "%s\t%d\t%d\t%.1f\t%.1f\t%s\t%s\t%s\t%s\t%s\n",
+					p.User, p.PID, p.PPID, p.CPU, p.Mem, stats.Bytes(p.RSS), p.State,
+					p.Start.Format("15:04"), top.FormatTime(p.Time), p.Command)
+			}
+			return w.Flush()
+		},
+	}
+	// everything after the container name is handed to ps
+	cmd.Flags().SetInterspersed(false)
+	rootCmd.AddCommand(cmd)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/arnab2001/boxy/internal/client"
+	"github.com/arnab2001/boxy/internal/health"
+	boxylabels "github.com/arnab2001/boxy/internal/labels"
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/spf13/cobra"
+)
+
+// healthPoll is how often wait --condition healthy re-reads the health state
+const healthPoll = 500 * time.Millisecond
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "wait [--condition stopped|healthy] <name>...",
+		Short: "Block until containers stop (printing their exit codes) or become healthy",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			condition, _ := cmd.Flags().GetString("condition")
+			if condition != "stopped" && condition != health.Healthy {
+				return fmt.Errorf("unsupported --condition %q (stopped or healthy)", condition)
+			}
+
+			ctx := client.Default()
+			c, err := client.Instance()
+			if err != nil {
+				return err
+			}
+			for _, name := range args {
+				cont, err := loadContainer(ctx, c, name)
+				if err != nil {
+					return err
+				}
+				if condition == health.Healthy {
+					if err := waitHealthy(ctx, cont); err != nil {
+						return err
+					}
+					fmt.Println(health.Healthy)
+					continue
+				}
+				code, err := waitStopped(ctx, cont)
+				if err != nil {
+					return err
+				}
+				fmt.Println(code)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().String("condition", "stopped", "stopped or healthy")
+	rootCmd.AddCommand(cmd)
+}
+
+// waitStopped blocks until the container's task exits and returns its
+// exit code. Without a task (deleted, or lost in a reboot) the recorded
+// exit code of the last one is returned.
+func waitStopped(ctx context.Context, cont containerd.Container) (uint32, error) {
+	task, err := cont.Task(ctx, nil)
+	if errdefs.IsNotFound(err) {
+		labels, err := cont.Labels(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if code, err := strconv.ParseUint(labels[boxylabels.ExitCode], 10, 32); err == nil {
+			return uint32(code), nil
+		}
+		return 0, fmt.Errorf("container %s has not been started", containerName(ctx, cont))
+	}
+	if err != nil {
+		return 0, err
+	}
+	exitCh, err := task.Wait(ctx)
+	if err != nil {
+		return 0, err
+	}
+	code, _, err := (<-exitCh).Result()
+	if err != nil {
+		return 0, err
+	}
+	recordExit(ctx, cont, code)
+	return code, nil
+}
+
+// recordExit stores the exit code of the container's task for boxy wait
+// and inspect. It is best effort: the container may be gone already.
+func recordExit(ctx context.Context, cont containerd.Container, code uint32) {
+	_, _ = cont.SetLabels(ctx, map[string]string{boxylabels.ExitCode: strconv.FormatUint(uint64(code), 10)})
+}
+
+// waitHealthy blocks until the container's monitor reports it healthy;
+// it fails when the task stops first
+func waitHealthy(ctx context.Context, cont containerd.Container) error {
+	labels, err := cont.Labels(ctx)
+	if err != nil {
+		return err
+	}
+	if labels[boxylabels.Healthcheck] == "" {
+		return fmt.Errorf("container %s has no health check", containerName(ctx, cont))
+	}
+	task, err := cont.Task(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("container %s is not running", containerName(ctx, cont))
+	}
+	exitCh, err := task.Wait(ctx)
+	if err != nil {
+		return err
+	}
+	ticker := time.NewTicker(healthPoll)
+	defer ticker.Stop()
+	for {
+		if h, err := health.Load(cont.ID()); err != nil {
+			return err
+		} else if h != nil && h.Status == health.Healthy {
+			return nil
+		}
+		select {
+		case st := <-exitCh:
+			return fmt.Errorf("container %s exited with code %d before becoming healthy", containerName(ctx, cont), st.ExitCode())
+		case <-ticker.C:
+		}
+	}
+}
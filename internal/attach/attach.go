@@ -0,0 +1,173 @@
+package attach
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ErrDetached is returned by DetachReader once the detach keys were typed
+var ErrDetached = errors.New("detached")
+
+// Mode is how a foreground container is attached to boxy's stdio
+type Mode struct {
+	Stdin bool // -i: pass stdin to the container
+	TTY   bool // -t: run the container on a pseudo-terminal
+}
+
+// Resolve picks the mode from the -i/-t flags. Without either flag (flags
+// is nil) stdin is always passed and a TTY is used only when stdin and
+// stdout are both terminals, so `boxy run img` stays interactive while
+// pipes on either side just work.
+func Resolve(flags *Mode, stdinTerminal, stdoutTerminal bool) (Mode, error) {
+	if flags == nil {
+		return Mode{Stdin: true, TTY: stdinTerminal && stdoutTerminal}, nil
+	}
+	if flags.Stdin && flags.TTY && !stdinTerminal {
+		return *flags, fmt.Errorf("the input device is not a TTY (drop -t to pipe stdin)")
+	}
+	return *flags, nil
+}
+
+// StdinCloser passes a reader through and calls the close function once it
+// reaches EOF, which is how the end of a piped stdin is forwarded to a
+// container (containerd keeps the stdin fifo open until CloseIO). The copy
+// starts before the task exists, so EOF may come before SetClose.
+type StdinCloser struct {
+	r   io.Reader
+	mu  sync.Mutex
+	eof bool
+	fn  func() // called at EOF
+}
+
+// NewStdinCloser wraps r
+func NewStdinCloser(r io.Reader) *StdinCloser {
+	return &StdinCloser{r: r}
+}
+
+func (s *StdinCloser) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if err == io.EOF {
+		s.mu.Lock()
+		s.eof = true
+		fn := s.fn
+		s.fn = nil
+		s.mu.Unlock()
+		if fn != nil {
+			fn()
+		}
+	}
+	return n, err
+}
+
+// SetClose sets the function called at EOF, calling it right away when
+// EOF was already reached
+func (s *StdinCloser) SetClose(fn func()) {
+	s.mu.Lock()
+	if !s.eof {
+		s.fn = fn
+		fn = nil
+	}
+	s.mu.Unlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+// DefaultDetachKeys leave an attached container running, as in docker
+const DefaultDetachKeys = "ctrl-p,ctrl-q"
+
+// ParseKeys parses a --detach-keys sequence: comma separated single
+// characters or ctrl-<c> with c one of a-z, @, [, \, ], ^ or _. An empty
+// sequence disables detaching.
+func ParseKeys(s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var keys []byte
+	for _, key := range strings.Split(s, ",") {
+		if len(key) == 1 {
+			keys = append(keys, key[0])
+			continue
+		}
+		c, ok := strings.CutPrefix(strings.ToLower(key), "ctrl-")
+		if !ok || len(c) != 1 {
+			return nil, fmt.Errorf("invalid detach key %q (a character or ctrl-<c>)", key)
+		}
+		switch {
+		case c[0] >= 'a' && c[0] <= 'z':
+			keys = append(keys, c[0]-'a'+1)
+		case strings.ContainsRune(`@[\]^_`, rune(c[0])):
+			keys = append(keys, c[0]-'@')
+		default:
+			return nil, fmt.Errorf("invalid detach key %q (ctrl- takes a-z, @, [, \\, ], ^ or _)", key)
+		}
+	}
+	return keys, nil
+}
+
+// DetachReader passes terminal input through until the detach keys are
+// typed. A partial sequence is held back and passed on once it stops
+// matching, so the keys themselves never reach the container.
+type DetachReader struct {
+	r        io.Reader
+	keys     []byte
+	matched  int    // keys typed so far
+	pending  []byte // input ready to be returned
+	err      error  // returned once pending is drained
+	detached chan struct{}
+}
+
+// NewDetachReader watches r for keys
+func NewDetachReader(r io.Reader, keys []byte) *DetachReader {
+	return &DetachReader{r: r, keys: keys, detached: make(chan struct{})}
+}
+
+// Detached is closed once the detach keys were typed
+func (d *DetachReader) Detached() <-chan struct{} {
+	return d.detached
+}
+
+func (d *DetachReader) Read(p []byte) (int, error) {
+	if len(d.keys) == 0 {
+		return d.r.Read(p)
+	}
+	for len(d.pending) == 0 && d.err == nil {
+		buf := make([]byte, len(p))
+		n, err := d.r.Read(buf)
+		d.scan(buf[:n])
+		if err != nil && d.err == nil {
+			d.pending = append(d.pending, d.keys[:d.matched]...)
+			d.matched = 0
+			d.err = err
+		}
+	}
+	if len(d.pending) > 0 {
+		n := copy(p, d.pending)
+		d.pending = d.pending[n:]
+		return n, nil
+	}
+	return 0, d.err
+}
+
+func (d *DetachReader) scan(data []byte) {
+	for _, b := range data {
+		if d.matched > 0 && b != d.keys[d.matched] {
+			d.pending = append(d.pending, d.keys[:d.matched]...)
+			d.matched = 0
+		}
+		if b != d.keys[d.matched] {
+			d.pending = append(d.pending, b)
+			continue
+		}
+		if d.matched++; d.matched == len(d.keys) {
+			// input typed after the keys is dropped with the connection
+			d.matched = 0
+			d.err = ErrDetached
+			close(d.detached)
+			return
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package attach
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// drainPoll is how long Drain reads at a time before it checks again
+// whether a client attached
+const drainPoll = 100 * time.Millisecond
+
+// Lock files in a container's run directory. Clients hold both shared while
+// they read the fifos. Drain only reads while it holds attach.lock
+// exclusively and keeps off it while anyone holds or waits for attach.want,
+// so a client waiting to attach is not starved.
+const (
+	wantFile = "attach.want"
+	lockFile = "attach.lock"
+)
+
+// Hold marks the fifos of the container with run directory dir as read by
+// a client until the returned function is called. It waits for a running
+// Drain to finish its current read.
+func Hold(dir string) (func(), error) {
+	want, err := openLock(dir, wantFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(want.Fd()), syscall.LOCK_SH); err != nil {
+		want.Close()
+		return nil, err
+	}
+	lock, err := openLock(dir, lockFile)
+	if err != nil {
+		want.Close()
+		return nil, err
+	}
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_SH); err != nil {
+		lock.Close()
+		want.Close()
+		return nil, err
+	}
+	// closing the files drops the locks
+	return func() {
+		lock.Close()
+		want.Close()
+	}, nil
+}
+
+// Drain discards what a container writes to its stdout and stderr fifos
+// while no client holds them. The shim keeps a read end of each fifo open
+// but never reads it, so without Drain a detached container blocks once
+// the pipe is full. It returns when the fifos are closed or ctx is done;
+// empty paths are skipped.
+func Drain(ctx context.Context, dir string, fifos ...string) error {
+	want, err := openLock(dir, wantFile)
+	if err != nil {
+		return err
+	}
+	defer want.Close()
+	lock, err := openLock(dir, lockFile)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	var files []*os.File
+	for _, path := range fifos {
+		if path == "" {
+			continue
+		}
+		f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		files = append(files, f)
+	}
+
+	for len(files) > 0 {
+		if tryLock(want) {
+			syscall.Flock(int(want.Fd()), syscall.LOCK_UN)
+			if tryLock(lock) {
+				files, err = discard(files, time.Now().Add(drainPoll))
+				syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+				if err != nil {
+					return err
+				}
+				if ctx.Err() != nil {
+					return nil
+				}
+				continue
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(drainPoll):
+		}
+	}
+	return nil
+}
+
+// discard reads the fifos until the deadline and returns the ones that
+// are still open
+func discard(files []*os.File, deadline time.Time) ([]*os.File, error) {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		open     []*os.File
+		firstErr error
+	)
+	for _, f := range files {
+		wg.Add(1)
+		go func(f *os.File) {
+			defer wg.Done()
+			if err := f.SetReadDeadline(deadline); err != nil {
+				mu.Lock()
+				firstErr = err
+				mu.Unlock()
+				return
+			}
+			_, err := io.Copy(io.Discard, f)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case errors.Is(err, os.ErrDeadlineExceeded):
+				open = append(open, f)
+			case err != nil && firstErr == nil:
+				firstErr = err
+			}
+			// io.Copy returns nil at EOF: every writer is gone
+		}(f)
+	}
+	wg.Wait()
+	return open, firstErr
+}
+
+func openLock(dir, name string) (*os.File, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_RDWR, 0600)
+}
+
+func tryLock(f *os.File) bool {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB) == nil
+}
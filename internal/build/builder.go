@@ -0,0 +1,521 @@
+package build
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/arnab2001/boxy/internal/image"
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/cio"
+	"github.com/containerd/containerd/mount"
+	"github.com/containerd/containerd/oci"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// Options configures a build
+type Options struct {
+	ContextDir string
+	Dockerfile string // path to the Dockerfile
+	Tag        string // normalised image reference to create
+	BuildArgs  map[string]string
+	Target     string // stop after this stage (default: last stage)
+
+	// GetImage returns a base image, pulling it if needed
+	GetImage func(ctx context.Context, ref string) (containerd.Image, error)
+
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Builder executes a Dockerfile with boxy's own snapshot & task machinery
+type Builder struct {
+	c      *containerd.Client
+	opts   Options
+	df     *Dockerfile
+	ignore *Ignore
+	id     string
+
+	results   []*image.State // finished stages, by index
+	step      int
+	stepTotal int
+}
+
+// Build runs the Dockerfile and registers the resulting image
+func Build(ctx context.Context, c *containerd.Client, opts Options) (containerd.Image, error) {
+	f, err := os.Open(opts.Dockerfile)
+	if err != nil {
+		return nil, err
+	}
+	df, err := Parse(f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", opts.Dockerfile, err)
+	}
+
+	ignore, err := LoadIgnore(opts.ContextDir)
+	if err != nil {
+		return nil, err
+	}
+
+	last := len(df.Stages) - 1
+	if opts.Target != "" {
+		idx, ok := df.StageIndex(opts.Target)
+		if !ok {
+			return nil, fmt.Errorf("target stage %q not found", opts.Target)
+		}
+		last = idx
+	}
+
+	b := &Builder{c: c, opts: opts, df: df, ignore: ignore, id: "boxy-build-" + randomID()}
+	for _, st := range df.Stages[:last+1] {
+		b.stepTotal += len(st.Instructions)
+	}
+
+	// keep intermediate content and snapshots alive until the image exists
+	ctx, done, err := c.WithLease(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done(ctx)
+
+	for _, st := range df.Stages[:last+1] {
+		state, err := b.runStage(ctx, st)
+		if err != nil {
+			return nil, err
+		}
+		b.results = append(b.results, state)
+	}
+
+	final := b.results[last]
+	return final.Write(ctx, c, opts.Tag, nil)
+}
+
+// stageEnv tracks variables visible while executing a stage
+type stageEnv struct {
+	state    *image.State
+	args     map[string]string // declared ARGs
+	shell    []string
+	cmdSet   bool
+	metaArgs map[string]string
+}
+
+// lookup gives ENV precedence over ARG, like docker
+func (e *stageEnv) lookup(name string) (string, bool) {
+	if v, ok := e.lookupEnvOnly(name); ok {
+		return v, true
+	}
+	v, ok := e.args[name]
+	return v, ok
+}
+
+func (e *stageEnv) workdir() string {
+	if e.state.Config.Config.WorkingDir == "" {
+		return "/"
+	}
+	return e.state.Config.Config.WorkingDir
+}
+
+func (b *Builder) metaArgs() (map[string]string, error) {
+	meta := map[string]string{}
+	for _, inst := range b.df.MetaArgs {
+		if err := b.declareArgs(inst, meta, func(k string) (string, bool) { v, ok := meta[k]; return v, ok }); err != nil {
+			return nil, err
+		}
+	}
+	return meta, nil
+}
+
+// declareArgs handles ARG name[=default] ..., with --build-arg overriding
+func (b *Builder) declareArgs(inst Instruction, into map[string]string, lookup func(string) (string, bool)) error {
+	words := splitWords(inst.Rest, lookup)
+	if len(words) == 0 {
+		return fmt.Errorf("line %d: ARG requires a name", inst.Line)
+	}
+	for _, w := range words {
+		name, def, hasDefault := strings.Cut(w, "=")
+		if v, ok := b.opts.BuildArgs[name]; ok {
+			into[name] = v
+		} else if hasDefault {
+			into[name] = def
+		} else if _, ok := into[name]; !ok {
+			into[name] = ""
+		}
+	}
+	return nil
+}
+
+func (b *Builder) runStage(ctx context.Context, st Stage) (*image.State, error) {
+	meta, err := b.metaArgs()
+	if err != nil {
+		return nil, err
+	}
+	b.announce(st.Instructions[0])
+
+	base := Expand(st.Base, func(k string) (string, bool) { v, ok := meta[k]; return v, ok })
+	var state *image.State
+	switch idx, isStage := b.df.StageIndex(base); {
+	case strings.EqualFold(base, "scratch"):
+		state = image.Scratch()
+	case isStage && idx < st.Index && b.df.Stages[idx].Name != "":
+		state, err = cloneState(b.results[idx])
+	default:
+		var img containerd.Image
+		if img, err = b.opts.GetImage(ctx, base); err == nil {
+			state, err = image.Load(ctx, img)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("FROM %s: %v", base, err)
+	}
+
+	env := &stageEnv{
+		state:    state,
+		args:     map[string]string{},
+		shell:    []string{"/bin/sh", "-c"},
+		metaArgs: meta,
+	}
+	for _, inst := range st.Instructions[1:] {
+		b.announce(inst)
+		if err := b.dispatch(ctx, env, inst); err != nil {
+			return nil, fmt.Errorf("line %d: %s: %v", inst.Line, inst.Cmd, err)
+		}
+	}
+	return state, nil
+}
+
+func (b *Builder) announce(inst Instruction) {
+	b.step++
+	fmt.Fprintf(b.opts.Stdout, "Step %d/%d : %s\n", b.step, b.stepTotal, inst.Original)
+}
+
+func (b *Builder) dispatch(ctx context.Context, env *stageEnv, inst Instruction) error {
+	cfg := &env.state.Config.Config
+	switch inst.Cmd {
+	case "ARG":
+		lookup := func(k string) (string, bool) {
+			if v, ok := env.lookup(k); ok {
+				return v, ok
+			}
+			v, ok := env.metaArgs[k]
+			return v, ok
+		}
+		for _, w := range splitWords(inst.Rest, lookup) {
+			// a redeclared meta ARG without default inherits its value
+			name, _, hasDefault := strings.Cut(w, "=")
+			if v, ok := env.metaArgs[name]; ok && !hasDefault {
+				env.args[name] = v
+			}
+		}
+		if err := b.declareArgs(inst, env.args, lookup); err != nil {
+			return err
+		}
+		env.state.AddHistory(inst.Original, "")
+
+	case "ENV":
+		kvs, err := keyValues(inst, env.lookup, true)
+		if err != nil {
+			return err
+		}
+		for _, kv := range kvs {
+			cfg.Env = setEnv(cfg.Env, kv[0], kv[1])
+		}
+		env.state.AddHistory(inst.Original, "")
+
+	case "LABEL":
+		kvs, err := keyValues(inst, env.lookup, true)
+		if err != nil {
+			return err
+		}
+		if cfg.Labels == nil {
+			cfg.Labels = map[string]string{}
+		}
+		for _, kv := range kvs {
+			cfg.Labels[kv[0]] = kv[1]
+		}
+		env.state.AddHistory(inst.Original, "")
+
+	case "WORKDIR":
+		dir := Expand(inst.Rest, env.lookup)
+		if dir == "" {
+			return fmt.Errorf("WORKDIR requires a path")
+		}
+		if !path.IsAbs(dir) {
+			dir = path.Join(env.workdir(), dir)
+		}
+		cfg.WorkingDir = path.Clean(dir)
+		return b.fsStep(ctx, env, inst, func(root string) error {
+			return (&copier{}).mkdirAll(root, cfg.WorkingDir)
+		})
+
+	case "USER":
+		cfg.User = Expand(inst.Rest, env.lookup)
+		env.state.AddHistory(inst.Original, "")
+
+	case "CMD":
+		cfg.Cmd = env.command(inst)
+		env.cmdSet = true
+		env.state.AddHistory(inst.Original, "")
+
+	case "ENTRYPOINT":
+		cfg.Entrypoint = env.command(inst)
+		if !env.cmdSet {
+			cfg.Cmd = nil // an inherited CMD doesn't survive a new ENTRYPOINT
+		}
+		env.state.AddHistory(inst.Original, "")
+
+	case "SHELL":
+		if !inst.IsJSON() || len(inst.JSON) == 0 {
+			return fmt.Errorf("SHELL requires the JSON form")
+		}
+		env.shell = inst.JSON
+		env.state.AddHistory(inst.Original, "")
+
+	case "EXPOSE":
+		if cfg.ExposedPorts == nil {
+			cfg.ExposedPorts = map[string]struct{}{}
+		}
+		for _, p := range splitWords(inst.Rest, env.lookup) {
+			if !strings.Contains(p, "/") {
+				p += "/tcp"
+			}
+			cfg.ExposedPorts[strings.ToLower(p)] = struct{}{}
+		}
+		env.state.AddHistory(inst.Original, "")
+
+	case "VOLUME":
+		vols := inst.JSON
+		if !inst.IsJSON() {
+			vols = splitWords(inst.Rest, env.lookup)
+		}
+		if cfg.Volumes == nil {
+			cfg.Volumes = map[string]struct{}{}
+		}
+		for _, v := range vols {
+			cfg.Volumes[v] = struct{}{}
+		}
+		env.state.AddHistory(inst.Original, "")
+
+	case "STOPSIGNAL":
+		cfg.StopSignal = Expand(inst.Rest, env.lookup)
+		env.state.AddHistory(inst.Original, "")
+
+	case "RUN":
+		return b.run(ctx, env, inst)
+
+	case "COPY", "ADD":
+		return b.copy(ctx, env, inst)
+
+	default:
+		return fmt.Errorf("unsupported instruction")
+	}
+	return nil
+}
+
+// command returns exec form arguments, wrapping shell form in the SHELL
+func (e *stageEnv) command(inst Instruction) []string {
+	if inst.IsJSON() {
+		return inst.JSON
+	}
+	return append(append([]string{}, e.shell...), inst.Rest)
+}
+
+// fsStep prepares an active snapshot on top of the current layers, lets fn
+// change the mounted filesystem and commits the result as a new layer
+func (b *Builder) fsStep(ctx context.Context, env *stageEnv, inst Instruction, fn func(root string) error) error {
+	key := fmt.Sprintf("%s-%d", b.id, b.step)
+	sn := b.c.SnapshotService(image.Snapshotter)
+	mounts, err := sn.Prepare(ctx, key, env.state.ChainID())
+	if err != nil {
+		return err
+	}
+	if err := mount.WithTempMount(ctx, mounts, fn); err != nil {
+		_ = sn.Remove(ctx, key)
+		return err
+	}
+	return env.state.CommitLayer(ctx, b.c, key, inst.Original, "")
+}
+
+func (b *Builder) run(ctx context.Context, env *stageEnv, inst Instruction) error {
+	key := fmt.Sprintf("%s-%d", b.id, b.step)
+	sn := b.c.SnapshotService(image.Snapshotter)
+	if _, err := sn.Prepare(ctx, key, env.state.ChainID()); err != nil {
+		return err
+	}
+
+	if err := b.exec(ctx, env, key, env.command(inst)); err != nil {
+		_ = sn.Remove(ctx, key)
+		return err
+	}
+	return env.state.CommitLayer(ctx, b.c, key, inst.Original, "")
+}
+
+// exec runs args in a throwaway container on top of snapshot key, using the
+// host network so package managers can reach the outside world
+func (b *Builder) exec(ctx context.Context, env *stageEnv, key string, args []string) error {
+	cfg := env.state.Config.Config
+	runEnv := append([]string{}, cfg.Env...)
+	for k, v := range env.args {
+		if _, ok := env.lookupEnvOnly(k); !ok {
+			runEnv = append(runEnv, k+"="+v)
+		}
+	}
+
+	specOpts := []oci.SpecOpts{
+		oci.WithProcessArgs(args...),
+		oci.WithEnv(runEnv),
+		oci.WithProcessCwd(env.workdir()),
+		oci.WithHostNamespace(specs.NetworkNamespace),
+		oci.WithHostResolvconf,
+		oci.WithHostHostsFile,
+	}
+	if cfg.User != "" {
+		specOpts = append(specOpts, oci.WithUser(cfg.User))
+	}
+
+	cont, err := b.c.NewContainer(ctx, key,
+		containerd.WithSnapshotter(image.Snapshotter),
+		containerd.WithSnapshot(key),
+		containerd.WithNewSpec(specOpts...),
+	)
+	if err != nil {
+		return err
+	}
+	defer cont.Delete(ctx)
+
+	task, err := cont.NewTask(ctx, cio.NewCreator(cio.WithStreams(nil, b.opts.Stdout, b.opts.Stderr)))
+	if err != nil {
+		return err
+	}
+	defer task.Delete(ctx)
+
+	exitCh, err := task.Wait(ctx)
+	if err != nil {
+		return err
+	}
+	if err := task.Start(ctx); err != nil {
+		return err
+	}
+	st := <-exitCh
+	code, _, err := st.Result()
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("command %q returned a non-zero code: %d", strings.Join(args, " "), code)
+	}
+	return nil
+}
+
+func (e *stageEnv) lookupEnvOnly(name string) (string, bool) {
+	for _, kv := range e.state.Config.Config.Env {
+		if k, v, _ := strings.Cut(kv, "="); k == name {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func (b *Builder) copy(ctx context.Context, env *stageEnv, inst Instruction) error {
+	words := inst.JSON
+	if !inst.IsJSON() {
+		words = splitWords(inst.Rest, env.lookup)
+	}
+	if len(words) < 2 {
+		return fmt.Errorf("requires at least one source and a destination")
+	}
+	srcs, dest := words[:len(words)-1], words[len(words)-1]
+	if !path.IsAbs(dest) {
+		trailing := strings.HasSuffix(dest, "/")
+		dest = path.Join(env.workdir(), dest)
+		if trailing {
+			dest += "/"
+		}
+	}
+
+	cp := &copier{srcRoot: b.opts.ContextDir, ignore: b.ignore, add: inst.Cmd == "ADD"}
+
+	apply := func(root string) error {
+		if spec := Expand(inst.Flags["chown"], env.lookup); spec != "" {
+			uid, gid, err := resolveChown(root, spec)
+			if err != nil {
+				return err
+			}
+			cp.uid, cp.gid, cp.chown = uid, gid, true
+		}
+		return cp.copyAll(srcs, root, dest)
+	}
+
+	from := inst.Flags["from"]
+	if from == "" {
+		return b.fsStep(ctx, env, inst, apply)
+	}
+
+	// COPY --from reads from an earlier stage or another image
+	cp.ignore, cp.add = nil, false
+	var src *image.State
+	if idx, ok := b.df.StageIndex(from); ok && idx < len(b.results) {
+		src = b.results[idx]
+	} else {
+		img, err := b.opts.GetImage(ctx, from)
+		if err != nil {
+			return err
+		}
+		if src, err = image.Load(ctx, img); err != nil {
+			return err
+		}
+	}
+
+	sn := b.c.SnapshotService(image.Snapshotter)
+	viewKey := fmt.Sprintf("%s-%d-from", b.id, b.step)
+	mounts, err := sn.View(ctx, viewKey, src.ChainID())
+	if err != nil {
+		return err
+	}
+	defer sn.Remove(ctx, viewKey)
+
+	return mount.WithReadonlyTempMount(ctx, mounts, func(srcRoot string) error {
+		cp.srcRoot = srcRoot
+		return b.fsStep(ctx, env, inst, apply)
+	})
+}
+
+func setEnv(env []string, key, value string) []string {
+	for i, kv := range env {
+		if k, _, _ := strings.Cut(kv, "="); k == key {
+			env[i] = key + "=" + value
+			return env
+		}
+	}
+	return append(env, key+"="+value)
+}
+
+func cloneState(s *image.State) (*image.State, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	out := &image.State{}
+	return out, json.Unmarshal(data, out)
+}
+
+func randomID() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ResolveDockerfile returns the Dockerfile path for a context and -f value
+func ResolveDockerfile(contextDir, file string) string {
+	if file == "" {
+		return filepath.Join(contextDir, "Dockerfile")
+	}
+	return file
+}
@@ -0,0 +1,53 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/arnab2001/boxy/internal/image"
+)
+
+// changeable lists the instructions accepted by `boxy commit --change`
+var changeable = map[string]bool{
+	"CMD": true, "ENTRYPOINT": true, "ENV": true, "EXPOSE": true, "LABEL": true,
+	"USER": true, "WORKDIR": true, "STOPSIGNAL": true, "VOLUME": true,
+}
+
+// ApplyChanges applies Dockerfile config instructions to an image config.
+// Each change is either "CMD [\"app\"]" or the KEY=value shorthand "CMD=app".
+func ApplyChanges(state *image.State, changes []string) error {
+	env := &stageEnv{state: state, args: map[string]string{}, shell: []string{"/bin/sh", "-c"}}
+	history := len(state.Config.History)
+	defer func() { state.Config.History = state.Config.History[:history] }()
+
+	for _, change := range changes {
+		text := strings.TrimSpace(change)
+		if k, v, ok := strings.Cut(text, "="); ok && !strings.ContainsAny(k, " \t") && changeable[strings.ToUpper(k)] {
+			text = k + " " + v
+		}
+
+		inst, err := parseInstruction(text, 0)
+		if err != nil {
+			return err
+		}
+		if !changeable[inst.Cmd] {
+			return fmt.Errorf("--change %q: %s is not supported (allowed: CMD, ENTRYPOINT, ENV, EXPOSE, LABEL, USER, WORKDIR, STOPSIGNAL, VOLUME)", change, inst.Cmd)
+		}
+
+		// WORKDIR only changes config here; no layer is created
+		if inst.Cmd == "WORKDIR" {
+			dir := Expand(inst.Rest, env.lookup)
+			if !path.IsAbs(dir) {
+				dir = path.Join(env.workdir(), dir)
+			}
+			state.Config.Config.WorkingDir = path.Clean(dir)
+			continue
+		}
+		if err := (&Builder{}).dispatch(context.Background(), env, inst); err != nil {
+			return fmt.Errorf("--change %q: %v", change, err)
+		}
+	}
+	return nil
+}
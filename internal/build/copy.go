@@ -0,0 +1,401 @@
+package build
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/containerd/continuity/fs"
+)
+
+// copier copies COPY/ADD sources from srcRoot into a mounted rootfs
+type copier struct {
+	srcRoot string
+	ignore  *Ignore // only applied to the build context
+	uid     int
+	gid     int
+	chown   bool // ownership was given with --chown
+	add     bool // ADD semantics: URLs and local archive extraction
+}
+
+// copyAll copies every source into dest (an absolute path inside destRoot)
+func (cp *copier) copyAll(srcs []string, destRoot, dest string) error {
+	destIsDir := strings.HasSuffix(dest, "/") || len(srcs) > 1
+
+	var matches []string
+	for _, src := range srcs {
+		if cp.add && isURL(src) {
+			matches = append(matches, src)
+			continue
+		}
+		found, err := cp.glob(src)
+		if err != nil {
+			return err
+		}
+		if len(found) == 0 {
+			return fmt.Errorf("%s: no such file or directory in build context", src)
+		}
+		if len(found) > 1 {
+			destIsDir = true
+		}
+		matches = append(matches, found...)
+	}
+
+	target, err := fs.RootPath(destRoot, dest)
+	if err != nil {
+		return err
+	}
+	if fi, err := os.Stat(target); err == nil && fi.IsDir() {
+		destIsDir = true
+	}
+
+	for _, m := range matches {
+		if isURL(m) {
+			if err := cp.download(m, destRoot, dest, destIsDir); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := cp.copyOne(m, destRoot, dest, destIsDir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// glob resolves a source pattern to paths relative to srcRoot
+func (cp *copier) glob(src string) ([]string, error) {
+	clean := filepath.Clean("/" + src)
+	abs := filepath.Join(cp.srcRoot, clean)
+	found, err := filepath.Glob(abs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source pattern %q: %v", src, err)
+	}
+	var rels []string
+	for _, f := range found {
+		rel, err := filepath.Rel(cp.srcRoot, f)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if rel != "." && cp.ignore.Excluded(rel) {
+			continue
+		}
+		rels = append(rels, rel)
+	}
+	return rels, nil
+}
+
+func (cp *copier) copyOne(rel, destRoot, dest string, destIsDir bool) error {
+	src, err := fs.RootPath(cp.srcRoot, rel)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	if fi.IsDir() {
+		// directories copy their contents, not the directory itself
+		return cp.copyTree(src, rel, destRoot, dest)
+	}
+
+	if cp.add {
+		if ok, err := cp.extractArchive(src, destRoot, dest); ok || err != nil {
+			return err
+		}
+	}
+
+	target := dest
+	if destIsDir {
+		target = path.Join(dest, filepath.Base(rel))
+	}
+	return cp.copyEntry(src, fi, destRoot, target)
+}
+
+// copyTree walks a source directory honouring .dockerignore
+func (cp *copier) copyTree(src, rel, destRoot, dest string) error {
+	if err := cp.mkdirAll(destRoot, dest); err != nil {
+		return err
+	}
+	return filepath.Walk(src, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		sub, err := filepath.Rel(src, p)
+		if err != nil || sub == "." {
+			return err
+		}
+		if cp.ignore.Excluded(filepath.Join(rel, sub)) {
+			if fi.IsDir() && !cp.ignore.HasExceptions() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		return cp.copyEntry(p, fi, destRoot, path.Join(dest, filepath.ToSlash(sub)))
+	})
+}
+
+// copyEntry copies a single file, directory or symlink to target (a path
+// inside destRoot); symlinks in destRoot are resolved within destRoot
+func (cp *copier) copyEntry(src string, fi os.FileInfo, destRoot, target string) error {
+	if err := cp.mkdirAll(destRoot, path.Dir(target)); err != nil {
+		return err
+	}
+	dst, err := fs.RootPath(destRoot, target)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case fi.IsDir():
+		if err := os.MkdirAll(dst, fi.Mode().Perm()); err != nil {
+			return err
+		}
+	case fi.Mode()&os.ModeSymlink != 0:
+		link, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		_ = os.Remove(dst)
+		if err := os.Symlink(link, dst); err != nil {
+			return err
+		}
+	case fi.Mode().IsRegular():
+		if err := copyFile(src, dst, fi.Mode().Perm()); err != nil {
+			return err
+		}
+	default:
+		return nil // sockets, devices etc. are not copied
+	}
+	return os.Lchown(dst, cp.uid, cp.gid)
+}
+
+// mkdirAll creates dir (inside root) and missing parents owned by root
+func (cp *copier) mkdirAll(root, dir string) error {
+	p, err := fs.RootPath(root, dir)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(p, 0755)
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chmod(dst, mode)
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// download fetches an ADD URL into the rootfs (mode 0600 like docker)
+func (cp *copier) download(rawURL, destRoot, dest string, destIsDir bool) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	target := dest
+	if destIsDir {
+		base := path.Base(u.Path)
+		if base == "/" || base == "." {
+			return fmt.Errorf("cannot determine filename from %s; use a file destination", rawURL)
+		}
+		target = path.Join(dest, base)
+	}
+	if err := cp.mkdirAll(destRoot, path.Dir(target)); err != nil {
+		return err
+	}
+	dst, err := fs.RootPath(destRoot, target)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.Get(rawURL) //nolint:gosec // URL comes from the Dockerfile
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download %s: %s", rawURL, resp.Status)
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Lchown(dst, cp.uid, cp.gid)
+}
+
+// extractArchive unpacks a local tar (optionally gzip/bzip2 compressed) into
+// dest; it reports false when src is not an archive
+func (cp *copier) extractArchive(src, destRoot, dest string) (bool, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, _ := br.Peek(3); len(magic) == 3 {
+		switch {
+		case magic[0] == 0x1f && magic[1] == 0x8b:
+			gz, err := gzip.NewReader(br)
+			if err != nil {
+				return false, nil
+			}
+			defer gz.Close()
+			r = gz
+		case string(magic) == "BZh":
+			r = bzip2.NewReader(br)
+		}
+	}
+
+	tr := tar.NewReader(r)
+	hdr, err := tr.Next()
+	if err != nil {
+		return false, nil // not a tar archive: copy as a plain file
+	}
+
+	if err := cp.mkdirAll(destRoot, dest); err != nil {
+		return true, err
+	}
+	for ; err == nil; hdr, err = tr.Next() {
+		if err := cp.extractEntry(tr, hdr, destRoot, dest); err != nil {
+			return true, err
+		}
+	}
+	if err != io.EOF {
+		return true, err
+	}
+	return true, nil
+}
+
+func (cp *copier) extractEntry(tr *tar.Reader, hdr *tar.Header, destRoot, dest string) error {
+	target := path.Join(dest, path.Clean("/"+hdr.Name))
+	if err := cp.mkdirAll(destRoot, path.Dir(target)); err != nil {
+		return err
+	}
+	dst, err := fs.RootPath(destRoot, target)
+	if err != nil {
+		return err
+	}
+
+	mode := os.FileMode(hdr.Mode).Perm()
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		if err := os.MkdirAll(dst, mode); err != nil {
+			return err
+		}
+	case tar.TypeReg:
+		out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, tr); err != nil {
+			out.Close()
+			return err
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+	case tar.TypeSymlink:
+		_ = os.Remove(dst)
+		if err := os.Symlink(hdr.Linkname, dst); err != nil {
+			return err
+		}
+	case tar.TypeLink:
+		old, err := fs.RootPath(destRoot, path.Join(dest, path.Clean("/"+hdr.Linkname)))
+		if err != nil {
+			return err
+		}
+		_ = os.Remove(dst)
+		if err := os.Link(old, dst); err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+
+	uid, gid := hdr.Uid, hdr.Gid
+	if cp.chown {
+		uid, gid = cp.uid, cp.gid
+	}
+	return os.Lchown(dst, uid, gid)
+}
+
+// resolveChown turns a --chown value (user[:group], names or IDs) into IDs,
+// looking names up in the destination rootfs
+func resolveChown(root, spec string) (int, int, error) {
+	user, group, hasGroup := strings.Cut(spec, ":")
+	uid, err := lookupID(root, "/etc/passwd", user)
+	if err != nil {
+		return 0, 0, err
+	}
+	gid := uid
+	if hasGroup {
+		if gid, err = lookupID(root, "/etc/group", group); err != nil {
+			return 0, 0, err
+		}
+	}
+	return uid, gid, nil
+}
+
+// lookupID resolves a numeric ID or a name from a passwd/group style file
+func lookupID(root, file, name string) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil {
+		return id, nil
+	}
+	p, err := fs.RootPath(root, file)
+	if err != nil {
+		return 0, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return 0, fmt.Errorf("cannot resolve %q: %v", name, err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Split(sc.Text(), ":")
+		if len(fields) > 2 && fields[0] == name {
+			return strconv.Atoi(fields[2])
+		}
+	}
+	return 0, fmt.Errorf("cannot resolve %q: not found in %s", name, file)
+}
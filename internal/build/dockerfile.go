@@ -0,0 +1,325 @@
+package build
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Instruction is a single parsed Dockerfile instruction
+type Instruction struct {
+	Cmd      string            // upper-cased keyword, e.g. RUN
+	Flags    map[string]string // leading --flag=value options (COPY --from=...)
+	Rest     string            // raw text after the keyword and flags
+	JSON     []string          // exec form arguments when Rest is a JSON array
+	Line     int               // 1-based line the instruction starts on
+	Original string            // instruction as written (continuations joined)
+}
+
+// IsJSON reports whether the instruction used the exec (JSON array) form
+func (i Instruction) IsJSON() bool { return i.JSON != nil }
+
+// Stage is a FROM instruction and everything up to the next FROM
+type Stage struct {
+	Base         string // image reference, "scratch" or an earlier stage name
+	Name         string // optional AS name (lower-cased)
+	Index        int
+	Instructions []Instruction
+}
+
+// Dockerfile is a parsed Dockerfile
+type Dockerfile struct {
+	MetaArgs []Instruction // ARG instructions before the first FROM
+	Stages   []Stage
+}
+
+// flagged lists the instructions whose leading --flags are parsed
+var flagged = map[string]bool{"FROM": true, "COPY": true, "ADD": true, "RUN": true}
+
+// Parse reads a Dockerfile (comments, line continuations, exec/shell forms)
+func Parse(r io.Reader) (*Dockerfile, error) {
+	var (
+		df      = &Dockerfile{}
+		sc      = bufio.NewScanner(r)
+		lineNo  = 0
+		start   = 0
+		pending strings.Builder
+	)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	flush := func() error {
+		text := strings.TrimSpace(pending.String())
+		pending.Reset()
+		if text == "" {
+			return nil
+		}
+		inst, err := parseInstruction(text, start)
+		if err != nil {
+			return err
+		}
+		return df.add(inst)
+	}
+
+	for sc.Scan() {
+		lineNo++
+		line := sc.Text()
+		trimmed := strings.TrimSpace(line)
+
+		// comments and blank lines are skipped, even inside a continuation
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if pending.Len() == 0 {
+			start = lineNo
+		}
+		if strings.HasSuffix(trimmed, "\\") {
+			pending.WriteString(strings.TrimSpace(strings.TrimSuffix(trimmed, "\\")))
+			pending.WriteString(" ")
+			continue
+		}
+		pending.WriteString(trimmed)
+		if err := flush(); err != nil {
+			return nil, err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	if len(df.Stages) == 0 {
+		return nil, fmt.Errorf("no FROM instruction found")
+	}
+	return df, nil
+}
+
+func (df *Dockerfile) add(inst Instruction) error {
+	if inst.Cmd == "FROM" {
+		words := strings.Fields(inst.Rest)
+		stage := Stage{Index: len(df.Stages), Instructions: []Instruction{inst}}
+		switch {
+		case len(words) == 1:
+			stage.Base = words[0]
+		case len(words) == 3 && strings.EqualFold(words[1], "AS"):
+			stage.Base, stage.Name = words[0], strings.ToLower(words[2])
+		default:
+			return fmt.Errorf("line %d: FROM requires IMAGE [AS name]", inst.Line)
+		}
+		df.Stages = append(df.Stages, stage)
+		return nil
+	}
+
+	if len(df.Stages) == 0 {
+		if inst.Cmd != "ARG" {
+			return fmt.Errorf("line %d: %s before FROM (only ARG is allowed)", inst.Line, inst.Cmd)
+		}
+		df.MetaArgs = append(df.MetaArgs, inst)
+		return nil
+	}
+	last := &df.Stages[len(df.Stages)-1]
+	last.Instructions = append(last.Instructions, inst)
+	return nil
+}
+
+// StageIndex finds a stage by AS name or numeric index
+func (df *Dockerfile) StageIndex(name string) (int, bool) {
+	name = strings.ToLower(name)
+	for i, s := range df.Stages {
+		if s.Name != "" && s.Name == name {
+			return i, true
+		}
+	}
+	var n int
+	if _, err := fmt.Sscanf(name, "%d", &n); err == nil && fmt.Sprint(n) == name && n >= 0 && n < len(df.Stages) {
+		return n, true
+	}
+	return 0, false
+}
+
+func parseInstruction(text string, line int) (Instruction, error) {
+	keyword, rest, _ := strings.Cut(text, " ")
+	inst := Instruction{
+		Cmd:      strings.ToUpper(keyword),
+		Flags:    map[string]string{},
+		Line:     line,
+		Original: text,
+	}
+	rest = strings.TrimSpace(rest)
+
+	if flagged[inst.Cmd] {
+		for strings.HasPrefix(rest, "--") {
+			word, after, _ := strings.Cut(rest, " ")
+			k, v, ok := strings.Cut(strings.TrimPrefix(word, "--"), "=")
+			if !ok {
+				v = "true"
+			}
+			inst.Flags[strings.ToLower(k)] = v
+			rest = strings.TrimSpace(after)
+		}
+	}
+	inst.Rest = rest
+
+	if strings.HasPrefix(rest, "[") {
+		var args []string
+		if err := json.Unmarshal([]byte(rest), &args); err == nil {
+			inst.JSON = args
+		}
+	}
+	return inst, nil
+}
+
+// splitWords tokenises shell-form arguments: whitespace separated words with
+// '…' and "…" quoting and backslash escapes. When lookup is non-nil,
+// $VAR / ${VAR} / ${VAR:-def} / ${VAR:+alt} are expanded outside single quotes.
+func splitWords(s string, lookup func(string) (string, bool)) []string {
+	var (
+		words  []string
+		cur    strings.Builder
+		inWord bool
+		quote  rune
+	)
+	rs := []rune(s)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\\' && i+1 < len(rs):
+			i++
+			cur.WriteRune(rs[i])
+			inWord = true
+		case r == '$' && lookup != nil:
+			val, n := expandAt(rs[i:], lookup)
+			cur.WriteString(val)
+			i += n - 1
+			inWord = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+	return words
+}
+
+// Expand substitutes variables in s without splitting it into words
+func Expand(s string, lookup func(string) (string, bool)) string {
+	var out strings.Builder
+	rs := []rune(s)
+	for i := 0; i < len(rs); i++ {
+		switch {
+		case rs[i] == '\\' && i+1 < len(rs) && rs[i+1] == '$':
+			out.WriteRune('$')
+			i++
+		case rs[i] == '$':
+			val, n := expandAt(rs[i:], lookup)
+			out.WriteString(val)
+			i += n - 1
+		default:
+			out.WriteRune(rs[i])
+		}
+	}
+	return out.String()
+}
+
+// expandAt expands the variable reference at the start of rs ('$' first) and
+// returns the value plus the number of runes consumed
+func expandAt(rs []rune, lookup func(string) (string, bool)) (string, int) {
+	if len(rs) < 2 {
+		return "$", 1
+	}
+	if rs[1] == '{' {
+		end := -1
+		for j := 2; j < len(rs); j++ {
+			if rs[j] == '}' {
+				end = j
+				break
+			}
+		}
+		if end < 0 {
+			return string(rs), len(rs)
+		}
+		body := string(rs[2:end])
+		name, word, op := body, "", ""
+		if k := strings.Index(body, ":"); k >= 0 && k+1 < len(body) {
+			name, op, word = body[:k], body[k:k+2], body[k+2:]
+		}
+		val, ok := lookup(name)
+		switch op {
+		case ":-":
+			if !ok || val == "" {
+				val = Expand(word, lookup)
+			}
+		case ":+":
+			if ok && val != "" {
+				val = Expand(word, lookup)
+			} else {
+				val = ""
+			}
+		}
+		return val, end + 1
+	}
+
+	j := 1
+	for j < len(rs) && (rs[j] == '_' || (rs[j] >= 'a' && rs[j] <= 'z') || (rs[j] >= 'A' && rs[j] <= 'Z') || (j > 1 && rs[j] >= '0' && rs[j] <= '9')) {
+		j++
+	}
+	if j == 1 {
+		return "$", 1
+	}
+	val, _ := lookup(string(rs[1:j]))
+	return val, j
+}
+
+// keyValues parses ENV/LABEL arguments: KEY=VALUE pairs, or the legacy
+// "KEY VALUE…" form when allowLegacy is set and the first word has no '='
+func keyValues(inst Instruction, lookup func(string) (string, bool), allowLegacy bool) ([][2]string, error) {
+	words := splitWords(inst.Rest, lookup)
+	if len(words) == 0 {
+		return nil, fmt.Errorf("line %d: %s requires at least one argument", inst.Line, inst.Cmd)
+	}
+	if !strings.Contains(words[0], "=") {
+		if allowLegacy {
+			key, value, _ := strings.Cut(inst.Rest, " ")
+			value = strings.TrimSpace(value)
+			if value == "" {
+				return nil, fmt.Errorf("line %d: %s %s requires a value", inst.Line, inst.Cmd, key)
+			}
+			return [][2]string{{key, Expand(value, lookup)}}, nil
+		}
+	}
+
+	var out [][2]string
+	for _, w := range words {
+		k, v, ok := strings.Cut(w, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("line %d: %s expects KEY=VALUE, got %q", inst.Line, inst.Cmd, w)
+		}
+		out = append(out, [2]string{k, v})
+	}
+	return out, nil
+}
@@ -0,0 +1,153 @@
+package build
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Ignore holds the patterns of a .dockerignore file
+type Ignore struct {
+	patterns []ignorePattern
+}
+
+type ignorePattern struct {
+	re        *regexp.Regexp
+	exclusion bool // "!pattern" re-includes matching paths
+}
+
+// LoadIgnore reads <contextDir>/.dockerignore; a missing file ignores nothing
+func LoadIgnore(contextDir string) (*Ignore, error) {
+	f, err := os.Open(filepath.Join(contextDir, ".dockerignore"))
+	if os.IsNotExist(err) {
+		return &Ignore{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return NewIgnore(lines)
+}
+
+// NewIgnore compiles .dockerignore lines (comments, "!" exceptions, "**")
+func NewIgnore(lines []string) (*Ignore, error) {
+	ig := &Ignore{}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p := ignorePattern{}
+		if strings.HasPrefix(line, "!") {
+			p.exclusion = true
+			line = strings.TrimSpace(line[1:])
+		}
+		line = filepath.ToSlash(filepath.Clean(line))
+		line = strings.TrimPrefix(line, "/")
+		if line == "." || line == "" {
+			continue
+		}
+		re, err := compilePattern(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid .dockerignore pattern %q: %v", line, err)
+		}
+		p.re = re
+		ig.patterns = append(ig.patterns, p)
+	}
+	return ig, nil
+}
+
+// Excluded reports whether rel (slash separated, relative to the context
+// root) is ignored. A path is also ignored when one of its parents is.
+func (ig *Ignore) Excluded(rel string) bool {
+	if ig == nil || len(ig.patterns) == 0 {
+		return false
+	}
+	rel = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(rel)), "/")
+
+	// check every parent so "node_modules" also ignores node_modules/x/y
+	parents := []string{rel}
+	for dir := filepath.Dir(rel); dir != "." && dir != "/"; dir = filepath.Dir(dir) {
+		parents = append(parents, dir)
+	}
+
+	excluded := false
+	for _, p := range ig.patterns {
+		for _, candidate := range parents {
+			if p.re.MatchString(candidate) {
+				excluded = !p.exclusion
+				break
+			}
+		}
+	}
+	return excluded
+}
+
+// compilePattern converts a Go filepath.Match style pattern with "**"
+// support into an anchored regular expression
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '*' && i+1 < len(pattern) && pattern[i+1] == '*':
+			i++
+			if i+1 < len(pattern) && pattern[i+1] == '/' {
+				// "**/" matches zero or more directories
+				i++
+				sb.WriteString("(.*/)?")
+			} else {
+				sb.WriteString(".*")
+			}
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated character class")
+			}
+			class := pattern[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i += end
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			sb.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
+
+// HasExceptions reports whether any "!" pattern exists, in which case
+// ignored directories still have to be walked
+func (ig *Ignore) HasExceptions() bool {
+	if ig == nil {
+		return false
+	}
+	for _, p := range ig.patterns {
+		if p.exclusion {
+			return true
+		}
+	}
+	return false
+}
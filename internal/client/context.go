@@ -6,7 +6,10 @@ import (
 	"github.com/containerd/containerd/namespaces"
 )
 
+// Namespace is the containerd namespace holding boxy's containers and images
+const Namespace = "boxy"
+
 // Default returns a context pre-populated with our namespace.
 func Default() context.Context {
-	return namespaces.WithNamespace(context.Background(), "boxy")
+	return namespaces.WithNamespace(context.Background(), Namespace)
 }
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Config is boxy's on-disk configuration (config.json)
+type Config struct {
+	Registry Registry `json:"registry,omitempty"`
+	UserNS   UserNS   `json:"userns,omitempty"`
+}
+
+// UserNS configures user namespace remapping for new containers
+type UserNS struct {
+	// Remap names the user whose /etc/subuid and /etc/subgid ranges are
+	// handed out to containers ("default" means the boxy user). When set,
+	// containers get --userns=auto unless another mode is requested.
+	Remap string `json:"remap,omitempty"`
+
+	// Size is the number of uids/gids given to each container (default 65536)
+	Size uint32 `json:"size,omitempty"`
+}
+
+// Registry holds per-registry endpoint configuration used when pulling
+type Registry struct {
+	// ConfigPath is a containerd-style certs.d root holding
+	// <host>/hosts.toml files (mirrors, CAs, client certs, skip_verify)
+	ConfigPath string `json:"configPath,omitempty"`
+
+	// Hosts maps a registry host (e.g. "docker.io") to the endpoints tried,
+	// in order, before the registry itself
+	Hosts map[string]RegistryHost `json:"hosts,omitempty"`
+}
+
+// RegistryHost configures the endpoints used for a single registry
+type RegistryHost struct {
+	Mirrors []Endpoint `json:"mirrors,omitempty"`
+
+	// Server overrides TLS settings for the upstream registry itself
+	Server *Endpoint `json:"server,omitempty"`
+
+	// MirrorsOnly disables falling back to the upstream registry
+	MirrorsOnly bool `json:"mirrorsOnly,omitempty"`
+}
+
+// Endpoint is a single registry endpoint (mirror or pull-through cache)
+type Endpoint struct {
+	Host         string   `json:"host"`                   // e.g. "mirror.local:5000" or "https://mirror.local/v2"
+	Capabilities []string `json:"capabilities,omitempty"` // pull, resolve, push (default pull+resolve)
+	SkipVerify   bool     `json:"skipVerify,omitempty"`
+	PlainHTTP    bool     `json:"plainHTTP,omitempty"`
+	CA           []string `json:"ca,omitempty"`
+	ClientCert   string   `json:"clientCert,omitempty"`
+	ClientKey    string   `json:"clientKey,omitempty"`
+}
+
+// Dir returns the directory boxy reads its configuration from
+func Dir() string {
+	if os.Geteuid() == 0 {
+		return "/etc/boxy"
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".config", "boxy")
+	}
+	return "/etc/boxy"
+}
+
+// RunDir returns the directory for runtime state such as health check
+// results; it does not survive reboots ($BOXY_RUN_DIR overrides it)
+func RunDir() string {
+	if dir := os.Getenv("BOXY_RUN_DIR"); dir != "" {
+		return dir
+	}
+	if os.Geteuid() == 0 {
+		return "/run/boxy"
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "boxy")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("boxy-%d", os.Geteuid()))
+}
+
+// Path returns the config file location ($BOXY_CONFIG overrides the default)
+func Path() string {
+	if p := os.Getenv("BOXY_CONFIG"); p != "" {
+		return p
+	}
+	return filepath.Join(Dir(), "config.json")
+}
+
+// Load reads the config file; a missing file yields the defaults
+func Load() (*Config, error) {
+	return LoadFile(Path())
+}
+
+// LoadFile reads the config from an explicit path
+func LoadFile(path string) (*Config, error) {
+	cfg := &Config{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg.withDefaults(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %v", path, err)
+	}
+	return cfg.withDefaults(), nil
+}
+
+func (c *Config) withDefaults() *Config {
+	if c.Registry.ConfigPath == "" {
+		c.Registry.ConfigPath = filepath.Join(Dir(), "certs.d")
+	}
+	if c.UserNS.Size == 0 {
+		c.UserNS.Size = 65536
+	}
+	return c
+}
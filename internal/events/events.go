@@ -0,0 +1,250 @@
+package events
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	boxylabels "github.com/arnab2001/boxy/internal/labels"
+	apievents "github.com/containerd/containerd/api/events"
+	ctrevents "github.com/containerd/containerd/events"
+	"github.com/containerd/typeurl/v2"
+)
+
+// Event is the stable JSON schema printed by `boxy events --format json`.
+// Type is one of container, image or snapshot; Action depends on the type:
+//
+//	container: create, start, exec_start, die, exec_die, oom, pause, unpause, rename, delete, destroy
+//	image:     create, update, delete
+//	snapshot:  prepare, commit, remove
+//
+// ID is the container ID, image reference or snapshot key; container
+// events carry the container's name in the name attribute, rename events
+// the previous one in oldName.
+type Event struct {
+	Time       time.Time         `json:"time"`
+	Type       string            `json:"type"`
+	Action     string            `json:"action"`
+	ID         string            `json:"id"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// Decode converts a containerd envelope into an Event; ok is false for
+// topics boxy does not report (content, namespaces, sandboxes, ...).
+// Container record updates are returned as update events with the new
+// name; Rename tells which of them are reported.
+func Decode(env *ctrevents.Envelope) (e *Event, ok bool, err error) {
+	v, err := typeurl.UnmarshalAny(env.Event)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to decode %s event: %v", env.Topic, err)
+	}
+	e = &Event{Time: env.Timestamp, Attributes: map[string]string{}}
+	container := func(action, id string) {
+		e.Type, e.Action, e.ID = "container", action, id
+	}
+
+	switch ev := v.(type) {
+	case *apievents.ContainerCreate:
+		container("create", ev.ID)
+		e.Attributes["image"] = ev.Image
+	case *apievents.ContainerUpdate:
+		container("update", ev.ID)
+		e.Attributes["name"] = ev.Labels[boxylabels.Name]
+	case *apievents.ContainerDelete:
+		container("destroy", ev.ID)
+	case *apievents.TaskStart:
+		container("start", ev.ContainerID)
+		e.Attributes["pid"] = strconv.FormatUint(uint64(ev.Pid), 10)
+	case *apievents.TaskExecStarted:
+		container("exec_start", ev.ContainerID)
+		e.Attributes["execID"] = ev.ExecID
+		e.Attributes["pid"] = strconv.FormatUint(uint64(ev.Pid), 10)
+	case *apievents.TaskExit:
+		// the init process is reported with the container's own id
+		if ev.ID == ev.ContainerID {
+			container("die", ev.ContainerID)
+		} else {
+			container("exec_die", ev.ContainerID)
+			e.Attributes["execID"] = ev.ID
+		}
+		e.Attributes["pid"] = strconv.FormatUint(uint64(ev.Pid), 10)
+		e.Attributes["exitCode"] = strconv.FormatUint(uint64(ev.ExitStatus), 10)
+	case *apievents.TaskOOM:
+		container("oom", ev.ContainerID)
+	case *apievents.TaskPaused:
+		container("pause", ev.ContainerID)
+	case *apievents.TaskResumed:
+		container("unpause", ev.ContainerID)
+	case *apievents.TaskDelete:
+		if ev.ID != "" && ev.ID != ev.ContainerID {
+			return nil, false, nil // exec processes are reported by exec_die
+		}
+		container("delete", ev.ContainerID)
+		e.Attributes["exitCode"] = strconv.FormatUint(uint64(ev.ExitStatus), 10)
+	case *apievents.ImageCreate:
+		e.Type, e.Action, e.ID = "image", "create", ev.Name
+	case *apievents.ImageUpdate:
+		e.Type, e.Action, e.ID = "image", "update", ev.Name
+	case *apievents.ImageDelete:
+		e.Type, e.Action, e.ID = "image", "delete", ev.Name
+	case *apievents.SnapshotPrepare:
+		e.Type, e.Action, e.ID = "snapshot", "prepare", ev.Key
+		e.Attributes["parent"] = ev.Parent
+		e.Attributes["snapshotter"] = ev.Snapshotter
+	case *apievents.SnapshotCommit:
+		e.Type, e.Action, e.ID = "snapshot", "commit", ev.Key
+		e.Attributes["name"] = ev.Name
+		e.Attributes["snapshotter"] = ev.Snapshotter
+	case *apievents.SnapshotRemove:
+		e.Type, e.Action, e.ID = "snapshot", "remove", ev.Key
+		e.Attributes["snapshotter"] = ev.Snapshotter
+	default:
+		return nil, false, nil
+	}
+	for k, v := range e.Attributes {
+		if v == "" {
+			delete(e.Attributes, k)
+		}
+	}
+	return e, true, nil
+}
+
+// Rename turns an update event into a rename event when the container's
+// name changed from previous; other updates (labels boxy keeps for its own
+// bookkeeping) are not reported
+func Rename(e *Event, previous string) bool {
+	name := e.Attributes["name"]
+	if e.Action != "update" || previous == "" || name == "" || name == previous {
+		return false
+	}
+	e.Action = "rename"
+	e.Attributes["oldName"] = previous
+	return true
+}
+
+// Containers remembers the name and image of the containers events were
+// seen for, so that events of a container carry them even once it is gone
+type Containers struct {
+	load  func(id string) (name, image string, err error)
+	known map[string]containerInfo
+}
+
+type containerInfo struct {
+	name, image string
+}
+
+// NewContainers returns a cache that looks up containers it has not seen
+// with load
+func NewContainers(load func(id string) (name, image string, err error)) *Containers {
+	return &Containers{load: load, known: map[string]containerInfo{}}
+}
+
+// Add records a container known up front, so that a rename reports its
+// old name
+func (c *Containers) Add(id, name, image string) {
+	c.known[id] = containerInfo{name, image}
+}
+
+// Annotate fills in the name and image attributes of a container event and
+// turns updates into rename events. It reports false for updates that are
+// not renames, which are not reported.
+func (c *Containers) Annotate(e *Event) bool {
+	if e.Type != "container" {
+		return true
+	}
+	if e.Attributes == nil {
+		e.Attributes = map[string]string{}
+	}
+	info, known := c.known[e.ID]
+	if !known && c.load != nil {
+		if name, image, err := c.load(e.ID); err == nil {
+			info = containerInfo{name, image}
+		}
+	}
+	if image := e.Attributes["image"]; image != "" {
+		info.image = image
+	}
+	previous := info.name
+	if name := e.Attributes["name"]; name != "" {
+		info.name = name
+	}
+	c.known[e.ID] = info
+	if e.Action == "update" && !Rename(e, previous) {
+		return false
+	}
+	if info.name != "" {
+		e.Attributes["name"] = info.name
+	}
+	if info.image != "" {
+		e.Attributes["image"] = info.image
+	}
+	return true
+}
+
+// filterKeys are the keys --filter accepts
+var filterKeys = map[string]bool{"type": true, "event": true, "container": true, "image": true}
+
+// Filter selects events. Values of one key are alternatives, different
+// keys must all match: type=container,event=die,event=oom keeps container
+// exits and OOMs.
+type Filter map[string][]string
+
+// ParseFilters parses --filter flags, each a comma separated list of
+// key=value pairs
+func ParseFilters(flags []string) (Filter, error) {
+	f := Filter{}
+	for _, flag := range flags {
+		for _, kv := range strings.Split(flag, ",") {
+			k, v, ok := strings.Cut(strings.TrimSpace(kv), "=")
+			if !ok || v == "" {
+				return nil, fmt.Errorf("invalid filter %q (expected key=value)", kv)
+			}
+			if !filterKeys[k] {
+				return nil, fmt.Errorf("unknown filter %q (type, event, container or image)", k)
+			}
+			f[k] = append(f[k], v)
+		}
+	}
+	return f, nil
+}
+
+// Match reports whether the event passes the filter
+func (f Filter) Match(e *Event) bool {
+	for k, values := range f {
+		var fields []string
+		switch k {
+		case "type":
+			fields = []string{e.Type}
+		case "event":
+			fields = []string{e.Action}
+		case "container":
+			if e.Type != "container" {
+				return false
+			}
+			fields = []string{e.ID, e.Attributes["name"]}
+		case "image":
+			fields = []string{e.ID}
+			if e.Type == "container" {
+				fields = []string{e.Attributes["image"]}
+			} else if e.Type != "image" {
+				return false
+			}
+		}
+		if !matchAny(values, fields) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchAny(values, fields []string) bool {
+	for _, v := range values {
+		for _, field := range fields {
+			if field != "" && v == field {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package exitcode
+
+import (
+	"errors"
+	"strings"
+)
+
+// Exit statuses of boxy run when the container's own status is not
+// available, as docker reports them
+const (
+	Failed       = 125 // boxy failed before the container ran
+	CannotInvoke = 126 // the command exists but cannot be executed
+	NotFound     = 127 // the command does not exist
+)
+
+// Error makes boxy exit with Code; Err is printed unless it is nil (a
+// container's own non-zero exit needs no message)
+type Error struct {
+	Code int
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return ""
+	}
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Wrap tags a boxy failure with Failed unless it already carries a code
+func Wrap(err error) error {
+	var e *Error
+	if err == nil || errors.As(err, &e) {
+		return err
+	}
+	return &Error{Code: Failed, Err: err}
+}
+
+// FromStart classifies an error creating or starting a container's task.
+// The runtime reports exec failures of the init process as
+// `exec: "cmd": <reason>`.
+func FromStart(err error) *Error {
+	msg := err.Error()
+	_, reason, isExec := strings.Cut(msg, "exec: ")
+	switch {
+	case isExec && (strings.Contains(reason, "executable file not found") || strings.Contains(reason, "no such file or directory")):
+		return &Error{Code: NotFound, Err: err}
+	case isExec && (strings.Contains(reason, "permission denied") || strings.Contains(reason, "is a directory")):
+		return &Error{Code: CannotInvoke, Err: err}
+	}
+	return &Error{Code: Failed, Err: err}
+}
+
+// Code returns the exit status for the error a command returned
+func Code(err error) int {
+	var e *Error
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &e):
+		return e.Code
+	}
+	return 1
+}
@@ -0,0 +1,174 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/arnab2001/boxy/internal/config"
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/content"
+)
+
+// Health statuses, as docker reports them
+const (
+	Starting  = "starting"
+	Healthy   = "healthy"
+	Unhealthy = "unhealthy"
+)
+
+// Defaults for values neither the image nor the flags set
+const (
+	DefaultInterval = 30 * time.Second
+	DefaultTimeout  = 30 * time.Second
+	DefaultRetries  = 3
+)
+
+// maxLog is the number of probe results kept in the state
+const maxLog = 5
+
+// maxOutput bounds the output kept per probe result
+const maxOutput = 4096
+
+// Config is a health check in the image config's format (durations in
+// nanoseconds). Test is ["NONE"], ["CMD", args...] or ["CMD-SHELL", cmd].
+type Config struct {
+	Test        []string      `json:"Test,omitempty"`
+	Interval    time.Duration `json:"Interval,omitempty"`
+	Timeout     time.Duration `json:"Timeout,omitempty"`
+	StartPeriod time.Duration `json:"StartPeriod,omitempty"`
+	Retries     int           `json:"Retries,omitempty"`
+}
+
+// FromImage reads the HEALTHCHECK of an image (nil when it has none).
+// The OCI image spec has no such field, so the raw config is decoded.
+func FromImage(ctx context.Context, img containerd.Image) (*Config, error) {
+	desc, err := img.Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := content.ReadBlob(ctx, img.ContentStore(), desc)
+	if err != nil {
+		return nil, err
+	}
+	var raw struct {
+		Config struct {
+			Healthcheck *Config `json:"Healthcheck"`
+		} `json:"config"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid image config: %v", err)
+	}
+	return raw.Config.Healthcheck, nil
+}
+
+// Enabled reports whether the config describes a probe to run
+func (c *Config) Enabled() bool {
+	return c != nil && len(c.Test) > 0 && c.Test[0] != "NONE"
+}
+
+// Args returns the probe command line
+func (c *Config) Args() ([]string, error) {
+	switch {
+	case len(c.Test) >= 2 && c.Test[0] == "CMD":
+		return c.Test[1:], nil
+	case len(c.Test) == 2 && c.Test[0] == "CMD-SHELL":
+		return []string{"/bin/sh", "-c", c.Test[1]}, nil
+	}
+	return nil, fmt.Errorf("invalid health check test %q", c.Test)
+}
+
+// WithDefaults fills in the values left unset
+func (c Config) WithDefaults() Config {
+	if c.Interval <= 0 {
+		c.Interval = DefaultInterval
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultTimeout
+	}
+	if c.Retries <= 0 {
+		c.Retries = DefaultRetries
+	}
+	return c
+}
+
+// Result is the outcome of one probe
+type Result struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	ExitCode int       `json:"exitCode"` // -1 when the probe could not run or timed out
+	Output   string    `json:"output"`
+}
+
+// State is the health of a container as recorded by its monitor
+type State struct {
+	Status        string   `json:"status"`
+	FailingStreak int      `json:"failingStreak"`
+	Log           []Result `json:"log,omitempty"`
+}
+
+// Record applies a probe result. Failures during the start period do not
+// count towards Retries; the first success ends the start period.
+func (s *State) Record(cfg Config, started time.Time, r Result) {
+	if len(r.Output) > maxOutput {
+		r.Output = r.Output[:maxOutput]
+	}
+	s.Log = append(s.Log, r)
+	if len(s.Log) > maxLog {
+		s.Log = s.Log[len(s.Log)-maxLog:]
+	}
+
+	if r.ExitCode == 0 {
+		s.Status = Healthy
+		s.FailingStreak = 0
+		return
+	}
+	if s.Status == Starting && r.Start.Before(started.Add(cfg.StartPeriod)) {
+		return
+	}
+	s.FailingStreak++
+	if s.FailingStreak >= cfg.Retries {
+		s.Status = Unhealthy
+	}
+}
+
+// Path returns the state file of a container
+func Path(name string) string {
+	return filepath.Join(config.RunDir(), name, "health.json")
+}
+
+// Load reads the recorded health of a container (nil when it has none)
+func Load(name string) (*State, error) {
+	data, err := os.ReadFile(Path(name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &State{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("invalid health state %s: %v", Path(name), err)
+	}
+	return s, nil
+}
+
+// Save atomically writes the state of a container
+func (s *State) Save(name string) error {
+	path := Path(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,243 @@
+package image
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"time"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/diff"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/platforms"
+	"github.com/containerd/containerd/rootfs"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/identity"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Snapshotter is the snapshotter boxy creates container filesystems with
+const Snapshotter = containerd.DefaultSnapshotter
+
+// State is an image being assembled: its config plus layer blobs
+type State struct {
+	Config ocispec.Image
+	Layers []ocispec.Descriptor
+}
+
+// ChainID returns the snapshot chain ID of the layers added so far
+// ("" for an empty rootfs, e.g. FROM scratch)
+func (s *State) ChainID() string {
+	if len(s.Config.RootFS.DiffIDs) == 0 {
+		return ""
+	}
+	return identity.ChainID(s.Config.RootFS.DiffIDs).String()
+}
+
+// Scratch returns an empty image state for the host platform
+func Scratch() *State {
+	return &State{Config: ocispec.Image{
+		Platform: ocispec.Platform{OS: "linux", Architecture: runtime.GOARCH},
+		RootFS:   ocispec.RootFS{Type: "layers"},
+	}}
+}
+
+// Load reads the config and layer descriptors of an existing image and makes
+// sure its layers are unpacked so snapshots can be stacked on top
+func Load(ctx context.Context, img containerd.Image) (*State, error) {
+	cs := img.ContentStore()
+	manifest, err := images.Manifest(ctx, cs, img.Target(), platforms.Default())
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := img.Spec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if unpacked, err := img.IsUnpacked(ctx, Snapshotter); err != nil || !unpacked {
+		if err := img.Unpack(ctx, Snapshotter); err != nil {
+			return nil, fmt.Errorf("failed to unpack %s: %v", img.Name(), err)
+		}
+	}
+	return &State{Config: cfg, Layers: append([]ocispec.Descriptor(nil), manifest.Layers...)}, nil
+}
+
+// AddHistory appends a history entry that did not produce a layer
+func (s *State) AddHistory(createdBy, comment string) {
+	now := time.Now().UTC()
+	s.Config.History = append(s.Config.History, ocispec.History{
+		Created:    &now,
+		CreatedBy:  createdBy,
+		Comment:    comment,
+		EmptyLayer: true,
+	})
+}
+
+// AddLayer diffs the snapshot key (of the given snapshotter) against its
+// parent, stores the layer blob and records it without touching the snapshot
+func (s *State) AddLayer(ctx context.Context, c *containerd.Client, snapshotter, key, createdBy, comment string) error {
+	desc, err := rootfs.CreateDiff(ctx, key, c.SnapshotService(snapshotter), c.DiffService(),
+		diff.WithMediaType(ocispec.MediaTypeImageLayerGzip),
+		diff.WithReference("boxy-layer-"+key),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to diff snapshot: %v", err)
+	}
+	diffID, err := images.GetDiffID(ctx, c.ContentStore(), desc)
+	if err != nil {
+		return err
+	}
+	s.appendLayer(desc, diffID, createdBy, comment)
+	return nil
+}
+
+// AddTarLayer stores the uncompressed layer tar produced by write as a
+// gzip blob and records it. Unlike AddLayer it lets the caller rewrite the
+// tar stream; the lease on ctx keeps the blob until the image refers to it.
+func (s *State) AddTarLayer(ctx context.Context, c *containerd.Client, ref string, write func(io.Writer) error, createdBy, comment string) error {
+	cw, err := content.OpenWriter(ctx, c.ContentStore(), content.WithRef("boxy-layer-"+ref))
+	if err != nil {
+		return err
+	}
+	defer cw.Close()
+	if err := cw.Truncate(0); err != nil { // drop what an interrupted write left
+		return err
+	}
+
+	compressed := &countingWriter{w: cw}
+	uncompressed := digest.SHA256.Digester()
+	gz := gzip.NewWriter(compressed)
+	if err := write(io.MultiWriter(gz, uncompressed.Hash())); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	diffID := uncompressed.Digest()
+	desc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageLayerGzip,
+		Digest:    cw.Digest(),
+		Size:      compressed.n,
+	}
+	labels := map[string]string{"containerd.io/uncompressed": diffID.String()}
+	if err := cw.Commit(ctx, desc.Size, desc.Digest, content.WithLabels(labels)); err != nil && !errdefs.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to store layer: %v", err)
+	}
+	s.appendLayer(desc, diffID, createdBy, comment)
+	return nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (s *State) appendLayer(desc ocispec.Descriptor, diffID digest.Digest, createdBy, comment string) {
+	s.Layers = append(s.Layers, desc)
+	s.Config.RootFS.DiffIDs = append(s.Config.RootFS.DiffIDs, diffID)
+	now := time.Now().UTC()
+	s.Config.History = append(s.Config.History, ocispec.History{
+		Created:   &now,
+		CreatedBy: createdBy,
+		Comment:   comment,
+	})
+}
+
+// CommitLayer records the active snapshot key as a new layer and commits the
+// snapshot under the new chain ID
+func (s *State) CommitLayer(ctx context.Context, c *containerd.Client, key, createdBy, comment string) error {
+	if err := s.AddLayer(ctx, c, Snapshotter, key, createdBy, comment); err != nil {
+		return err
+	}
+
+	// the committed snapshot is named after the chain ID, which is what
+	// unpack looks for, so the new image needs no further unpacking
+	sn := c.SnapshotService(Snapshotter)
+	if err := sn.Commit(ctx, s.ChainID(), key); err != nil {
+		if !errdefs.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to commit snapshot: %v", err)
+		}
+		_ = sn.Remove(ctx, key)
+	}
+	return nil
+}
+
+// Write stores the image config and manifest in the content store and
+// creates (or moves) the image name to point at them
+func (s *State) Write(ctx context.Context, c *containerd.Client, name string, labels map[string]string) (containerd.Image, error) {
+	now := time.Now().UTC()
+	s.Config.Created = &now
+
+	configJSON, err := json.Marshal(s.Config)
+	if err != nil {
+		return nil, err
+	}
+	configDesc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageConfig,
+		Digest:    digest.FromBytes(configJSON),
+		Size:      int64(len(configJSON)),
+	}
+
+	manifest := ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    configDesc,
+		Layers:    s.Layers,
+	}
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	manifestDesc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Digest:    digest.FromBytes(manifestJSON),
+		Size:      int64(len(manifestJSON)),
+	}
+
+	// gc labels keep the config and layers alive while the manifest is
+	gcLabels := map[string]string{
+		"containerd.io/gc.ref.content.config": configDesc.Digest.String(),
+	}
+	for i, l := range s.Layers {
+		gcLabels[fmt.Sprintf("containerd.io/gc.ref.content.l.%d", i)] = l.Digest.String()
+	}
+
+	cs := c.ContentStore()
+	if err := content.WriteBlob(ctx, cs, "boxy-config-"+configDesc.Digest.String(),
+		bytes.NewReader(configJSON), configDesc); err != nil {
+		return nil, fmt.Errorf("failed to write image config: %v", err)
+	}
+	if err := content.WriteBlob(ctx, cs, "boxy-manifest-"+manifestDesc.Digest.String(),
+		bytes.NewReader(manifestJSON), manifestDesc, content.WithLabels(gcLabels)); err != nil {
+		return nil, fmt.Errorf("failed to write image manifest: %v", err)
+	}
+
+	record := images.Image{Name: name, Target: manifestDesc, Labels: labels}
+	is := c.ImageService()
+	stored, err := is.Create(ctx, record)
+	if errdefs.IsAlreadyExists(err) {
+		stored, err = is.Update(ctx, record)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	img := containerd.NewImage(c, stored)
+	if err := img.Unpack(ctx, Snapshotter); err != nil {
+		return nil, fmt.Errorf("failed to unpack %s: %v", name, err)
+	}
+	return img, nil
+}
@@ -0,0 +1,57 @@
+package labels
+
+// Container labels boxy stores alongside each container record
+const (
+	// Name is the container's name (--name or generated); the container
+	// ID is a random hex string
+	Name = "boxy.name"
+
+	// Privileged marks containers started with --privileged
+	Privileged = "boxy.privileged"
+
+	// Seccomp records the seccomp profile: default, unconfined or a path
+	Seccomp = "boxy.seccomp"
+
+	// UserNS records the --userns mode; UIDMap and GIDMap hold the id
+	// mappings of remapped containers as "container:host:size,..."
+	UserNS = "boxy.userns"
+	UIDMap = "boxy.userns.uidmap"
+	GIDMap = "boxy.userns.gidmap"
+
+	// Ports records the published ports as "HOST:CONT/PROTO,..."
+	Ports = "boxy.ports"
+
+	// Healthcheck holds the container's health check (image HEALTHCHECK
+	// merged with the --health-* flags) as JSON
+	Healthcheck = "boxy.healthcheck"
+
+	// RestartPolicy is the --restart policy; Restarts counts how often the
+	// container's task was restarted by it
+	RestartPolicy = "boxy.restart"
+	Restarts      = "boxy.restarts"
+
+	// Stopped marks containers stopped with boxy stop, which restart
+	// policies leave alone
+	Stopped = "boxy.stopped"
+
+	// AutoRemove marks detached --rm containers, which their monitor
+	// removes once the task exits
+	AutoRemove = "boxy.autoremove"
+
+	// StopSignal is the signal boxy stop sends first (--stop-signal or the
+	// image's STOPSIGNAL); StopTimeout is how long it waits before SIGKILL
+	StopSignal  = "boxy.stop-signal"
+	StopTimeout = "boxy.stop-timeout"
+
+	// ExitCode is the exit code of the container's last task, recorded
+	// when boxy sees it exit so that boxy wait can report it once the task
+	// is gone
+	ExitCode = "boxy.exit-code"
+)
+
+// Image labels
+const (
+	// SignaturePrefix + n points an image at the n-th cosign signature
+	// manifest fetched with it; the gc.ref prefix keeps the content alive
+	SignaturePrefix = "containerd.io/gc.ref.content.boxy.sig."
+)
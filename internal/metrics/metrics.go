@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"github.com/arnab2001/boxy/internal/stats"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Container is the state of one container at scrape time
+type Container struct {
+	Name     string
+	Image    string
+	Running  bool
+	ExitCode *uint32 // set once the task has exited
+	Restarts int
+	Ports    int
+	Sample   *stats.Sample // nil when the task is not running
+}
+
+var labelNames = []string{"name", "image"}
+
+func desc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc("boxy_container_"+name, help, labelNames, nil)
+}
+
+var (
+	runningDesc  = desc("running", "1 if the container's task is running")
+	exitCodeDesc = desc("exit_code", "Exit code of the container's last task")
+	restartsDesc = desc("restarts_total", "Number of times the container was restarted")
+	portsDesc    = desc("published_ports", "Number of published host ports")
+	cpuDesc      = desc("cpu_seconds_total", "CPU time consumed")
+	memoryDesc   = desc("memory_usage_bytes", "Memory usage excluding inactive page cache")
+	limitDesc    = desc("memory_limit_bytes", "Memory limit (absent when unlimited)")
+	pidsDesc     = desc("pids", "Number of processes")
+	blkReadDesc  = desc("block_read_bytes_total", "Bytes read from block devices")
+	blkWriteDesc = desc("block_write_bytes_total", "Bytes written to block devices")
+	netRxDesc    = desc("network_receive_bytes_total", "Bytes received on all interfaces but lo")
+	netTxDesc    = desc("network_transmit_bytes_total", "Bytes transmitted on all interfaces but lo")
+)
+
+// Collector exports the containers returned by List on every scrape
+type Collector struct {
+	List func() ([]Container, error)
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		runningDesc, exitCodeDesc, restartsDesc, portsDesc, cpuDesc, memoryDesc,
+		limitDesc, pidsDesc, blkReadDesc, blkWriteDesc, netRxDesc, netTxDesc,
+	} {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	containers, err := c.List()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(runningDesc, err)
+		return
+	}
+	for _, ct := range containers {
+		metric := func(d *prometheus.Desc, t prometheus.ValueType, v float64) {
+			ch <- prometheus.MustNewConstMetric(d, t, v, ct.Name, ct.Image)
+		}
+		running := 0.0
+		if ct.Running {
+			running = 1
+		}
+		metric(runningDesc, prometheus.GaugeValue, running)
+		if ct.ExitCode != nil {
+			metric(exitCodeDesc, prometheus.GaugeValue, float64(*ct.ExitCode))
+		}
+		metric(restartsDesc, prometheus.CounterValue, float64(ct.Restarts))
+		metric(portsDesc, prometheus.GaugeValue, float64(ct.Ports))
+
+		s := ct.Sample
+		if s == nil {
+			continue
+		}
+		metric(cpuDesc, prometheus.CounterValue, float64(s.CPU)/1e9)
+		metric(memoryDesc, prometheus.GaugeValue, float64(s.Memory))
+		if s.Limit > 0 {
+			metric(limitDesc, prometheus.GaugeValue, float64(s.Limit))
+		}
+		metric(pidsDesc, prometheus.GaugeValue, float64(s.Pids))
+		metric(blkReadDesc, prometheus.CounterValue, float64(s.BlkRead))
+		metric(blkWriteDesc, prometheus.CounterValue, float64(s.BlkWrite))
+		metric(netRxDesc, prometheus.CounterValue, float64(s.NetRx))
+		metric(netTxDesc, prometheus.CounterValue, float64(s.NetTx))
+	}
+}
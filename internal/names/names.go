@@ -0,0 +1,110 @@
+package names
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ShortID is the length of the IDs boxy ps shows
+const ShortID = 12
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Validate checks a --name: letters, digits, '_', '.' and '-', starting
+// with a letter or digit
+func Validate(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid container name %q (letters, digits, '_', '.' and '-', starting with a letter or digit)", name)
+	}
+	return nil
+}
+
+// NewID returns a random 64 hex digit container ID
+func NewID() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // the kernel's random source does not fail
+	}
+	return hex.EncodeToString(b)
+}
+
+var (
+	adjectives = []string{
+		"agile", "bold", "brave", "bright", "busy", "calm", "clever", "cool",
+		"crisp", "curious", "daring", "eager", "fancy", "fervent", "gentle",
+		"happy", "hopeful", "jolly", "keen", "kind", "lucid", "lucky", "merry",
+		"modest", "nifty", "noble", "patient", "plucky", "proud", "quick",
+		"quiet", "relaxed", "serene", "sharp", "snappy", "steady", "sunny",
+		"swift", "tender", "tidy", "vivid", "witty", "zealous",
+	}
+	surnames = []string{
+		"babbage", "bohr", "boole", "curie", "darwin", "dijkstra", "einstein",
+		"euler", "faraday", "fermi", "feynman", "galileo", "gauss", "goodall",
+		"hamilton", "hopper", "hypatia", "kepler", "knuth", "lamarr", "lovelace",
+		"maxwell", "meitner", "mendel", "newton", "noether", "pascal", "pasteur",
+		"planck", "ramanujan", "ritchie", "sagan", "shannon", "somerville",
+		"tesla", "thompson", "torvalds", "turing", "volta", "wozniak", "yalow",
+	}
+)
+
+// Generate returns a random adjective_surname name. retry > 0 appends it
+// as a number, for when the names drawn so far were taken.
+func Generate(retry int) string {
+	name := pick(adjectives) + "_" + pick(surnames)
+	if retry > 0 {
+		name += fmt.Sprint(retry)
+	}
+	return name
+}
+
+func pick(words []string) string {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(words))))
+	if err != nil {
+		panic(err)
+	}
+	return words[n.Int64()]
+}
+
+// Container is what Resolve knows about a container
+type Container struct {
+	ID   string
+	Name string
+}
+
+// Resolve finds the container ref refers to: a full ID, a name or a unique
+// ID prefix, in that order
+func Resolve(ref string, containers []Container) (Container, error) {
+	for _, c := range containers {
+		if c.ID == ref {
+			return c, nil
+		}
+	}
+	for _, c := range containers {
+		if c.Name == ref {
+			return c, nil
+		}
+	}
+	var matches []Container
+	for _, c := range containers {
+		if ref != "" && strings.HasPrefix(c.ID, ref) {
+			matches = append(matches, c)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Container{}, fmt.Errorf("no such container: %s", ref)
+	case 1:
+		return matches[0], nil
+	}
+	var found []string
+	for _, c := range matches {
+		found = append(found, fmt.Sprintf("%s (%s)", c.ID[:min(len(c.ID), ShortID)], c.Name))
+	}
+	sort.Strings(found)
+	return Container{}, fmt.Errorf("%q matches %d containers: %s (use a longer ID or the name)", ref, len(matches), strings.Join(found, ", "))
+}
@@ -0,0 +1,92 @@
+package oci
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/containerd/containerd/oci"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// cfsPeriod is the CFS scheduling period --cpus quotas are expressed in (100ms)
+const cfsPeriod = 100000
+
+// ResourceOptions are the run flags limiting container resources
+type ResourceOptions struct {
+	Memory int64   // bytes, 0 = unlimited
+	CPUs   float64 // number of CPUs, 0 = unlimited
+}
+
+// ParseMemory parses sizes such as "512m", "1g", "64KiB" or "1048576"
+func ParseMemory(s string) (int64, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	v = strings.TrimSuffix(strings.TrimSuffix(v, "ib"), "b")
+	mult := int64(1)
+	if n := len(v); n > 0 {
+		switch v[n-1] {
+		case 'k':
+			mult = 1 << 10
+		case 'm':
+			mult = 1 << 20
+		case 'g':
+			mult = 1 << 30
+		case 't':
+			mult = 1 << 40
+		}
+		if mult > 1 {
+			v = v[:n-1]
+		}
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q (e.g. 512m, 2g)", s)
+	}
+	return int64(n * float64(mult)), nil
+}
+
+// WithResources converts resource limits into spec options
+func WithResources(o ResourceOptions) []oci.SpecOpts {
+	var opts []oci.SpecOpts
+	if o.Memory > 0 {
+		opts = append(opts, oci.WithMemoryLimit(uint64(o.Memory)))
+	}
+	if o.CPUs > 0 {
+		opts = append(opts, oci.WithCPUCFS(int64(o.CPUs*cfsPeriod), cfsPeriod))
+	}
+	return opts
+}
+
+// ParseVolume parses a -v HOST:CONTAINER[:ro|rw] bind mount; the host path
+// is made absolute and its symlinks resolved
+func ParseVolume(s string) (specs.Mount, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return specs.Mount{}, fmt.Errorf("invalid volume %q (expected HOST:CONTAINER[:ro])", s)
+	}
+	mode := "rw"
+	if len(parts) == 3 {
+		if parts[2] != "ro" && parts[2] != "rw" {
+			return specs.Mount{}, fmt.Errorf("invalid volume mode %q (expected ro or rw)", parts[2])
+		}
+		mode = parts[2]
+	}
+	if !filepath.IsAbs(parts[1]) {
+		return specs.Mount{}, fmt.Errorf("volume destination %q must be absolute", parts[1])
+	}
+
+	host, err := filepath.Abs(parts[0])
+	if err != nil {
+		return specs.Mount{}, err
+	}
+	if host, err = filepath.EvalSymlinks(host); err != nil {
+		return specs.Mount{}, fmt.Errorf("volume source: %v", err)
+	}
+	return specs.Mount{
+		Type:        "bind",
+		Source:      host,
+		Destination: filepath.Clean(parts[1]),
+		Options:     []string{"rbind", mode},
+	}, nil
+}
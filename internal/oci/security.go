@@ -0,0 +1,262 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/arnab2001/boxy/internal/seccomp"
+	"github.com/containerd/containerd/containers"
+	"github.com/containerd/containerd/namespaces"
+	"github.com/containerd/containerd/oci"
+	"github.com/containerd/containerd/pkg/cap"
+)
+
+// SecurityOptions are the run flags controlling container privileges
+type SecurityOptions struct {
+	CapAdd          []string
+	CapDrop         []string
+	Privileged      bool
+	NoNewPrivileges bool
+	ReadOnly        bool
+	Devices         []string // HOST[:CONTAINER[:PERMS]]
+	Seccomp         string   // "" (built-in default), "unconfined" or a profile path
+}
+
+// SeccompLabel describes the seccomp setting for the container's labels
+func (o SecurityOptions) SeccompLabel() string {
+	switch {
+	case o.Seccomp != "":
+		return o.Seccomp
+	case o.Privileged:
+		return "unconfined"
+	default:
+		return "default"
+	}
+}
+
+// NormalizeCap turns "net_admin" / "CAP_NET_ADMIN" into "CAP_NET_ADMIN";
+// "ALL" is passed through
+func NormalizeCap(c string) (string, error) {
+	c = strings.ToUpper(strings.TrimSpace(c))
+	if c == "ALL" {
+		return c, nil
+	}
+	if !strings.HasPrefix(c, "CAP_") {
+		c = "CAP_" + c
+	}
+	for _, known := range cap.Known() {
+		if c == known {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("unknown capability: %s", c)
+}
+
+func normalizeCaps(caps []string) (list []string, all bool, err error) {
+	for _, c := range caps {
+		n, err := NormalizeCap(c)
+		if err != nil {
+			return nil, false, err
+		}
+		if n == "ALL" {
+			all = true
+			continue
+		}
+		list = append(list, n)
+	}
+	return list, all, nil
+}
+
+// DefaultCaps returns the capabilities of containerd's default spec, which
+// every container starts from
+func DefaultCaps() ([]string, error) {
+	ctx := namespaces.WithNamespace(context.Background(), namespaces.Default)
+	var s oci.Spec
+	if err := oci.WithDefaultSpec()(ctx, nil, &containers.Container{}, &s); err != nil {
+		return nil, err
+	}
+	if s.Process == nil || s.Process.Capabilities == nil {
+		return nil, nil
+	}
+	return s.Process.Capabilities.Bounding, nil
+}
+
+// EffectiveCaps returns the capabilities WithSecurity leaves a container
+// that is not privileged: the defaults (none after --cap-drop ALL), plus
+// --cap-add, minus --cap-drop. --cap-add ALL counts as every known
+// capability.
+func EffectiveCaps(o SecurityOptions) ([]string, error) {
+	add, addAll, err := normalizeCaps(o.CapAdd)
+	if err != nil {
+		return nil, err
+	}
+	drop, dropAll, err := normalizeCaps(o.CapDrop)
+	if err != nil {
+		return nil, err
+	}
+	var base []string
+	switch {
+	case addAll:
+		base = cap.Known()
+	case !dropAll:
+		if base, err = DefaultCaps(); err != nil {
+			return nil, err
+		}
+	}
+	dropped := map[string]bool{}
+	if !dropAll {
+		// --cap-drop wins over --cap-add, as in WithSecurity
+		for _, c := range drop {
+			dropped[c] = true
+		}
+	}
+	var caps []string
+	seen := map[string]bool{}
+	for _, c := range append(base, add...) {
+		if !dropped[c] && !seen[c] {
+			seen[c] = true
+			caps = append(caps, c)
+		}
+	}
+	return caps, nil
+}
+
+// ParseSecurityOpt applies one --security-opt value
+func ParseSecurityOpt(o *SecurityOptions, opt string) error {
+	key, value, hasValue := strings.Cut(opt, "=")
+	if !hasValue {
+		key, value, hasValue = strings.Cut(opt, ":")
+	}
+	switch key {
+	case "seccomp":
+		if !hasValue || value == "" {
+			return fmt.Errorf("invalid --security-opt %s: expected seccomp=<profile.json|unconfined>", opt)
+		}
+		o.Seccomp = value
+		return nil
+	case "no-new-privileges":
+		if !hasValue {
+			o.NoNewPrivileges = true
+			return nil
+		}
+		switch value {
+		case "true":
+			o.NoNewPrivileges = true
+		case "false":
+			o.NoNewPrivileges = false
+		default:
+			return fmt.Errorf("invalid --security-opt %s: expected true or false", opt)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported --security-opt: %s", opt)
+	}
+}
+
+// ParseDevice splits a --device value into host path, container path and
+// cgroup permissions (default rwm)
+func ParseDevice(spec string) (host, container, perms string, err error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) > 3 || parts[0] == "" {
+		return "", "", "", fmt.Errorf("invalid device %q (expected HOST[:CONTAINER[:PERMS]])", spec)
+	}
+	host, container, perms = parts[0], parts[0], "rwm"
+	switch len(parts) {
+	case 2:
+		if isDevicePerms(parts[1]) {
+			perms = parts[1]
+		} else {
+			container = parts[1]
+		}
+	case 3:
+		container, perms = parts[1], parts[2]
+	}
+	if !isDevicePerms(perms) {
+		return "", "", "", fmt.Errorf("invalid device permissions %q in %q", perms, spec)
+	}
+	if !strings.HasPrefix(container, "/") {
+		return "", "", "", fmt.Errorf("device container path must be absolute: %s", container)
+	}
+	return host, container, perms, nil
+}
+
+func isDevicePerms(s string) bool {
+	if s == "" || len(s) > 3 {
+		return false
+	}
+	for _, r := range s {
+		if r != 'r' && r != 'w' && r != 'm' {
+			return false
+		}
+	}
+	return true
+}
+
+// WithSecurity translates the security flags into OCI spec options
+func WithSecurity(o SecurityOptions) ([]oci.SpecOpts, error) {
+	var opts []oci.SpecOpts
+
+	if o.Privileged {
+		opts = append(opts, oci.WithPrivileged, oci.WithHostDevices, oci.WithAllDevicesAllowed)
+	}
+
+	add, addAll, err := normalizeCaps(o.CapAdd)
+	if err != nil {
+		return nil, err
+	}
+	drop, dropAll, err := normalizeCaps(o.CapDrop)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case o.Privileged:
+		// like docker, --privileged keeps every capability whatever
+		// --cap-add and --cap-drop say
+	case dropAll:
+		// --cap-drop ALL starts from nothing; --cap-add re-adds
+		opts = append(opts, oci.WithCapabilities(nil))
+		if addAll {
+			opts = append(opts, oci.WithAllCurrentCapabilities)
+		}
+		opts = append(opts, oci.WithAddedCapabilities(add))
+	default:
+		if addAll {
+			opts = append(opts, oci.WithAllCurrentCapabilities)
+		}
+		opts = append(opts, oci.WithAddedCapabilities(add), oci.WithDroppedCapabilities(drop))
+	}
+
+	if o.NoNewPrivileges {
+		opts = append(opts, oci.WithNoNewPrivileges)
+	}
+	if o.ReadOnly {
+		opts = append(opts, oci.WithRootFSReadonly())
+	}
+	for _, d := range o.Devices {
+		host, container, perms, err := ParseDevice(d)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(host); err != nil {
+			return nil, fmt.Errorf("device %s: %v", host, err)
+		}
+		opts = append(opts, oci.WithDevices(host, container, perms))
+	}
+
+	// seccomp goes last: conditional rules depend on the final capabilities
+	switch {
+	case o.Seccomp == "unconfined":
+		opts = append(opts, oci.WithSeccompUnconfined)
+	case o.Seccomp != "":
+		profile, err := seccomp.Load(o.Seccomp)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, seccomp.WithProfile(profile))
+	case !o.Privileged:
+		opts = append(opts, seccomp.WithProfile(seccomp.Default()))
+	}
+	return opts, nil
+}
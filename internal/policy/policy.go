@@ -0,0 +1,134 @@
+package policy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/arnab2001/boxy/internal/config"
+	refdocker "github.com/containerd/containerd/reference/docker"
+	"gopkg.in/yaml.v3"
+)
+
+// Policy is the host-level policy file (policy.yaml)
+type Policy struct {
+	Images Images `yaml:"images,omitempty"`
+	Run    Run    `yaml:"run,omitempty"`
+}
+
+// Images restricts which images may be pulled and run. Scopes are
+// repository prefixes matched on path boundaries: "docker.io" covers all of
+// Docker Hub, "docker.io/library" the official images,
+// "docker.io/library/nginx" a single repository.
+type Images struct {
+	Allowed       []string        `yaml:"allowed,omitempty"` // empty allows every scope not blocked
+	Blocked       []string        `yaml:"blocked,omitempty"`
+	RequireDigest []string        `yaml:"requireDigest,omitempty"`
+	Signatures    []SignatureRule `yaml:"signatures,omitempty"`
+}
+
+// SignatureRule requires images in Scope to carry a cosign signature made
+// by one of Keys (PEM public key files)
+type SignatureRule struct {
+	Scope string   `yaml:"scope"`
+	Keys  []string `yaml:"keys"`
+}
+
+// Denial is returned when a policy rule rejects an image or container
+type Denial struct {
+	Subject string // e.g. "image docker.io/library/nginx:latest"
+	Rule    string // e.g. "images.blocked[docker.io/library]"
+	Reason  string
+}
+
+func (d *Denial) Error() string {
+	return fmt.Sprintf("policy denied %s: %s (rule %s)", d.Subject, d.Reason, d.Rule)
+}
+
+// Path returns the policy file location ($BOXY_POLICY overrides the default)
+func Path() string {
+	if p := os.Getenv("BOXY_POLICY"); p != "" {
+		return p
+	}
+	return filepath.Join(config.Dir(), "policy.yaml")
+}
+
+// Load reads the policy file; a missing file yields an empty policy that
+// allows everything
+func Load() (*Policy, error) {
+	return LoadFile(Path())
+}
+
+// LoadFile reads the policy from an explicit path
+func LoadFile(path string) (*Policy, error) {
+	p := &Policy{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy %s: %v", path, err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(p); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("invalid policy %s: %v", path, err)
+	}
+	for i, r := range p.Images.Signatures {
+		if r.Scope == "" || len(r.Keys) == 0 {
+			return nil, fmt.Errorf("invalid policy %s: images.signatures[%d] needs a scope and keys", path, i)
+		}
+	}
+	return p, nil
+}
+
+// InScope reports whether the repository name falls under scope
+func InScope(name, scope string) bool {
+	scope = strings.TrimSuffix(scope, "/")
+	return name == scope || strings.HasPrefix(name, scope+"/")
+}
+
+// CheckReference applies the registry and digest rules to a normalised
+// reference; it needs no network access
+func (p *Policy) CheckReference(named refdocker.Named) error {
+	name := named.Name()
+	subject := "image " + named.String()
+
+	for _, scope := range p.Images.Blocked {
+		if InScope(name, scope) {
+			return &Denial{subject, "images.blocked[" + scope + "]", name + " is blocked"}
+		}
+	}
+	if len(p.Images.Allowed) > 0 {
+		allowed := false
+		for _, scope := range p.Images.Allowed {
+			if InScope(name, scope) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return &Denial{subject, "images.allowed", name + " is not in an allowed registry"}
+		}
+	}
+	for _, scope := range p.Images.RequireDigest {
+		if _, ok := named.(refdocker.Digested); !ok && InScope(name, scope) {
+			return &Denial{subject, "images.requireDigest[" + scope + "]", "reference must be pinned by digest (name@sha256:...)"}
+		}
+	}
+	return nil
+}
+
+// SignatureRules returns the signature rules covering a repository
+func (p *Policy) SignatureRules(name string) []SignatureRule {
+	var rules []SignatureRule
+	for _, r := range p.Images.Signatures {
+		if InScope(name, r.Scope) {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
@@ -0,0 +1,126 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Run restricts the options containers may be started with
+type Run struct {
+	DenyPrivileged  bool `yaml:"denyPrivileged,omitempty"`
+	DenyHostNetwork bool `yaml:"denyHostNetwork,omitempty"`
+
+	// AllowedMounts lists the host path prefixes -v may bind. When the key
+	// is present (even as []) every other host path is denied.
+	AllowedMounts []string `yaml:"allowedMounts"`
+
+	// MinHostPort is the lowest host port -p may publish
+	MinHostPort int `yaml:"minHostPort,omitempty"`
+
+	// RequireLimits denies containers started without --memory and --cpus
+	RequireLimits bool `yaml:"requireLimits,omitempty"`
+
+	// DeniedCapabilities may not be held by containers (CAP_ prefix
+	// optional): they must not be added with --cap-add, and ones granted
+	// by default must be removed with --cap-drop
+	DeniedCapabilities []string `yaml:"deniedCapabilities,omitempty"`
+}
+
+// RunRequest describes a container about to be created
+type RunRequest struct {
+	Name        string
+	Privileged  bool
+	HostNetwork bool
+	Mounts      []string // host paths, absolute with symlinks resolved
+	HostPorts   []int
+	Memory      int64
+	CPUs        float64
+	CapAdd      []string // normalised (CAP_NET_ADMIN, ALL)
+	Caps        []string // effective set: defaults + CapAdd - CapDrop
+}
+
+// CheckRun returns every run rule the request violates
+func (p *Policy) CheckRun(r RunRequest) []*Denial {
+	var denials []*Denial
+	subject := "container"
+	if r.Name != "" {
+		subject += " " + r.Name
+	}
+	deny := func(rule, reason string) {
+		denials = append(denials, &Denial{subject, "run." + rule, reason})
+	}
+
+	if p.Run.DenyPrivileged && r.Privileged {
+		deny("denyPrivileged", "--privileged is not allowed")
+	}
+	if p.Run.DenyHostNetwork && r.HostNetwork {
+		deny("denyHostNetwork", "--network host is not allowed")
+	}
+	if p.Run.AllowedMounts != nil {
+		for _, m := range r.Mounts {
+			allowed := false
+			for _, prefix := range p.Run.AllowedMounts {
+				if prefix == "/" || InScope(m, prefix) {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				deny("allowedMounts", fmt.Sprintf("host path %s is outside %s", m, strings.Join(p.Run.AllowedMounts, ", ")))
+			}
+		}
+	}
+	if p.Run.MinHostPort > 0 {
+		for _, port := range r.HostPorts {
+			if port < p.Run.MinHostPort {
+				deny("minHostPort", fmt.Sprintf("host port %d is below %d", port, p.Run.MinHostPort))
+			}
+		}
+	}
+	if p.Run.RequireLimits {
+		if r.Memory <= 0 {
+			deny("requireLimits", "--memory must be set")
+		}
+		if r.CPUs <= 0 {
+			deny("requireLimits", "--cpus must be set")
+		}
+	}
+	for _, denied := range p.Run.DeniedCapabilities {
+		name := strings.ToUpper(denied)
+		if !strings.HasPrefix(name, "CAP_") {
+			name = "CAP_" + name
+		}
+		if r.Privileged {
+			deny("deniedCapabilities", "--privileged grants "+name)
+			continue
+		}
+		if !contains(r.Caps, name) {
+			continue
+		}
+		if contains(r.CapAdd, name) || contains(r.CapAdd, "ALL") {
+			deny("deniedCapabilities", name+" may not be added")
+		} else {
+			deny("deniedCapabilities", name+" is granted by default (drop it with --cap-drop)")
+		}
+	}
+	return denials
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Err joins denials into one error (nil when there are none)
+func Err(denials []*Denial) error {
+	errs := make([]error, len(denials))
+	for i, d := range denials {
+		errs[i] = d
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,186 @@
+package policy
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/containerd/containerd/content"
+	refdocker "github.com/containerd/containerd/reference/docker"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// cosign signature artifacts: a manifest whose layers are simple-signing
+// payloads, each carrying its base64 signature in an annotation
+const (
+	SignatureArtifactType  = "application/vnd.dev.cosign.artifact.sig.v1+json"
+	SimpleSigningMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	SignatureAnnotation    = "dev.cosignproject.cosign/signature"
+)
+
+// simpleSigning is the signed payload ("cosign container image signature")
+type simpleSigning struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+// LoadKey reads a PEM-encoded public key (ECDSA, RSA or Ed25519)
+func LoadKey(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("%s: no PEM public key found", path)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return key, nil
+}
+
+// VerifyPayload checks sig over payload with key and that the payload
+// vouches for the manifest dgst of repository name
+func VerifyPayload(payload, sig []byte, key crypto.PublicKey, name string, dgst digest.Digest) error {
+	sum := sha256.Sum256(payload)
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(k, sum[:], sig) {
+			return fmt.Errorf("invalid signature")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], sig); err != nil {
+			return fmt.Errorf("invalid signature")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, payload, sig) {
+			return fmt.Errorf("invalid signature")
+		}
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+
+	var p simpleSigning
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return fmt.Errorf("invalid signature payload: %v", err)
+	}
+	if p.Critical.Type != "cosign container image signature" {
+		return fmt.Errorf("unexpected payload type %q", p.Critical.Type)
+	}
+	if p.Critical.Image.DockerManifestDigest != dgst.String() {
+		return fmt.Errorf("signature is for %s, not %s", p.Critical.Image.DockerManifestDigest, dgst)
+	}
+	if identity := normalizeIdentity(p.Critical.Identity.DockerReference); identity != name {
+		return fmt.Errorf("signature is for repository %s, not %s", identity, name)
+	}
+	return nil
+}
+
+// normalizeIdentity maps cosign's docker-reference ("index.docker.io/library/nginx")
+// onto containerd's repository names ("docker.io/library/nginx")
+func normalizeIdentity(ref string) string {
+	ref = strings.TrimPrefix(ref, "index.")
+	named, err := refdocker.ParseNormalizedNamed(ref)
+	if err != nil {
+		return ref
+	}
+	return named.Name()
+}
+
+// VerifySignatures enforces the signature rules covering named against the
+// signature manifests sigs (as stored by registry.FetchSignatures) for the
+// image manifest dgst
+func (p *Policy) VerifySignatures(ctx context.Context, store content.Provider, named refdocker.Named, dgst digest.Digest, sigs []ocispec.Descriptor) error {
+	rules := p.SignatureRules(named.Name())
+	if len(rules) == 0 {
+		return nil
+	}
+	signatures, err := readSignatures(ctx, store, sigs)
+	if err != nil {
+		return err
+	}
+
+	subject := "image " + named.String()
+	for _, r := range rules {
+		rule := "images.signatures[" + r.Scope + "]"
+		if len(signatures) == 0 {
+			return &Denial{subject, rule, "no cosign signature found for " + dgst.String()}
+		}
+		var lastErr error
+		verified := false
+		for _, path := range r.Keys {
+			key, err := LoadKey(path)
+			if err != nil {
+				return &Denial{subject, rule, err.Error()}
+			}
+			for _, s := range signatures {
+				if lastErr = VerifyPayload(s.payload, s.sig, key, named.Name(), dgst); lastErr == nil {
+					verified = true
+					break
+				}
+			}
+			if verified {
+				break
+			}
+		}
+		if !verified {
+			return &Denial{subject, rule, fmt.Sprintf("no signature verified with %s: %v", strings.Join(r.Keys, ", "), lastErr)}
+		}
+	}
+	return nil
+}
+
+type signature struct {
+	payload []byte
+	sig     []byte
+}
+
+func readSignatures(ctx context.Context, store content.Provider, manifests []ocispec.Descriptor) ([]signature, error) {
+	var out []signature
+	for _, desc := range manifests {
+		data, err := content.ReadBlob(ctx, store, desc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read signature manifest %s: %v", desc.Digest, err)
+		}
+		var m ocispec.Manifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("invalid signature manifest %s: %v", desc.Digest, err)
+		}
+		for _, layer := range m.Layers {
+			b64, ok := layer.Annotations[SignatureAnnotation]
+			if layer.MediaType != SimpleSigningMediaType || !ok {
+				continue
+			}
+			sig, err := base64.StdEncoding.DecodeString(b64)
+			if err != nil {
+				continue
+			}
+			payload, err := content.ReadBlob(ctx, store, layer)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read signature payload %s: %v", layer.Digest, err)
+			}
+			out = append(out, signature{payload: payload, sig: sig})
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,74 @@
+package ports
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Mapping matches the CNI portmap plugin's expected structure
+// https://www.cni.dev/plugins/current/meta/portmap/
+type Mapping struct {
+	HostPort      int32  `json:"hostPort"`
+	ContainerPort int32  `json:"containerPort"`
+	Protocol      string `json:"protocol,omitempty"`
+	HostIP        string `json:"hostIP,omitempty"`
+}
+
+// Parse parses -p/--publish flags into Mapping structs
+func Parse(flags []string) ([]Mapping, error) {
+	var mappings []Mapping
+	for _, flag := range flags {
+		proto := "tcp" // default protocol
+		hostPortStr := ""
+		contPortProto := ""
+
+		parts := strings.SplitN(flag, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid port mapping (expected HOST:CONT): %s", flag)
+		}
+		hostPortStr = parts[0]
+		contPortProto = parts[1]
+
+		contParts := strings.SplitN(contPortProto, "/", 2)
+		contPortStr := contParts[0]
+		if len(contParts) == 2 {
+			proto = strings.ToLower(contParts[1])
+			if proto != "tcp" && proto != "udp" {
+				return nil, fmt.Errorf("unsupported protocol: %s", proto)
+			}
+		}
+
+		hostPort, err1 := parsePortNum(hostPortStr)
+		contPort, err2 := parsePortNum(contPortStr)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("invalid port numbers in: %s", flag)
+		}
+
+		mappings = append(mappings, Mapping{
+			HostPort:      hostPort,
+			ContainerPort: contPort,
+			Protocol:      proto,
+		})
+	}
+	return mappings, nil
+}
+
+// Format is the inverse of Parse, used to record the mappings in the
+// container's labels
+func Format(mappings []Mapping) string {
+	specs := make([]string, len(mappings))
+	for i, m := range mappings {
+		specs[i] = fmt.Sprintf("%d:%d/%s", m.HostPort, m.ContainerPort, m.Protocol)
+	}
+	return strings.Join(specs, ",")
+}
+
+// parsePortNum parses a string port number
+func parsePortNum(s string) (int32, error) {
+	p, err := strconv.Atoi(s)
+	if err != nil || p < 1 || p > 65535 {
+		return 0, fmt.Errorf("invalid port: %s", s)
+	}
+	return int32(p), nil
+}
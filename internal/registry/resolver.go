@@ -0,0 +1,162 @@
+package registry
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/arnab2001/boxy/internal/config"
+	"github.com/containerd/containerd/remotes"
+	"github.com/containerd/containerd/remotes/docker"
+	dockerconfig "github.com/containerd/containerd/remotes/docker/config"
+)
+
+// NewResolver returns a resolver honouring boxy's registry configuration:
+// endpoints from config.json first, then hosts.toml files under ConfigPath,
+// then the registry's default endpoint
+func NewResolver(ctx context.Context, cfg *config.Config) remotes.Resolver {
+	return docker.NewResolver(docker.ResolverOptions{
+		Hosts: Hosts(ctx, cfg),
+	})
+}
+
+// Hosts builds the RegistryHosts lookup used by NewResolver
+func Hosts(ctx context.Context, cfg *config.Config) docker.RegistryHosts {
+	return docker.Registries(
+		configuredHosts(cfg.Registry.Hosts),
+		dockerconfig.ConfigureHosts(ctx, dockerconfig.HostOptions{
+			HostDir: dockerconfig.HostDirFromRoot(cfg.Registry.ConfigPath),
+		}),
+	)
+}
+
+// configuredHosts resolves hosts listed in config.json; an empty result lets
+// docker.Registries fall through to the next lookup
+func configuredHosts(hosts map[string]config.RegistryHost) docker.RegistryHosts {
+	return func(host string) ([]docker.RegistryHost, error) {
+		rh, ok := hosts[host]
+		if !ok {
+			return nil, nil
+		}
+
+		var out []docker.RegistryHost
+		for _, m := range rh.Mirrors {
+			h, err := endpointHost(m, docker.HostCapabilityPull|docker.HostCapabilityResolve)
+			if err != nil {
+				return nil, fmt.Errorf("registry %s: %v", host, err)
+			}
+			out = append(out, h)
+		}
+		if rh.MirrorsOnly {
+			return out, nil
+		}
+
+		server := config.Endpoint{Host: host}
+		if rh.Server != nil {
+			server = *rh.Server
+		}
+		if server.Host == "docker.io" {
+			server.Host = "registry-1.docker.io"
+		}
+		h, err := endpointHost(server, docker.HostCapabilityPull|docker.HostCapabilityResolve|docker.HostCapabilityPush)
+		if err != nil {
+			return nil, fmt.Errorf("registry %s: %v", host, err)
+		}
+		return append(out, h), nil
+	}
+}
+
+// endpointHost turns a configured endpoint into a docker.RegistryHost
+func endpointHost(ep config.Endpoint, defaultCaps docker.HostCapabilities) (docker.RegistryHost, error) {
+	raw := ep.Host
+	if !strings.Contains(raw, "://") {
+		scheme := "https"
+		if ep.PlainHTTP {
+			scheme = "http"
+		}
+		raw = scheme + "://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return docker.RegistryHost{}, fmt.Errorf("invalid endpoint %q", ep.Host)
+	}
+
+	path := strings.TrimSuffix(u.Path, "/")
+	if path == "" {
+		path = "/v2"
+	}
+
+	caps := defaultCaps
+	if len(ep.Capabilities) > 0 {
+		caps = 0
+		for _, c := range ep.Capabilities {
+			switch strings.ToLower(c) {
+			case "pull":
+				caps |= docker.HostCapabilityPull
+			case "resolve":
+				caps |= docker.HostCapabilityResolve
+			case "push":
+				caps |= docker.HostCapabilityPush
+			default:
+				return docker.RegistryHost{}, fmt.Errorf("unknown capability %q for %s", c, ep.Host)
+			}
+		}
+	}
+
+	tlsConfig, err := endpointTLS(ep)
+	if err != nil {
+		return docker.RegistryHost{}, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	client := &http.Client{Transport: transport}
+
+	return docker.RegistryHost{
+		Client:       client,
+		Authorizer:   docker.NewDockerAuthorizer(docker.WithAuthClient(client)),
+		Host:         u.Host,
+		Scheme:       u.Scheme,
+		Path:         path,
+		Capabilities: caps,
+	}, nil
+}
+
+// endpointTLS builds the TLS client config (CAs, client cert, skip-verify)
+func endpointTLS(ep config.Endpoint) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: ep.SkipVerify} //nolint:gosec // opt-in per endpoint
+
+	if len(ep.CA) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		for _, ca := range ep.CA {
+			data, err := os.ReadFile(ca)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read CA %s: %v", ca, err)
+			}
+			if !pool.AppendCertsFromPEM(data) {
+				return nil, fmt.Errorf("no certificates found in %s", ca)
+			}
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if ep.ClientCert != "" || ep.ClientKey != "" {
+		if ep.ClientCert == "" || ep.ClientKey == "" {
+			return nil, fmt.Errorf("clientCert and clientKey must be set together for %s", ep.Host)
+		}
+		pair, err := tls.LoadX509KeyPair(ep.ClientCert, ep.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate for %s: %v", ep.Host, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{pair}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,120 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/arnab2001/boxy/internal/policy"
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/remotes"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// maxSignatureBlob bounds the size of signature manifests and payloads
+const maxSignatureBlob = 4 << 20
+
+// FetchSignatures downloads the cosign signature manifests (and their
+// payloads) of the manifest dgst in repository name into store. It looks at
+// the OCI referrers tag (sha256-<hex>, an index of referrers) and cosign's
+// legacy signature tag (sha256-<hex>.sig).
+func FetchSignatures(ctx context.Context, resolver remotes.Resolver, name string, dgst digest.Digest, store content.Ingester) ([]ocispec.Descriptor, error) {
+	tag := strings.Replace(dgst.String(), ":", "-", 1)
+
+	var manifests []ocispec.Descriptor
+	for _, ref := range []string{name + ":" + tag, name + ":" + tag + ".sig"} {
+		_, desc, err := resolver.Resolve(ctx, ref)
+		if errdefs.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %v", ref, err)
+		}
+		fetcher, err := resolver.Fetcher(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		data, err := fetchBlob(ctx, fetcher, desc)
+		if err != nil {
+			return nil, err
+		}
+
+		if !images.IsIndexType(desc.MediaType) {
+			manifests = append(manifests, desc)
+			continue
+		}
+		var index ocispec.Index
+		if err := json.Unmarshal(data, &index); err != nil {
+			return nil, fmt.Errorf("invalid referrers index %s: %v", ref, err)
+		}
+		for _, m := range index.Manifests {
+			if m.ArtifactType == policy.SignatureArtifactType {
+				manifests = append(manifests, m)
+			}
+		}
+	}
+
+	var stored []ocispec.Descriptor
+	for _, desc := range manifests {
+		if err := storeSignature(ctx, resolver, name, desc, store); err != nil {
+			return nil, err
+		}
+		stored = append(stored, ocispec.Descriptor{MediaType: desc.MediaType, Digest: desc.Digest, Size: desc.Size})
+	}
+	return stored, nil
+}
+
+// storeSignature copies a signature manifest and its layers into store; the
+// manifest is labelled so garbage collection keeps its layers
+func storeSignature(ctx context.Context, resolver remotes.Resolver, name string, desc ocispec.Descriptor, store content.Ingester) error {
+	fetcher, err := resolver.Fetcher(ctx, name+"@"+desc.Digest.String())
+	if err != nil {
+		return err
+	}
+	data, err := fetchBlob(ctx, fetcher, desc)
+	if err != nil {
+		return err
+	}
+	var m ocispec.Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("invalid signature manifest %s: %v", desc.Digest, err)
+	}
+
+	labels := map[string]string{}
+	for i, layer := range m.Layers {
+		blob, err := fetchBlob(ctx, fetcher, layer)
+		if err != nil {
+			return err
+		}
+		if err := content.WriteBlob(ctx, store, layer.Digest.String(), bytes.NewReader(blob), layer); err != nil {
+			return err
+		}
+		labels[fmt.Sprintf("containerd.io/gc.ref.content.l.%d", i)] = layer.Digest.String()
+	}
+	return content.WriteBlob(ctx, store, desc.Digest.String(), bytes.NewReader(data), desc, content.WithLabels(labels))
+}
+
+func fetchBlob(ctx context.Context, fetcher remotes.Fetcher, desc ocispec.Descriptor) ([]byte, error) {
+	if desc.Size > maxSignatureBlob {
+		return nil, fmt.Errorf("signature blob %s too large (%d bytes)", desc.Digest, desc.Size)
+	}
+	rc, err := fetcher.Fetch(ctx, desc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %v", desc.Digest, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxSignatureBlob+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != desc.Size || digest.FromBytes(data) != desc.Digest {
+		return nil, fmt.Errorf("signature blob %s failed verification", desc.Digest)
+	}
+	return data, nil
+}
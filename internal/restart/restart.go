@@ -0,0 +1,130 @@
+package restart
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Restart policy names, as docker spells them
+const (
+	No            = "no"
+	OnFailure     = "on-failure"
+	Always        = "always"
+	UnlessStopped = "unless-stopped"
+)
+
+// Backoff bounds: the delay doubles from minDelay up to maxDelay and starts
+// over once a container stayed up for resetAfter
+const (
+	minDelay   = 100 * time.Millisecond
+	maxDelay   = time.Minute
+	resetAfter = 10 * time.Second
+)
+
+// Policy decides whether an exited container is started again
+type Policy struct {
+	Name       string
+	MaxRetries int // on-failure only; 0 = unlimited
+}
+
+// Parse parses --restart values: no, on-failure[:N], always, unless-stopped
+func Parse(s string) (Policy, error) {
+	name, max, hasMax := strings.Cut(s, ":")
+	p := Policy{Name: name}
+	switch name {
+	case "", No:
+		p.Name = No
+	case Always, UnlessStopped:
+	case OnFailure:
+		if hasMax {
+			n, err := strconv.Atoi(max)
+			if err != nil || n < 0 {
+				return p, fmt.Errorf("invalid --restart %q (maximum retries must be a positive number)", s)
+			}
+			p.MaxRetries = n
+			return p, nil
+		}
+	default:
+		return p, fmt.Errorf("invalid --restart %q (no, on-failure[:N], always or unless-stopped)", s)
+	}
+	if hasMax {
+		return p, fmt.Errorf("invalid --restart %q (only on-failure takes a maximum)", s)
+	}
+	return p, nil
+}
+
+func (p Policy) String() string {
+	if p.Name == OnFailure && p.MaxRetries > 0 {
+		return fmt.Sprintf("%s:%d", p.Name, p.MaxRetries)
+	}
+	return p.Name
+}
+
+// Enabled reports whether the policy ever restarts a container
+func (p Policy) Enabled() bool {
+	return p.Name != "" && p.Name != No
+}
+
+// ShouldRestart decides after an exit. restarts is the number of restarts
+// so far; stopped means the container was stopped with boxy stop.
+func (p Policy) ShouldRestart(exitCode uint32, restarts int, stopped bool) bool {
+	if stopped {
+		return false
+	}
+	switch p.Name {
+	case Always, UnlessStopped:
+		return true
+	case OnFailure:
+		return exitCode != 0 && (p.MaxRetries == 0 || restarts < p.MaxRetries)
+	}
+	return false
+}
+
+// Backoff returns the delay before the next restart given the previous
+// delay and how long the container ran
+func Backoff(prev, uptime time.Duration) time.Duration {
+	if prev == 0 || uptime >= resetAfter {
+		return minDelay
+	}
+	if next := prev * 2; next < maxDelay {
+		return next
+	}
+	return maxDelay
+}
+
+// RestoreOnBoot reports whether boxy system restore starts the container
+// again: always does even after boxy stop, unless-stopped does not
+func (p Policy) RestoreOnBoot(stopped bool) bool {
+	switch p.Name {
+	case Always:
+		return true
+	case UnlessStopped:
+		return !stopped
+	}
+	return false
+}
+
+// SystemdUnit returns a oneshot unit running `boxy system restore` once
+// containerd is up; exe is the path of the boxy binary
+func SystemdUnit(exe, containerdSock string) string {
+	env := ""
+	if containerdSock != "" {
+		env = "Environment=CONTAINERD_SOCK=" + containerdSock + "\n"
+	}
+	return `[Unit]
+Description=Restore boxy containers with a restart policy
+After=containerd.service network-online.target
+Requires=containerd.service
+Wants=network-online.target
+
+[Service]
+Type=oneshot
+RemainAfterExit=yes
+` + env + `ExecStart=` + exe + ` system restore
+
+[Install]
+WantedBy=multi-user.target
+`
+}
@@ -0,0 +1,298 @@
+package runopts
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/arnab2001/boxy/internal/attach"
+	"github.com/arnab2001/boxy/internal/health"
+	boxylabels "github.com/arnab2001/boxy/internal/labels"
+	boxyoci "github.com/arnab2001/boxy/internal/oci"
+	"github.com/arnab2001/boxy/internal/policy"
+	"github.com/arnab2001/boxy/internal/ports"
+	"github.com/arnab2001/boxy/internal/restart"
+	"github.com/arnab2001/boxy/internal/signals"
+	"github.com/arnab2001/boxy/internal/userns"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/spf13/pflag"
+)
+
+// AddFlags defines the container flags shared by run, create and policy check
+func AddFlags(flags *pflag.FlagSet) {
+	flags.String("name", "", "container name (default: a generated adjective_surname)")
+	flags.BoolP("detach", "d", false, "run in background (no TTY)")
+	flags.BoolP("interactive", "i", false, "keep stdin open (default when neither -i nor -t is given)")
+	flags.BoolP("tty", "t", false, "allocate a pseudo-TTY (default when stdin and stdout are terminals)")
+	flags.String("detach-keys", attach.DefaultDetachKeys, "key sequence that detaches from a -it container (empty disables)")
+	flags.Bool("rm", false, "remove the container when it exits")
+	flags.StringSliceP("publish", "p", nil, "HOST:CONT[,PROTO]")
+	flags.StringArrayP("volume", "v", nil, "bind mount HOST:CONTAINER[:ro]")
+	flags.String("network", "", "network mode (host shares the host's network namespace)")
+	flags.String("memory", "", "memory limit (e.g. 512m, 2g)")
+	flags.Float64("cpus", 0, "number of CPUs (e.g. 1.5)")
+	flags.StringSlice("cap-add", nil, "add Linux capabilities (e.g. NET_ADMIN, ALL)")
+	flags.StringSlice("cap-drop", nil, "drop Linux capabilities (e.g. CHOWN, ALL)")
+	flags.Bool("privileged", false, "all capabilities, all host devices, no seccomp/apparmor")
+	flags.StringArray("security-opt", nil, "security options (no-new-privileges, seccomp=<profile.json|unconfined>)")
+	flags.Bool("read-only", false, "mount the container's root filesystem read-only")
+	flags.StringArray("device", nil, "add a host device HOST[:CONTAINER[:PERMS]]")
+	flags.String("userns", "", "user namespace: auto, host or keep-id (default from config)")
+	flags.String("health-cmd", "", "command to check health, run with /bin/sh -c (overrides the image HEALTHCHECK)")
+	flags.Duration("health-interval", 0, "time between health checks (default 30s)")
+	flags.Duration("health-timeout", 0, "maximum time a health check may run (default 30s)")
+	flags.Int("health-retries", 0, "consecutive failures before unhealthy (default 3)")
+	flags.Duration("health-start-period", 0, "grace period during which failures do not count")
+	flags.Bool("no-healthcheck", false, "disable the image's HEALTHCHECK")
+	flags.String("restart", restart.No, "restart policy: no, on-failure[:N], always or unless-stopped")
+	flags.String("stop-signal", "", "signal boxy stop sends first (default the image's STOPSIGNAL or SIGTERM)")
+	flags.String("stop-timeout", signals.DefaultTimeout.String(), "time boxy stop waits before SIGKILL, in seconds or a duration like 1m")
+}
+
+// Options are the parsed container flags
+type Options struct {
+	Name        string
+	Detach      bool
+	Stdio       *attach.Mode // -i/-t; nil when neither was given
+	DetachKeys  []byte
+	AutoRemove  bool
+	Ports       []ports.Mapping
+	Mounts      []specs.Mount
+	HostNetwork bool
+	Resources   boxyoci.ResourceOptions
+	Security    boxyoci.SecurityOptions
+	UserNS      string
+	Health      health.Config // overrides of the image's health check
+	NoHealth    bool
+	Restart     restart.Policy
+	StopSignal  string         // normalised --stop-signal; "" = the image's
+	StopTimeout *time.Duration // nil = default
+}
+
+// Parse validates the flags defined by AddFlags
+func Parse(flags *pflag.FlagSet) (*Options, error) {
+	o := &Options{}
+	o.Name, _ = flags.GetString("name")
+	o.Detach, _ = flags.GetBool("detach")
+	if flags.Changed("interactive") || flags.Changed("tty") {
+		if o.Detach {
+			return nil, fmt.Errorf("-i and -t cannot be used with -d")
+		}
+		o.Stdio = &attach.Mode{}
+		o.Stdio.Stdin, _ = flags.GetBool("interactive")
+		o.Stdio.TTY, _ = flags.GetBool("tty")
+	}
+	o.AutoRemove, _ = flags.GetBool("rm")
+
+	var err error
+	detachKeys, _ := flags.GetString("detach-keys")
+	if o.DetachKeys, err = attach.ParseKeys(detachKeys); err != nil {
+		return nil, err
+	}
+	if o.Security, err = securityOptions(flags); err != nil {
+		return nil, err
+	}
+	usernsFlag, _ := flags.GetString("userns")
+	if o.UserNS, err = userns.ParseMode(usernsFlag); err != nil {
+		return nil, err
+	}
+
+	publish, _ := flags.GetStringSlice("publish")
+	if o.Ports, err = ports.Parse(publish); err != nil {
+		return nil, err
+	}
+	volumes, _ := flags.GetStringArray("volume")
+	for _, v := range volumes {
+		m, err := boxyoci.ParseVolume(v)
+		if err != nil {
+			return nil, err
+		}
+		o.Mounts = append(o.Mounts, m)
+	}
+
+	switch network, _ := flags.GetString("network"); network {
+	case "":
+	case "host":
+		o.HostNetwork = true
+		if len(o.Ports) > 0 {
+			return nil, fmt.Errorf("-p cannot be used with --network host")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported --network %q (only host)", network)
+	}
+
+	if memory, _ := flags.GetString("memory"); memory != "" {
+		if o.Resources.Memory, err = boxyoci.ParseMemory(memory); err != nil {
+			return nil, err
+		}
+	}
+	if o.Resources.CPUs, _ = flags.GetFloat64("cpus"); o.Resources.CPUs < 0 {
+		return nil, fmt.Errorf("--cpus must be positive")
+	}
+
+	if cmd, _ := flags.GetString("health-cmd"); cmd != "" {
+		o.Health.Test = []string{"CMD-SHELL", cmd}
+	}
+	o.Health.Interval, _ = flags.GetDuration("health-interval")
+	o.Health.Timeout, _ = flags.GetDuration("health-timeout")
+	o.Health.StartPeriod, _ = flags.GetDuration("health-start-period")
+	o.Health.Retries, _ = flags.GetInt("health-retries")
+	if o.Health.Interval < 0 || o.Health.Timeout < 0 || o.Health.StartPeriod < 0 || o.Health.Retries < 0 {
+		return nil, fmt.Errorf("--health-* values must not be negative")
+	}
+	o.NoHealth, _ = flags.GetBool("no-healthcheck")
+	if o.NoHealth && o.Health.Test != nil {
+		return nil, fmt.Errorf("--health-cmd cannot be used with --no-healthcheck")
+	}
+
+	restartFlag, _ := flags.GetString("restart")
+	if o.Restart, err = restart.Parse(restartFlag); err != nil {
+		return nil, err
+	}
+	// a restarted task has no terminal to attach to
+	if o.Restart.Enabled() && o.Stdio != nil && (o.Stdio.Stdin || o.Stdio.TTY) {
+		return nil, fmt.Errorf("--restart cannot be used with -i or -t")
+	}
+	if o.Restart.Enabled() && o.AutoRemove {
+		return nil, fmt.Errorf("--rm cannot be used with --restart")
+	}
+
+	if stopSignal, _ := flags.GetString("stop-signal"); stopSignal != "" {
+		sig, err := signals.Parse(stopSignal)
+		if err != nil {
+			return nil, err
+		}
+		o.StopSignal = signals.Name(sig)
+	}
+	if flags.Changed("stop-timeout") {
+		stopTimeout, _ := flags.GetString("stop-timeout")
+		timeout, err := signals.ParseTimeout(stopTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("--stop-timeout: %v", err)
+		}
+		o.StopTimeout = &timeout
+	}
+	return o, nil
+}
+
+// ParseCreate parses the flags of boxy create. boxy start runs the
+// container in the background unless -a is given, so it is always detached
+// and --rm is left to the monitor.
+func ParseCreate(flags *pflag.FlagSet) (*Options, error) {
+	o, err := Parse(flags)
+	if err != nil {
+		return nil, err
+	}
+	o.Detach = true
+	return o, nil
+}
+
+// Healthcheck merges the image's health check with the --health-* flags;
+// nil means the container is not health checked
+func (o *Options) Healthcheck(image *health.Config) *health.Config {
+	if o.NoHealth {
+		return nil
+	}
+	var cfg health.Config
+	if image != nil {
+		cfg = *image
+	}
+	if o.Health.Test != nil {
+		cfg.Test = o.Health.Test
+	}
+	if o.Health.Interval > 0 {
+		cfg.Interval = o.Health.Interval
+	}
+	if o.Health.Timeout > 0 {
+		cfg.Timeout = o.Health.Timeout
+	}
+	if o.Health.StartPeriod > 0 {
+		cfg.StartPeriod = o.Health.StartPeriod
+	}
+	if o.Health.Retries > 0 {
+		cfg.Retries = o.Health.Retries
+	}
+	if !cfg.Enabled() {
+		return nil
+	}
+	return &cfg
+}
+
+// PolicyRequest describes the container to the run-time policy
+func (o *Options) PolicyRequest() policy.RunRequest {
+	r := policy.RunRequest{
+		Name:        o.Name,
+		Privileged:  o.Security.Privileged,
+		HostNetwork: o.HostNetwork,
+		Memory:      o.Resources.Memory,
+		CPUs:        o.Resources.CPUs,
+	}
+	for _, m := range o.Mounts {
+		r.Mounts = append(r.Mounts, m.Source)
+	}
+	for _, p := range o.Ports {
+		r.HostPorts = append(r.HostPorts, int(p.HostPort))
+	}
+	for _, c := range o.Security.CapAdd {
+		if name, err := boxyoci.NormalizeCap(c); err == nil {
+			r.CapAdd = append(r.CapAdd, name)
+		}
+	}
+	// unknown capabilities are rejected when the spec is built
+	r.Caps, _ = boxyoci.EffectiveCaps(o.Security)
+	return r
+}
+
+// Labels records the options boxy start, stop and the monitor read back
+// later. healthcheck and stopSignal are the values merged with the image's.
+func (o *Options) Labels(healthcheck *health.Config, stopSignal string) (map[string]string, error) {
+	labels := map[string]string{
+		boxylabels.Name:    o.Name,
+		boxylabels.Seccomp: o.Security.SeccompLabel(),
+	}
+	if o.Security.Privileged {
+		labels[boxylabels.Privileged] = "true"
+	}
+	if len(o.Ports) > 0 {
+		labels[boxylabels.Ports] = ports.Format(o.Ports)
+	}
+	if healthcheck != nil {
+		data, err := json.Marshal(healthcheck)
+		if err != nil {
+			return nil, err
+		}
+		labels[boxylabels.Healthcheck] = string(data)
+	}
+	if o.Restart.Enabled() {
+		labels[boxylabels.RestartPolicy] = o.Restart.String()
+	}
+	// an attached run removes the container itself
+	if o.AutoRemove && o.Detach {
+		labels[boxylabels.AutoRemove] = "true"
+	}
+	if stopSignal != "" {
+		labels[boxylabels.StopSignal] = stopSignal
+	}
+	if o.StopTimeout != nil {
+		labels[boxylabels.StopTimeout] = o.StopTimeout.String()
+	}
+	return labels, nil
+}
+
+// securityOptions collects the capability / privilege flags
+func securityOptions(flags *pflag.FlagSet) (boxyoci.SecurityOptions, error) {
+	var o boxyoci.SecurityOptions
+	o.CapAdd, _ = flags.GetStringSlice("cap-add")
+	o.CapDrop, _ = flags.GetStringSlice("cap-drop")
+	o.Privileged, _ = flags.GetBool("privileged")
+	o.ReadOnly, _ = flags.GetBool("read-only")
+	o.Devices, _ = flags.GetStringArray("device")
+
+	secOpts, _ := flags.GetStringArray("security-opt")
+	for _, opt := range secOpts {
+		if err := boxyoci.ParseSecurityOpt(&o, opt); err != nil {
+			return o, err
+		}
+	}
+	return o, nil
+}
@@ -0,0 +1,342 @@
+package seccomp
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/containerd/containerd/containers"
+	"github.com/containerd/containerd/oci"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// defaultProfile is Docker's default seccomp profile (moby, Apache-2.0)
+//
+//go:embed default.json
+var defaultProfile []byte
+
+// Profile is a seccomp profile in Docker's JSON format
+type Profile struct {
+	DefaultAction   specs.LinuxSeccompAction `json:"defaultAction"`
+	DefaultErrnoRet *uint                    `json:"defaultErrnoRet,omitempty"`
+	Architectures   []specs.Arch             `json:"architectures,omitempty"`
+	ArchMap         []Architecture           `json:"archMap,omitempty"`
+	Flags           []specs.LinuxSeccompFlag `json:"flags,omitempty"`
+	ListenerPath    string                   `json:"listenerPath,omitempty"`
+	Syscalls        []Syscall                `json:"syscalls"`
+}
+
+// Architecture maps a native architecture to the sub-architectures it runs
+type Architecture struct {
+	Arch      specs.Arch   `json:"architecture"`
+	SubArches []specs.Arch `json:"subArchitectures"`
+}
+
+// Syscall is a rule, optionally conditional on capabilities/arch/kernel
+type Syscall struct {
+	Names    []string                 `json:"names,omitempty"`
+	Name     string                   `json:"name,omitempty"` // legacy single name
+	Action   specs.LinuxSeccompAction `json:"action"`
+	ErrnoRet *uint                    `json:"errnoRet,omitempty"`
+	Args     []specs.LinuxSeccompArg  `json:"args,omitempty"`
+	Comment  string                   `json:"comment,omitempty"`
+	Includes *Filter                  `json:"includes,omitempty"`
+	Excludes *Filter                  `json:"excludes,omitempty"`
+}
+
+// Filter limits a rule to (or excludes it from) certain conditions
+type Filter struct {
+	Caps      []string `json:"caps,omitempty"`
+	Arches    []string `json:"arches,omitempty"`
+	MinKernel string   `json:"minKernel,omitempty"` // "<kernel>.<major>"
+}
+
+var validActions = map[specs.LinuxSeccompAction]bool{
+	specs.ActKill: true, specs.ActKillProcess: true, specs.ActKillThread: true,
+	specs.ActTrap: true, specs.ActErrno: true, specs.ActTrace: true,
+	specs.ActAllow: true, specs.ActLog: true, specs.ActNotify: true,
+}
+
+var validOps = map[specs.LinuxSeccompOperator]bool{
+	specs.OpNotEqual: true, specs.OpLessThan: true, specs.OpLessEqual: true,
+	specs.OpEqualTo: true, specs.OpGreaterEqual: true, specs.OpGreaterThan: true,
+	specs.OpMaskedEqual: true,
+}
+
+// goToNative maps GOARCH to the arch names used in includes/excludes, and
+// nativeToSeccomp those names to libseccomp architectures
+var (
+	goToNative = map[string]string{
+		"386": "x86", "amd64": "amd64", "arm": "arm", "arm64": "arm64",
+		"mips64": "mips64", "mips64le": "mipsel64", "mipsle": "mipsel",
+		"ppc64": "ppc64", "ppc64le": "ppc64le", "riscv64": "riscv64",
+		"s390x": "s390x", "loong64": "loong64",
+	}
+	nativeToSeccomp = map[string]specs.Arch{
+		"x86": specs.ArchX86, "amd64": specs.ArchX86_64, "arm": specs.ArchARM,
+		"arm64": specs.ArchAARCH64, "mips64": specs.ArchMIPS64, "mipsel64": specs.ArchMIPSEL64,
+		"mipsel": specs.ArchMIPSEL, "ppc64": specs.ArchPPC64, "ppc64le": specs.ArchPPC64LE,
+		"riscv64": specs.ArchRISCV64, "s390x": specs.ArchS390X, "loong64": specs.ArchLOONGARCH64,
+	}
+)
+
+// Default returns the built-in default profile
+func Default() *Profile {
+	p, err := Parse(defaultProfile)
+	if err != nil {
+		panic(fmt.Sprintf("built-in seccomp profile is invalid: %v", err))
+	}
+	return p
+}
+
+// DefaultJSON returns the built-in default profile as shipped
+func DefaultJSON() []byte {
+	return defaultProfile
+}
+
+// Load reads and validates a profile file
+func Load(path string) (*Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return p, nil
+}
+
+// Parse decodes and validates a profile, returning the first problem found
+func Parse(data []byte) (*Profile, error) {
+	p, errs := parse(data)
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return p, nil
+}
+
+// Validate reports every problem found in a profile
+func Validate(data []byte) []error {
+	_, errs := parse(data)
+	return errs
+}
+
+func parse(data []byte) (*Profile, []error) {
+	p := &Profile{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(p); err != nil {
+		return nil, []error{jsonError(data, err)}
+	}
+	return p, p.validate()
+}
+
+// jsonError adds line:column information to JSON decoding errors
+func jsonError(data []byte, err error) error {
+	var offset int64 = -1
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		offset = e.Offset
+	case *json.UnmarshalTypeError:
+		offset = e.Offset
+	}
+	if offset < 0 {
+		return err
+	}
+	line := 1 + bytes.Count(data[:offset], []byte("\n"))
+	col := int(offset) - bytes.LastIndexByte(data[:offset], '\n')
+	return fmt.Errorf("line %d, column %d: %v", line, col, err)
+}
+
+func (p *Profile) validate() []error {
+	var errs []error
+	if p.DefaultAction == "" {
+		errs = append(errs, fmt.Errorf("defaultAction is required"))
+	} else if !validActions[p.DefaultAction] {
+		errs = append(errs, fmt.Errorf("defaultAction: unknown action %q", p.DefaultAction))
+	}
+	if len(p.Architectures) > 0 && len(p.ArchMap) > 0 {
+		errs = append(errs, fmt.Errorf("use either architectures or archMap, not both"))
+	}
+
+	for i, sc := range p.Syscalls {
+		where := fmt.Sprintf("syscalls[%d]", i)
+		if len(sc.Names) > 0 {
+			where = fmt.Sprintf("syscalls[%d] (%s)", i, sc.Names[0])
+		}
+		switch {
+		case sc.Name != "" && len(sc.Names) > 0:
+			errs = append(errs, fmt.Errorf("%s: use either name or names, not both", where))
+		case sc.Name == "" && len(sc.Names) == 0:
+			errs = append(errs, fmt.Errorf("%s: no syscall names", where))
+		}
+		if !validActions[sc.Action] {
+			errs = append(errs, fmt.Errorf("%s: unknown action %q", where, sc.Action))
+		}
+		for j, a := range sc.Args {
+			if !validOps[a.Op] {
+				errs = append(errs, fmt.Errorf("%s: args[%d]: unknown operator %q", where, j, a.Op))
+			}
+			if a.Index > 5 {
+				errs = append(errs, fmt.Errorf("%s: args[%d]: index %d out of range (0-5)", where, j, a.Index))
+			}
+		}
+		for _, f := range []*Filter{sc.Includes, sc.Excludes} {
+			if f == nil || f.MinKernel == "" {
+				continue
+			}
+			if _, _, err := parseKernel(f.MinKernel); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", where, err))
+			}
+		}
+	}
+	return errs
+}
+
+// Build resolves the profile for this host and the container's bounding
+// capability set into an OCI seccomp configuration
+func (p *Profile) Build(bounding []string) (*specs.LinuxSeccomp, error) {
+	out := &specs.LinuxSeccomp{
+		DefaultAction:   p.DefaultAction,
+		DefaultErrnoRet: p.DefaultErrnoRet,
+		Architectures:   p.Architectures,
+		Flags:           p.Flags,
+		ListenerPath:    p.ListenerPath,
+	}
+
+	arch := goToNative[runtime.GOARCH]
+	if native, ok := nativeToSeccomp[arch]; ok {
+		for _, a := range p.ArchMap {
+			if a.Arch == native {
+				out.Architectures = append(append(out.Architectures, a.Arch), a.SubArches...)
+				break
+			}
+		}
+	}
+
+	for _, sc := range p.Syscalls {
+		ok, err := sc.applies(arch, bounding)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		names := sc.Names
+		if sc.Name != "" {
+			names = []string{sc.Name}
+		}
+		out.Syscalls = append(out.Syscalls, specs.LinuxSyscall{
+			Names:    names,
+			Action:   sc.Action,
+			ErrnoRet: sc.ErrnoRet,
+			Args:     sc.Args,
+		})
+	}
+	return out, nil
+}
+
+func (sc Syscall) applies(arch string, bounding []string) (bool, error) {
+	if f := sc.Excludes; f != nil {
+		if contains(f.Arches, arch) {
+			return false, nil
+		}
+		for _, c := range f.Caps {
+			if contains(bounding, c) {
+				return false, nil
+			}
+		}
+		if f.MinKernel != "" {
+			ok, err := kernelAtLeast(f.MinKernel)
+			if err != nil || ok {
+				return false, err
+			}
+		}
+	}
+	if f := sc.Includes; f != nil {
+		if len(f.Arches) > 0 && !contains(f.Arches, arch) {
+			return false, nil
+		}
+		for _, c := range f.Caps {
+			if !contains(bounding, c) {
+				return false, nil
+			}
+		}
+		if f.MinKernel != "" {
+			ok, err := kernelAtLeast(f.MinKernel)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// WithProfile sets the container's seccomp filter from the profile; it must
+// run after capabilities are final because rules depend on them
+func WithProfile(p *Profile) oci.SpecOpts {
+	return func(_ context.Context, _ oci.Client, _ *containers.Container, s *specs.Spec) error {
+		var bounding []string
+		if s.Process != nil && s.Process.Capabilities != nil {
+			bounding = s.Process.Capabilities.Bounding
+		}
+		sc, err := p.Build(bounding)
+		if err != nil {
+			return err
+		}
+		if s.Linux == nil {
+			s.Linux = &specs.Linux{}
+		}
+		s.Linux.Seccomp = sc
+		return nil
+	}
+}
+
+func parseKernel(v string) (uint64, uint64, error) {
+	kernel, major, ok := strings.Cut(v, ".")
+	k, err1 := strconv.ParseUint(kernel, 10, 8)
+	m, err2 := strconv.ParseUint(major, 10, 8)
+	if !ok || err1 != nil || err2 != nil || (k == 0 && m == 0) {
+		return 0, 0, fmt.Errorf("invalid minKernel %q, expected \"<kernel>.<major>\"", v)
+	}
+	return k, m, nil
+}
+
+// kernelAtLeast compares the running kernel against "<kernel>.<major>"
+func kernelAtLeast(v string) (bool, error) {
+	wantK, wantM, err := parseKernel(v)
+	if err != nil {
+		return false, err
+	}
+	var uts syscall.Utsname
+	if err := syscall.Uname(&uts); err != nil {
+		return false, err
+	}
+	var release strings.Builder
+	for _, c := range uts.Release {
+		if c == 0 {
+			break
+		}
+		release.WriteByte(byte(c))
+	}
+	var k, m uint64
+	fmt.Sscanf(release.String(), "%d.%d", &k, &m)
+	return k > wantK || (k == wantK && m >= wantM), nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
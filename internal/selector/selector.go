@@ -0,0 +1,141 @@
+package selector
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	refdocker "github.com/containerd/containerd/reference/docker"
+)
+
+// Container statuses, as docker names them
+const (
+	Created = "created"
+	Running = "running"
+	Paused  = "paused"
+	Exited  = "exited"
+)
+
+// Container is what filters are matched against
+type Container struct {
+	ID     string
+	Name   string
+	Image  string
+	Status string
+	Labels map[string]string
+}
+
+// Filter selects containers. Values of one key are alternatives, different
+// keys must all match: status=exited,status=created,label=tier=web keeps the
+// stopped web containers.
+type Filter map[string][]string
+
+var statuses = map[string]bool{Created: true, Running: true, Paused: true, Exited: true}
+
+// ParseFilters parses --filter flags, each a comma separated list of
+// label=KEY[=VALUE], status=STATUS or ancestor=IMAGE
+func ParseFilters(flags []string) (Filter, error) {
+	f := Filter{}
+	for _, flag := range flags {
+		for _, kv := range strings.Split(flag, ",") {
+			k, v, ok := strings.Cut(strings.TrimSpace(kv), "=")
+			if !ok || v == "" {
+				return nil, fmt.Errorf("invalid filter %q (expected key=value)", kv)
+			}
+			switch k {
+			case "label":
+			case "status":
+				if !statuses[v] {
+					return nil, fmt.Errorf("invalid status filter %q (created, running, paused or exited)", v)
+				}
+			case "ancestor":
+				if _, err := refdocker.ParseNormalizedNamed(v); err != nil {
+					return nil, fmt.Errorf("invalid ancestor filter %q: %v", v, err)
+				}
+			default:
+				return nil, fmt.Errorf("unknown filter %q (label, status or ancestor)", k)
+			}
+			f[k] = append(f[k], v)
+		}
+	}
+	return f, nil
+}
+
+// Match reports whether the container passes the filter
+func (f Filter) Match(c Container) bool {
+	for k, values := range f {
+		matched := false
+		for _, v := range values {
+			switch k {
+			case "label":
+				key, want, hasValue := strings.Cut(v, "=")
+				got, ok := c.Labels[key]
+				matched = ok && (!hasValue || got == want)
+			case "status":
+				matched = c.Status == v
+			case "ancestor":
+				matched = ancestor(c.Image, v)
+			}
+			if matched {
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// ancestor reports whether image is ref; a ref without tag or digest
+// matches every tag of the repository
+func ancestor(image, ref string) bool {
+	want, err := refdocker.ParseNormalizedNamed(ref)
+	if err != nil {
+		return false
+	}
+	have, err := refdocker.ParseNormalizedNamed(image)
+	if err != nil {
+		return false
+	}
+	if refdocker.IsNameOnly(want) {
+		return have.Name() == want.Name()
+	}
+	return have.String() == want.String()
+}
+
+// Each calls fn for every item with at most limit calls running at once and
+// joins the errors, each prefixed with its item
+func Each(items []string, limit int, fn func(item string) error) error {
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, len(items)) // one slot per item, no locking
+		sem  = make(chan struct{}, max(limit, 1))
+	)
+	for i, item := range items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, item string) {
+			defer func() { <-sem; wg.Done() }()
+			if err := fn(item); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", item, err)
+			}
+		}(i, item)
+	}
+	wg.Wait()
+
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	if len(failed) == 1 {
+		return failed[0]
+	}
+	return fmt.Errorf("%d of %d failed:\n%w", len(failed), len(items), errors.Join(failed...))
+}
@@ -0,0 +1,67 @@
+package signals
+
+import (
+	"fmt"
+	"strconv"
+	"syscall"
+	"time"
+
+	boxylabels "github.com/arnab2001/boxy/internal/labels"
+	"github.com/moby/sys/signal"
+	"golang.org/x/sys/unix"
+)
+
+// Defaults for containers whose image and flags set no stop signal or
+// timeout, like docker
+const (
+	DefaultStop    = syscall.SIGTERM
+	DefaultTimeout = 10 * time.Second
+)
+
+// Parse parses a signal name with or without the SIG prefix, in any case
+// (SIGQUIT, quit), or a signal number
+func Parse(s string) (syscall.Signal, error) {
+	sig, err := signal.ParseSignal(s)
+	if err != nil || !signal.ValidSignalForPlatform(sig) {
+		return 0, fmt.Errorf("invalid signal %q", s)
+	}
+	return sig, nil
+}
+
+// Name returns the SIG-prefixed name of a signal, or its number when it
+// has none
+func Name(sig syscall.Signal) string {
+	if name := unix.SignalName(sig); name != "" {
+		return name
+	}
+	return fmt.Sprint(int(sig))
+}
+
+// ParseTimeout parses a stop timeout given as whole seconds, like docker's
+// -t 5, or as a duration (30s, 1m)
+func ParseTimeout(s string) (time.Duration, error) {
+	t, err := time.ParseDuration(s)
+	if n, nerr := strconv.Atoi(s); nerr == nil {
+		t, err = time.Duration(n)*time.Second, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout %q (seconds or a duration like 30s)", s)
+	}
+	if t < 0 {
+		return 0, fmt.Errorf("timeout %q must not be negative", s)
+	}
+	return t, nil
+}
+
+// Stop returns the signal and timeout boxy stop uses for a container with
+// the given labels. Invalid labels fall back to the defaults.
+func Stop(labels map[string]string) (syscall.Signal, time.Duration) {
+	sig, timeout := DefaultStop, DefaultTimeout
+	if s, err := Parse(labels[boxylabels.StopSignal]); err == nil {
+		sig = s
+	}
+	if t, err := ParseTimeout(labels[boxylabels.StopTimeout]); err == nil {
+		timeout = t
+	}
+	return sig, timeout
+}
@@ -0,0 +1,130 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/archive"
+	"github.com/containerd/containerd/containers"
+	"github.com/containerd/containerd/mount"
+	"github.com/containerd/continuity/fs"
+)
+
+// WithReadonlyRoot mounts the container's root filesystem read-only on a
+// temporary directory for the duration of fn; safe for running containers
+func WithReadonlyRoot(ctx context.Context, c *containerd.Client, info containers.Container, fn func(root string) error) error {
+	mounts, err := rootMounts(ctx, c, info)
+	if err != nil {
+		return err
+	}
+	return mount.WithReadonlyTempMount(ctx, mounts, fn)
+}
+
+// WithRoot mounts the container's root filesystem read-write; only use it
+// when the container has no running task
+func WithRoot(ctx context.Context, c *containerd.Client, info containers.Container, fn func(root string) error) error {
+	mounts, err := rootMounts(ctx, c, info)
+	if err != nil {
+		return err
+	}
+	return mount.WithTempMount(ctx, mounts, fn)
+}
+
+func rootMounts(ctx context.Context, c *containerd.Client, info containers.Container) ([]mount.Mount, error) {
+	if info.SnapshotKey == "" {
+		return nil, fmt.Errorf("container %s has no snapshot", info.ID)
+	}
+	return c.SnapshotService(info.Snapshotter).Mounts(ctx, info.SnapshotKey)
+}
+
+// Change is one line of boxy diff
+type Change struct {
+	Kind string // A (added), C (changed) or D (deleted)
+	Path string // absolute path inside the container
+}
+
+func (c Change) String() string {
+	return c.Kind + " " + c.Path
+}
+
+// Changes reports the paths added, changed or deleted in the directory
+// upper relative to lower ("" for an empty lower)
+func Changes(ctx context.Context, lower, upper string, fn func(Change) error) error {
+	return fs.Changes(ctx, lower, upper, func(kind fs.ChangeKind, p string, _ os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		var marker string
+		switch kind {
+		case fs.ChangeKindAdd:
+			marker = "A"
+		case fs.ChangeKindModify:
+			marker = "C"
+		case fs.ChangeKindDelete:
+			marker = "D"
+		default:
+			return nil
+		}
+		return fn(Change{Kind: marker, Path: filepath.Join("/", p)})
+	})
+}
+
+// ContainerChanges reports the changes in the container's snapshot
+// relative to its parent (the image's root filesystem)
+func ContainerChanges(ctx context.Context, c *containerd.Client, info containers.Container, fn func(Change) error) error {
+	return withLayers(ctx, c, info, func(lower, upper string) error {
+		return Changes(ctx, lower, upper, fn)
+	})
+}
+
+// WriteDiff streams the container's changes relative to its parent as a
+// layer tar (deletions as whiteouts)
+func WriteDiff(ctx context.Context, c *containerd.Client, info containers.Container, w io.Writer) error {
+	return withLayers(ctx, c, info, func(lower, upper string) error {
+		return archive.WriteDiff(ctx, w, lower, upper)
+	})
+}
+
+// withLayers mounts the container's snapshot and its parent read-only;
+// lower is "" when the snapshot has no parent
+func withLayers(ctx context.Context, c *containerd.Client, info containers.Container, fn func(lower, upper string) error) error {
+	sn := c.SnapshotService(info.Snapshotter)
+	st, err := sn.Stat(ctx, info.SnapshotKey)
+	if err != nil {
+		return err
+	}
+
+	return WithReadonlyRoot(ctx, c, info, func(upper string) error {
+		if st.Parent == "" {
+			return fn("", upper)
+		}
+		viewKey := fmt.Sprintf("%s-diff-view-%d", info.SnapshotKey, time.Now().UnixNano())
+		lower, err := sn.View(ctx, viewKey, st.Parent)
+		if err != nil {
+			return err
+		}
+		defer sn.Remove(ctx, viewKey)
+
+		return mount.WithReadonlyTempMount(ctx, lower, func(lowerRoot string) error {
+			return fn(lowerRoot, upper)
+		})
+	})
+}
+
+// Export streams the directory root as a tar archive
+func Export(ctx context.Context, root string, w io.Writer) error {
+	return archive.WriteDiff(ctx, w, "", root)
+}
+
+// ExportContainer streams the flattened container root filesystem as a
+// tar archive
+func ExportContainer(ctx context.Context, c *containerd.Client, info containers.Container, w io.Writer) error {
+	return WithReadonlyRoot(ctx, c, info, func(root string) error {
+		return Export(ctx, root, w)
+	})
+}
@@ -0,0 +1,210 @@
+package snapshot
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/containerd/continuity/fs"
+)
+
+// TarOptions controls how WriteTar and ExtractTar treat files
+type TarOptions struct {
+	FollowLink    bool // archive the target of a symlinked source path
+	PreserveOwner bool // keep uid/gid from the archive when extracting
+	UID, GID      int  // owner used when PreserveOwner is false
+	ChownEntries  bool // apply UID/GID when PreserveOwner is false
+}
+
+// WriteTar archives src (resolved inside root, symlinks bounded to root)
+// into w. The top-level entry is named name; "." archives only the contents.
+func WriteTar(w io.Writer, root, src, name string, opts TarOptions) error {
+	parent, err := fs.RootPath(root, path.Dir(path.Clean("/"+src)))
+	if err != nil {
+		return err
+	}
+	p := filepath.Join(parent, path.Base(path.Clean("/"+src)))
+	if opts.FollowLink {
+		if p, err = fs.RootPath(root, path.Clean("/"+src)); err != nil {
+			return err
+		}
+	}
+	if _, err := os.Lstat(p); err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	err = filepath.Walk(p, func(file string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(p, file)
+		if err != nil {
+			return err
+		}
+		entry := filepath.ToSlash(filepath.Join(name, rel))
+		if entry == "." {
+			return nil
+		}
+		return writeEntry(tw, file, entry, fi)
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func writeEntry(tw *tar.Writer, file, name string, fi os.FileInfo) error {
+	var link string
+	if fi.Mode()&os.ModeSymlink != 0 {
+		l, err := os.Readlink(file)
+		if err != nil {
+			return err
+		}
+		link = l
+	}
+	hdr, err := tar.FileInfoHeader(fi, link)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if fi.IsDir() {
+		hdr.Name += "/"
+	}
+	// numeric IDs only: names would be resolved against the wrong passwd
+	hdr.Uname, hdr.Gname = "", ""
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		hdr.Uid, hdr.Gid = int(st.Uid), int(st.Gid)
+	}
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// ExtractTar unpacks r under root. Every entry (and hard link target) is
+// resolved with symlinks bounded to root, so nothing can escape it.
+func ExtractTar(r io.Reader, root string, opts TarOptions) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := extractEntry(tr, hdr, root, opts); err != nil {
+			return fmt.Errorf("%s: %v", hdr.Name, err)
+		}
+	}
+}
+
+func extractEntry(tr *tar.Reader, hdr *tar.Header, root string, opts TarOptions) error {
+	name := path.Clean("/" + hdr.Name)
+	if name == "/" {
+		return nil
+	}
+	parent, err := fs.RootPath(root, path.Dir(name))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return err
+	}
+	// the entry itself is not resolved: an existing symlink gets replaced
+	dst := filepath.Join(parent, path.Base(name))
+	mode := os.FileMode(hdr.Mode).Perm() | os.FileMode(hdr.Mode)&(os.ModeSetuid|os.ModeSetgid|os.ModeSticky)
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		if fi, err := os.Lstat(dst); err == nil && !fi.IsDir() {
+			if err := os.Remove(dst); err != nil {
+				return err
+			}
+		}
+		if err := os.MkdirAll(dst, mode); err != nil {
+			return err
+		}
+	case tar.TypeReg:
+		if err := removeNonDir(dst); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|syscall.O_NOFOLLOW, mode)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, tr); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	case tar.TypeSymlink:
+		if err := removeNonDir(dst); err != nil {
+			return err
+		}
+		if err := os.Symlink(hdr.Linkname, dst); err != nil {
+			return err
+		}
+	case tar.TypeLink:
+		target, err := fs.RootPath(root, path.Clean("/"+hdr.Linkname))
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(target, filepath.Clean(root)+string(filepath.Separator)) {
+			return fmt.Errorf("hard link target %s escapes destination", hdr.Linkname)
+		}
+		if err := removeNonDir(dst); err != nil {
+			return err
+		}
+		if err := os.Link(target, dst); err != nil {
+			return err
+		}
+	default:
+		return nil // devices, fifos etc. are skipped
+	}
+
+	if hdr.Typeflag != tar.TypeSymlink {
+		if err := os.Chmod(dst, mode); err != nil {
+			return err
+		}
+	}
+	switch {
+	case opts.PreserveOwner:
+		return os.Lchown(dst, hdr.Uid, hdr.Gid)
+	case opts.ChownEntries:
+		return os.Lchown(dst, opts.UID, opts.GID)
+	}
+	return nil
+}
+
+func removeNonDir(p string) error {
+	fi, err := os.Lstat(p)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return fmt.Errorf("cannot overwrite directory with a non-directory")
+	}
+	return os.Remove(p)
+}
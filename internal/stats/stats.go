@@ -0,0 +1,204 @@
+package stats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	v1 "github.com/containerd/cgroups/v3/cgroup1/stats"
+	v2 "github.com/containerd/cgroups/v3/cgroup2/stats"
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/api/types"
+	"github.com/containerd/typeurl/v2"
+)
+
+// unlimited is the threshold above which a cgroup memory limit means "no
+// limit" (cgroup v1 reports PAGE_COUNTER_MAX pages, v2 math.MaxUint64)
+const unlimited = 1 << 62
+
+// Sample is one reading of a container's resource usage. Counters (CPU,
+// block and network I/O) are cumulative since the container started.
+type Sample struct {
+	Time     time.Time `json:"time"`
+	CPU      uint64    `json:"cpuNanos"`
+	Memory   uint64    `json:"memoryBytes"`
+	Limit    uint64    `json:"memoryLimitBytes,omitempty"` // 0 = unlimited
+	Pids     uint64    `json:"pids"`
+	BlkRead  uint64    `json:"blockReadBytes"`
+	BlkWrite uint64    `json:"blockWriteBytes"`
+	NetRx    uint64    `json:"netRxBytes"`
+	NetTx    uint64    `json:"netTxBytes"`
+}
+
+// Target is a container boxy stats may sample; Status is the status of
+// its task ("" when it has none)
+type Target struct {
+	ID     string
+	Name   string
+	Status containerd.ProcessStatus
+}
+
+// Sampled keeps the targets whose task has a cgroup to read metrics from:
+// running and paused ones. Exited tasks stay until boxy rm but have none.
+// With named set the targets were given on the command line and one that
+// cannot be sampled is an error.
+func Sampled(targets []Target, named bool) ([]Target, error) {
+	var out []Target
+	for _, t := range targets {
+		switch t.Status {
+		case containerd.Running, containerd.Paused, containerd.Pausing:
+			out = append(out, t)
+		default:
+			if named {
+				return nil, fmt.Errorf("container %s is not running", t.Name)
+			}
+		}
+	}
+	return out, nil
+}
+
+// Decode converts the metrics of a task (cgroup v1 or v2) into a sample.
+// Memory excludes inactive page cache, as docker stats does.
+func Decode(m *types.Metric) (Sample, error) {
+	s := Sample{Time: time.Now()}
+	if m.Timestamp != nil {
+		s.Time = m.Timestamp.AsTime()
+	}
+	data, err := typeurl.UnmarshalAny(m.Data)
+	if err != nil {
+		return s, fmt.Errorf("failed to decode metrics: %v", err)
+	}
+
+	switch v := data.(type) {
+	case *v1.Metrics:
+		if v.CPU != nil && v.CPU.Usage != nil {
+			s.CPU = v.CPU.Usage.Total
+		}
+		if v.Memory != nil && v.Memory.Usage != nil {
+			s.Memory = sub(v.Memory.Usage.Usage, v.Memory.TotalInactiveFile)
+			s.Limit = limit(v.Memory.Usage.Limit)
+		}
+		if v.Pids != nil {
+			s.Pids = v.Pids.Current
+		}
+		if v.Blkio != nil {
+			for _, e := range v.Blkio.IoServiceBytesRecursive {
+				switch strings.ToLower(e.Op) {
+				case "read":
+					s.BlkRead += e.Value
+				case "write":
+					s.BlkWrite += e.Value
+				}
+			}
+		}
+	case *v2.Metrics:
+		if v.CPU != nil {
+			s.CPU = v.CPU.UsageUsec * 1000
+		}
+		if v.Memory != nil {
+			s.Memory = sub(v.Memory.Usage, v.Memory.InactiveFile)
+			s.Limit = limit(v.Memory.UsageLimit)
+		}
+		if v.Pids != nil {
+			s.Pids = v.Pids.Current
+		}
+		if v.Io != nil {
+			for _, e := range v.Io.Usage {
+				s.BlkRead += e.Rbytes
+				s.BlkWrite += e.Wbytes
+			}
+		}
+	default:
+		return s, fmt.Errorf("unsupported metrics type %T", data)
+	}
+	return s, nil
+}
+
+func sub(a, b uint64) uint64 {
+	if b > a {
+		return 0
+	}
+	return a - b
+}
+
+func limit(l uint64) uint64 {
+	if l >= unlimited {
+		return 0
+	}
+	return l
+}
+
+// CPUPercent is the CPU used between two samples as a percentage of one
+// CPU (a container busy on two cores shows 200%)
+func CPUPercent(prev, cur Sample) float64 {
+	wall := cur.Time.Sub(prev.Time)
+	if wall <= 0 || cur.CPU < prev.CPU {
+		return 0
+	}
+	return float64(cur.CPU-prev.CPU) / float64(wall.Nanoseconds()) * 100
+}
+
+// ReadNetDev adds the interface counters of the network namespace pid
+// lives in to the sample
+func (s *Sample) ReadNetDev(pid uint32) error {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/net/dev", pid))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	s.NetRx, s.NetTx, err = ParseNetDev(f)
+	return err
+}
+
+// ParseNetDev sums received and transmitted bytes over every interface
+// but loopback in /proc/<pid>/net/dev format
+func ParseNetDev(r io.Reader) (rx, tx uint64, err error) {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		iface, counters, ok := strings.Cut(sc.Text(), ":")
+		if !ok || strings.TrimSpace(iface) == "lo" {
+			continue // headers
+		}
+		fields := strings.Fields(counters)
+		if len(fields) < 9 {
+			return 0, 0, fmt.Errorf("malformed net/dev line %q", sc.Text())
+		}
+		r, err1 := strconv.ParseUint(fields[0], 10, 64)
+		t, err2 := strconv.ParseUint(fields[8], 10, 64)
+		if err1 != nil || err2 != nil {
+			return 0, 0, fmt.Errorf("malformed net/dev line %q", sc.Text())
+		}
+		rx += r
+		tx += t
+	}
+	return rx, tx, sc.Err()
+}
+
+// Bytes formats a byte count with binary units (e.g. "12.5MiB")
+func Bytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// HostMemory is the total RAM of the host, shown as the limit of
+// containers without one
+func HostMemory() uint64 {
+	var info syscall.Sysinfo_t
+	if err := syscall.Sysinfo(&info); err != nil {
+		return 0
+	}
+	return uint64(info.Totalram) * uint64(info.Unit)
+}
@@ -0,0 +1,172 @@
+package top
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is USER_HZ, the unit of the times in /proc/<pid>/stat (100 on
+// every Linux architecture boxy runs on)
+const clockTicks = 100
+
+// Process is what boxy top shows about one process, read from /proc
+type Process struct {
+	PID     int
+	PPID    int
+	UID     int
+	User    string
+	State   string
+	CPU     float64 // % of one CPU over the process lifetime, as ps reports it
+	RSS     uint64  // bytes
+	Mem     float64 // % of host RAM
+	Time    time.Duration
+	Start   time.Time
+	Command string
+}
+
+// Read collects the process information of pid from procfs rooted at proc
+// (normally "/proc")
+func Read(proc string, pid int) (Process, error) {
+	p := Process{PID: pid}
+	dir := filepath.Join(proc, strconv.Itoa(pid))
+
+	stat, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return p, err
+	}
+	// comm may contain spaces and parentheses; the fields follow the last ')'
+	open, end := bytes.IndexByte(stat, '('), bytes.LastIndexByte(stat, ')')
+	if open < 0 || end < open {
+		return p, fmt.Errorf("malformed %s/stat", dir)
+	}
+	comm := string(stat[open+1 : end])
+	fields := strings.Fields(string(stat[end+1:]))
+	if len(fields) < 22 {
+		return p, fmt.Errorf("malformed %s/stat", dir)
+	}
+	// fields[0] is field 3 (state) of proc(5)
+	p.State = fields[0]
+	p.PPID, _ = strconv.Atoi(fields[1])
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	starttime, _ := strconv.ParseUint(fields[19], 10, 64)
+	rss, _ := strconv.ParseUint(fields[21], 10, 64)
+	p.Time = ticks(utime + stime)
+	p.RSS = rss * uint64(os.Getpagesize())
+
+	if boot, uptime, err := bootTime(proc); err == nil {
+		p.Start = boot.Add(ticks(starttime))
+		if elapsed := uptime - ticks(starttime); elapsed > 0 {
+			p.CPU = float64(p.Time) / float64(elapsed) * 100
+		}
+	}
+	if total := memTotal(proc); total > 0 {
+		p.Mem = float64(p.RSS) / float64(total) * 100
+	}
+
+	p.UID = -1
+	if status, err := os.ReadFile(filepath.Join(dir, "status")); err == nil {
+		for _, line := range strings.Split(string(status), "\n") {
+			if v, ok := strings.CutPrefix(line, "Uid:"); ok {
+				if f := strings.Fields(v); len(f) > 0 {
+					p.UID, _ = strconv.Atoi(f[0])
+				}
+				break
+			}
+		}
+	}
+	p.User = strconv.Itoa(p.UID)
+	if u, err := user.LookupId(p.User); err == nil {
+		p.User = u.Username
+	}
+
+	// kernel threads and zombies have no command line
+	p.Command = "[" + comm + "]"
+	if cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil && len(cmdline) > 0 {
+		p.Command = strings.TrimSpace(string(bytes.ReplaceAll(cmdline, []byte{0}, []byte{' '})))
+	}
+	return p, nil
+}
+
+func ticks(n uint64) time.Duration {
+	return time.Duration(n) * time.Second / clockTicks
+}
+
+// bootTime returns when the host booted and how long ago that was
+func bootTime(proc string) (time.Time, time.Duration, error) {
+	data, err := os.ReadFile(filepath.Join(proc, "uptime"))
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	f := strings.Fields(string(data))
+	if len(f) == 0 {
+		return time.Time{}, 0, fmt.Errorf("malformed %s/uptime", proc)
+	}
+	secs, err := strconv.ParseFloat(f[0], 64)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	uptime := time.Duration(secs * float64(time.Second))
+	return time.Now().Add(-uptime), uptime, nil
+}
+
+// memTotal returns MemTotal from meminfo in bytes (0 when unknown)
+func memTotal(proc string) uint64 {
+	data, err := os.ReadFile(filepath.Join(proc, "meminfo"))
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if v, ok := strings.CutPrefix(line, "MemTotal:"); ok {
+			kb, _ := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(v), " kB"), 10, 64)
+			return kb << 10
+		}
+	}
+	return 0
+}
+
+// FilterPS keeps the header of `ps` output and the rows of the given pids.
+// The PID column is located through the header, so any ps options that
+// print it work.
+func FilterPS(output []byte, pids []int) ([]byte, error) {
+	lines := strings.Split(strings.TrimRight(string(output), "\n"), "\n")
+	col := -1
+	for i, name := range strings.Fields(lines[0]) {
+		if name == "PID" {
+			col = i
+			break
+		}
+	}
+	if col < 0 {
+		return nil, fmt.Errorf("ps output has no PID column (add -o pid or use -ef)")
+	}
+
+	want := map[int]bool{}
+	for _, pid := range pids {
+		want[pid] = true
+	}
+	var out bytes.Buffer
+	out.WriteString(lines[0] + "\n")
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if col >= len(fields) {
+			continue
+		}
+		if pid, err := strconv.Atoi(fields[col]); err == nil && want[pid] {
+			out.WriteString(line + "\n")
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// FormatTime formats cumulative CPU time like ps (HH:MM:SS)
+func FormatTime(d time.Duration) string {
+	s := int(d / time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", s/3600, s/60%60, s%60)
+}
@@ -0,0 +1,29 @@
+package userns
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/arnab2001/boxy/internal/config"
+)
+
+// Lock serialises id allocation between boxy processes. A mapping is only
+// visible to others once the container holding it exists, so the lock is
+// held from Setup until the container is created. The returned function
+// releases it and may be called more than once.
+func Lock() (func(), error) {
+	dir := config.RunDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "userns.lock"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() { f.Close() }, nil // closing the file drops the lock
+}
@@ -0,0 +1,200 @@
+package userns
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/arnab2001/boxy/internal/image"
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/containers"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/leases"
+	"github.com/containerd/containerd/mount"
+	"github.com/containerd/containerd/snapshots"
+	"github.com/opencontainers/image-spec/identity"
+)
+
+// How often and how long a create waits for another one remapping the same
+// image; chowning a large image takes a while
+const (
+	remapPoll = 200 * time.Millisecond
+	remapWait = 5 * time.Minute
+)
+
+// WithRemappedSnapshot prepares the container's snapshot for mapping m.
+// An auto mapping is a single offset, which containerd's own
+// WithRemappedSnapshot handles. containerd cannot express keep-id's hole, so
+// for it the image's root filesystem is copied and chowned to the mapping's
+// host ids. Each container gets its own id block, so the copy is only reused
+// when a later container is given the same block again.
+func WithRemappedSnapshot(id string, img containerd.Image, m Mapping) containerd.NewContainerOpts {
+	if uid, gid, ok := m.offset(); ok {
+		return func(ctx context.Context, c *containerd.Client, info *containers.Container) error {
+			info.Snapshotter = image.Snapshotter
+			return containerd.WithRemappedSnapshot(id, img, uid, gid)(ctx, c, info)
+		}
+	}
+	return func(ctx context.Context, c *containerd.Client, info *containers.Container) error {
+		diffIDs, err := img.RootFS(ctx)
+		if err != nil {
+			return err
+		}
+		parent := identity.ChainID(diffIDs).String()
+		remapped := parent + "-userns-" + m.key()
+
+		// the lease keeps the copy from being collected until the container's
+		// snapshot references it
+		ctx, done, err := c.WithLease(ctx)
+		if err != nil {
+			return err
+		}
+		defer done(ctx)
+
+		sn := c.SnapshotService(image.Snapshotter)
+		if _, err := sn.Stat(ctx, remapped); errdefs.IsNotFound(err) {
+			if err := remapSnapshot(ctx, c, parent, remapped, m); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+		if err := leaseSnapshot(ctx, c, remapped); err != nil {
+			return err
+		}
+
+		gcRef := snapshots.WithLabels(map[string]string{
+			"containerd.io/gc.ref.snapshot." + image.Snapshotter: remapped,
+		})
+		if _, err := sn.Prepare(ctx, id, remapped, gcRef); err != nil {
+			return err
+		}
+		info.Snapshotter = image.Snapshotter
+		info.SnapshotKey = id
+		info.Image = img.Name()
+		return nil
+	}
+}
+
+// offset returns the host ids of a mapping that shifts every container id
+// by the same amount
+func (m Mapping) offset() (uint32, uint32, bool) {
+	if len(m.UIDs) != 1 || len(m.GIDs) != 1 || m.UIDs[0].ContainerID != 0 || m.GIDs[0].ContainerID != 0 {
+		return 0, 0, false
+	}
+	return m.UIDs[0].HostID, m.GIDs[0].HostID, true
+}
+
+// leaseSnapshot adds snapshot name to the lease in ctx
+func leaseSnapshot(ctx context.Context, c *containerd.Client, name string) error {
+	id, ok := leases.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	err := c.LeasesService().AddResource(ctx, leases.Lease{ID: id}, leases.Resource{
+		ID:   name,
+		Type: "snapshots/" + image.Snapshotter,
+	})
+	if errdefs.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+// key identifies a mapping in snapshot names
+func (m Mapping) key() string {
+	sum := sha256.Sum256([]byte(FormatMappings(m.UIDs) + "/" + FormatMappings(m.GIDs)))
+	return hex.EncodeToString(sum[:6])
+}
+
+// remapSnapshot commits a chowned copy of parent as name. When another
+// create is remapping the same image and mapping, its result is used.
+func remapSnapshot(ctx context.Context, c *containerd.Client, parent, name string, m Mapping) error {
+	sn := c.SnapshotService(image.Snapshotter)
+	key := name + "-remap"
+	mounts, err := sn.Prepare(ctx, key, parent)
+	if errdefs.IsAlreadyExists(err) {
+		return waitRemap(ctx, sn, name, key)
+	}
+	if err != nil {
+		return err
+	}
+	err = mount.WithTempMount(ctx, mounts, func(root string) error {
+		return chownTree(root, m)
+	})
+	if err == nil {
+		err = sn.Commit(ctx, name, key)
+		if errdefs.IsAlreadyExists(err) {
+			sn.Remove(ctx, key)
+			return nil // another create won
+		}
+	}
+	if err != nil {
+		sn.Remove(ctx, key)
+		return fmt.Errorf("failed to remap image filesystem: %v", err)
+	}
+	return nil
+}
+
+// waitRemap waits for the create preparing key to commit it as name
+func waitRemap(ctx context.Context, sn snapshots.Snapshotter, name, key string) error {
+	deadline := time.Now().Add(remapWait)
+	for {
+		if _, err := sn.Stat(ctx, name); err == nil {
+			return nil
+		}
+		if _, err := sn.Stat(ctx, key); errdefs.IsNotFound(err) {
+			// committed or given up since the first Stat
+			if _, err := sn.Stat(ctx, name); err == nil {
+				return nil
+			}
+			return fmt.Errorf("failed to remap image filesystem: a concurrent create failed, try again")
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for snapshot %s (left by an interrupted create? remove it with ctr -n boxy snapshots rm)", key)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(remapPoll):
+		}
+	}
+}
+
+// chownTree moves every file's owner into the mapping's host ids; ids the
+// mapping does not cover are left alone (they show up as nobody)
+func chownTree(root string, m Mapping) error {
+	return filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		st, ok := fi.Sys().(*syscall.Stat_t)
+		if !ok {
+			return nil
+		}
+		uid, uidOK := ToHost(m.UIDs, st.Uid)
+		gid, gidOK := ToHost(m.GIDs, st.Gid)
+		if !uidOK {
+			uid = st.Uid
+		}
+		if !gidOK {
+			gid = st.Gid
+		}
+		if uid == st.Uid && gid == st.Gid {
+			return nil
+		}
+		if err := os.Lchown(path, int(uid), int(gid)); err != nil {
+			return err
+		}
+		// chown clears setuid/setgid bits on some filesystems
+		if fi.Mode()&(os.ModeSetuid|os.ModeSetgid) != 0 {
+			return os.Chmod(path, fi.Mode())
+		}
+		return nil
+	})
+}
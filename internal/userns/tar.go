@@ -0,0 +1,79 @@
+package userns
+
+import (
+	"archive/tar"
+	"io"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// ToContainer translates a host id back to the container id mapped to it
+func ToContainer(mappings []specs.LinuxIDMapping, id uint32) (uint32, bool) {
+	for _, m := range mappings {
+		if id >= m.HostID && id-m.HostID < m.Size {
+			return m.ContainerID + id - m.HostID, true
+		}
+	}
+	return 0, false
+}
+
+// UnshiftTar copies a tar stream of a remapped container's filesystem,
+// moving owners from the mapping's host ids back to container ids so the
+// archive looks like one of any other container. Ids the mapping does not
+// cover are left alone, like chownTree does.
+func UnshiftTar(w io.Writer, r io.Reader, m Mapping) error {
+	tr := tar.NewReader(r)
+	tw := tar.NewWriter(w)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return tw.Close()
+		}
+		if err != nil {
+			return err
+		}
+		if uid, ok := ToContainer(m.UIDs, uint32(hdr.Uid)); ok {
+			hdr.Uid = int(uid)
+		}
+		if gid, ok := ToContainer(m.GIDs, uint32(hdr.Gid)); ok {
+			hdr.Gid = int(gid)
+		}
+		// names would be looked up in the host's passwd file
+		hdr.Uname, hdr.Gname = "", ""
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// Unshifted runs write, which produces a tar stream of a remapped
+// container's filesystem, and copies the stream to w through UnshiftTar
+func Unshifted(w io.Writer, m Mapping, write func(io.Writer) error) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(write(pw))
+	}()
+	err := UnshiftTar(w, pr, m)
+	pr.CloseWithError(err) // stops write when unshifting failed
+	return err
+}
+
+// FromLabels returns the mapping recorded in a container's labels (nil for
+// containers sharing the host's user namespace)
+func FromLabels(uidMap, gidMap string) (*Mapping, error) {
+	if uidMap == "" {
+		return nil, nil
+	}
+	uids, err := ParseMappings(uidMap)
+	if err != nil {
+		return nil, err
+	}
+	gids, err := ParseMappings(gidMap)
+	if err != nil {
+		return nil, err
+	}
+	return &Mapping{UIDs: uids, GIDs: gids}, nil
+}
@@ -0,0 +1,270 @@
+package userns
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/arnab2001/boxy/internal/config"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// Modes accepted by --userns
+const (
+	Host   = "host"    // share the host's user namespace (no remapping)
+	Auto   = "auto"    // private range allocated from the remap user's subordinate ids
+	KeepID = "keep-id" // like auto, but the invoking user keeps its uid/gid
+)
+
+// DefaultUser owns the subordinate ranges when the config says "default"
+const DefaultUser = "boxy"
+
+// Range is a contiguous block of host ids
+type Range struct {
+	Start uint32
+	Size  uint32
+}
+
+func (r Range) end() uint32 { return r.Start + r.Size }
+
+func (r Range) overlaps(o Range) bool {
+	return r.Start < o.end() && o.Start < r.end()
+}
+
+// Mapping holds the uid and gid mappings of one container
+type Mapping struct {
+	Mode string // Auto or KeepID
+	UIDs []specs.LinuxIDMapping
+	GIDs []specs.LinuxIDMapping
+}
+
+// ParseMode validates a --userns value ("" means the configured default)
+func ParseMode(mode string) (string, error) {
+	switch mode {
+	case "", Host, Auto, KeepID:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid --userns %q (expected auto, host or keep-id)", mode)
+}
+
+// SubIDs returns the ranges /etc/subuid-style file path grants to the user
+// (entries may name the user or its numeric id)
+func SubIDs(path string, u *user.User) ([]Range, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ranges []Range
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		parts := strings.Split(text, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("%s:%d: expected name:start:count", path, line)
+		}
+		if parts[0] != u.Username && parts[0] != u.Uid {
+			continue
+		}
+		start, err := strconv.ParseUint(parts[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid start %q", path, line, parts[1])
+		}
+		size, err := strconv.ParseUint(parts[2], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid count %q", path, line, parts[2])
+		}
+		if start+size > 1<<32 {
+			return nil, fmt.Errorf("%s:%d: range exceeds the id space", path, line)
+		}
+		ranges = append(ranges, Range{Start: uint32(start), Size: uint32(size)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("no subordinate ids for %s in %s", u.Username, path)
+	}
+	return ranges, nil
+}
+
+// Allocate returns the first block of size ids inside ranges that does not
+// overlap any block in used
+func Allocate(ranges, used []Range, size uint32) (Range, error) {
+	for _, r := range ranges {
+		candidate := Range{Start: r.Start, Size: size}
+		for candidate.end() <= r.end() && candidate.end() > candidate.Start {
+			clash := false
+			for _, u := range used {
+				if candidate.overlaps(u) {
+					candidate.Start = u.end()
+					clash = true
+					break
+				}
+			}
+			if !clash {
+				return candidate, nil
+			}
+		}
+	}
+	return Range{}, fmt.Errorf("no free range of %d subordinate ids left", size)
+}
+
+// Remap maps container ids 0..size-1 onto the host blocks uids and gids
+func Remap(uids, gids Range) Mapping {
+	return Mapping{
+		UIDs: []specs.LinuxIDMapping{{ContainerID: 0, HostID: uids.Start, Size: uids.Size}},
+		GIDs: []specs.LinuxIDMapping{{ContainerID: 0, HostID: gids.Start, Size: gids.Size}},
+	}
+}
+
+// Keep is like Remap but maps uid/gid to themselves, leaving a one-id hole
+// in the subordinate blocks; uid and gid must lie inside the container range
+func Keep(uids, gids Range, uid, gid uint32) (Mapping, error) {
+	if uid == 0 || uid >= uids.Size || gid == 0 || gid >= gids.Size {
+		return Mapping{}, fmt.Errorf("keep-id: %d:%d is outside the container's id range", uid, gid)
+	}
+	return Mapping{
+		UIDs: keepIDs(uids, uid),
+		GIDs: keepIDs(gids, gid),
+	}, nil
+}
+
+func keepIDs(r Range, id uint32) []specs.LinuxIDMapping {
+	m := []specs.LinuxIDMapping{
+		{ContainerID: 0, HostID: r.Start, Size: id},
+		{ContainerID: id, HostID: id, Size: 1},
+	}
+	if rest := r.Size - id - 1; rest > 0 {
+		m = append(m, specs.LinuxIDMapping{ContainerID: id + 1, HostID: r.Start + id, Size: rest})
+	}
+	return m
+}
+
+// ToHost translates a container id to the host id it maps to
+func ToHost(mappings []specs.LinuxIDMapping, id uint32) (uint32, bool) {
+	for _, m := range mappings {
+		if id >= m.ContainerID && id-m.ContainerID < m.Size {
+			return m.HostID + id - m.ContainerID, true
+		}
+	}
+	return 0, false
+}
+
+// Blocks returns the host ranges the mappings occupy, for Allocate's used list
+func Blocks(mappings []specs.LinuxIDMapping) []Range {
+	var blocks []Range
+	for _, m := range mappings {
+		blocks = append(blocks, Range{Start: m.HostID, Size: m.Size})
+	}
+	return blocks
+}
+
+// FormatMappings encodes mappings as "container:host:size,..." for labels
+func FormatMappings(mappings []specs.LinuxIDMapping) string {
+	parts := make([]string, len(mappings))
+	for i, m := range mappings {
+		parts[i] = fmt.Sprintf("%d:%d:%d", m.ContainerID, m.HostID, m.Size)
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseMappings decodes the output of FormatMappings
+func ParseMappings(s string) ([]specs.LinuxIDMapping, error) {
+	var mappings []specs.LinuxIDMapping
+	for _, part := range strings.Split(s, ",") {
+		var m specs.LinuxIDMapping
+		if _, err := fmt.Sscanf(part, "%d:%d:%d", &m.ContainerID, &m.HostID, &m.Size); err != nil {
+			return nil, fmt.Errorf("invalid id mapping %q", part)
+		}
+		mappings = append(mappings, m)
+	}
+	return mappings, nil
+}
+
+// Effective resolves an empty --userns to the configured default
+func Effective(mode string, cfg config.UserNS) string {
+	switch {
+	case mode != "":
+		return mode
+	case cfg.Remap != "":
+		return Auto
+	default:
+		return Host
+	}
+}
+
+// Setup picks the mapping for a new container. mode is an effective --userns
+// value, used the mappings of existing containers. A nil mapping means host.
+func Setup(mode string, cfg config.UserNS, used []Mapping) (*Mapping, error) {
+	if mode == Host {
+		return nil, nil
+	}
+
+	name := cfg.Remap
+	if name == "" || name == "default" {
+		name = DefaultUser
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		u = &user.User{Username: name} // subuid entries don't need a passwd entry
+	}
+	subUIDs, err := SubIDs("/etc/subuid", u)
+	if err != nil {
+		return nil, err
+	}
+	subGIDs, err := SubIDs("/etc/subgid", u)
+	if err != nil {
+		return nil, err
+	}
+
+	var usedUIDs, usedGIDs []Range
+	for _, m := range used {
+		usedUIDs = append(usedUIDs, Blocks(m.UIDs)...)
+		usedGIDs = append(usedGIDs, Blocks(m.GIDs)...)
+	}
+	uids, err := Allocate(subUIDs, usedUIDs, cfg.Size)
+	if err != nil {
+		return nil, err
+	}
+	gids, err := Allocate(subGIDs, usedGIDs, cfg.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	if mode == Auto {
+		m := Remap(uids, gids)
+		m.Mode = Auto
+		return &m, nil
+	}
+	uid, gid, err := invokingUser()
+	if err != nil {
+		return nil, err
+	}
+	m, err := Keep(uids, gids, uid, gid)
+	if err != nil {
+		return nil, err
+	}
+	m.Mode = KeepID
+	return &m, nil
+}
+
+// invokingUser returns the uid/gid of the user behind sudo (or the caller)
+func invokingUser() (uint32, uint32, error) {
+	uid, gid := os.Getuid(), os.Getgid()
+	if s := os.Getenv("SUDO_UID"); s != "" {
+		uid, _ = strconv.Atoi(s)
+		gid, _ = strconv.Atoi(os.Getenv("SUDO_GID"))
+	}
+	if uid <= 0 || gid <= 0 {
+		return 0, 0, fmt.Errorf("keep-id needs a non-root invoking user (run boxy through sudo)")
+	}
+	return uint32(uid), uint32(gid), nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/arnab2001/boxy/internal/attach"
+)
+
+// Test how -i/-t and the terminals decide the stdio mode
+func TestResolveAttachMode(t *testing.T) {
+	tests := []struct {
+		name          string
+		flags         *attach.Mode
+		stdin, stdout bool
+		expected      attach.Mode
+		wantErr       bool
+	}{
+		{"terminal", nil, true, true, attach.Mode{Stdin: true, TTY: true}, false},
+		{"piped stdin", nil, false, true, attach.Mode{Stdin: true}, false},
+		{"piped stdout", nil, true, false, attach.Mode{Stdin: true}, false},
+		{"-i", &attach.Mode{Stdin: true}, true, true, attach.Mode{Stdin: true}, false},
+		{"-t", &attach.Mode{TTY: true}, false, false, attach.Mode{TTY: true}, false},
+		{"-it", &attach.Mode{Stdin: true, TTY: true}, true, true, attach.Mode{Stdin: true, TTY: true}, false},
+		{"-it piped", &attach.Mode{Stdin: true, TTY: true}, false, true, attach.Mode{}, true},
+	}
+	for _, tt := range tests {
+		got, err := attach.Resolve(tt.flags, tt.stdin, tt.stdout)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v; expected error: %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got != tt.expected {
+			t.Errorf("%s: mode = %+v; expected %+v", tt.name, got, tt.expected)
+		}
+	}
+}
+
+// Test that stdin EOF closes the task's stdin, before or after it exists
+func TestStdinCloser(t *testing.T) {
+	closed := 0
+	in := attach.NewStdinCloser(strings.NewReader("hi\n"))
+	in.SetClose(func() { closed++ })
+	data, err := io.ReadAll(in)
+	if err != nil || string(data) != "hi\n" {
+		t.Fatalf("ReadAll = %q, %v", data, err)
+	}
+	in.Read(make([]byte, 1)) // reading past EOF again closes only once
+	if closed != 1 {
+		t.Errorf("closed %d times; expected once", closed)
+	}
+
+	// EOF before the task exists: SetClose closes right away
+	early := attach.NewStdinCloser(strings.NewReader(""))
+	io.ReadAll(early)
+	closed = 0
+	early.SetClose(func() { closed++ })
+	if closed != 1 {
+		t.Errorf("early EOF closed %d times; expected once", closed)
+	}
+}
+
+// Test --detach-keys parsing
+func TestParseDetachKeys(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected []byte
+		wantErr  bool
+	}{
+		{"ctrl-p,ctrl-q", []byte{16, 17}, false},
+		{"CTRL-A,x", []byte{1, 'x'}, false},
+		{`ctrl-@,ctrl-[,ctrl-\,ctrl-_`, []byte{0, 27, 28, 31}, false},
+		{"", nil, false},
+		{"ctrl-1", nil, true},
+		{"alt-x", nil, true},
+		{"ctrl-p,", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := attach.ParseKeys(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseKeys(%q) error = %v; expected error: %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && string(got) != string(tt.expected) {
+			t.Errorf("ParseKeys(%q) = %v; expected %v", tt.in, got, tt.expected)
+		}
+	}
+}
+
+// Test that the detach keys are swallowed and end the input
+func TestDetachReader(t *testing.T) {
+	keys := []byte{16, 17} // ctrl-p,ctrl-q
+	tests := []struct {
+		name     string
+		in       string
+		expected string
+		detached bool
+	}{
+		{"plain", "ls\n", "ls\n", false},
+		{"detach", "ls\n\x10\x11echo lost\n", "ls\n", true},
+		{"partial", "a\x10b\x10", "a\x10b\x10", false},
+		{"repeated prefix", "\x10\x10\x11", "\x10", true},
+	}
+	for _, tt := range tests {
+		d := attach.NewDetachReader(strings.NewReader(tt.in), keys)
+		data, err := io.ReadAll(d)
+		if tt.detached != (err == attach.ErrDetached) {
+			t.Errorf("%s: error = %v; expected detached: %v", tt.name, err, tt.detached)
+		}
+		if string(data) != tt.expected {
+			t.Errorf("%s: passed %q; expected %q", tt.name, data, tt.expected)
+		}
+		select {
+		case <-d.Detached():
+			if !tt.detached {
+				t.Errorf("%s: Detached closed", tt.name)
+			}
+		default:
+			if tt.detached {
+				t.Errorf("%s: Detached not closed", tt.name)
+			}
+		}
+	}
+}
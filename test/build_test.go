@@ -0,0 +1,204 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/arnab2001/boxy/internal/build"
+	"github.com/arnab2001/boxy/internal/image"
+)
+
+// Test Dockerfile parsing (stages, continuations, exec/shell forms)
+func TestParseDockerfile(t *testing.T) {
+	src := `# syntax comment
+ARG BASE=alpine:3.20
+FROM ${BASE} AS builder
+RUN apk add --no-cache \
+    build-base \
+    git
+COPY --chown=1000:1000 src/ /src/
+WORKDIR /src
+
+FROM scratch
+COPY --from=builder /src/app /app
+ENTRYPOINT ["/app"]
+CMD --help
+`
+	df, err := build.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Expected Dockerfile to parse, got: %v", err)
+	}
+
+	if len(df.MetaArgs) != 1 || df.MetaArgs[0].Rest != "BASE=alpine:3.20" {
+		t.Errorf("Unexpected meta args: %+v", df.MetaArgs)
+	}
+	if len(df.Stages) != 2 {
+		t.Fatalf("Expected 2 stages, got %d", len(df.Stages))
+	}
+
+	builder := df.Stages[0]
+	if builder.Base != "${BASE}" || builder.Name != "builder" {
+		t.Errorf("Unexpected first stage: base=%q name=%q", builder.Base, builder.Name)
+	}
+	if len(builder.Instructions) != 4 {
+		t.Fatalf("Expected 4 instructions in first stage, got %d", len(builder.Instructions))
+	}
+
+	run := builder.Instructions[1]
+	if run.Cmd != "RUN" || run.Rest != "apk add --no-cache build-base git" || run.Line != 4 {
+		t.Errorf("Unexpected RUN: %+v", run)
+	}
+
+	cp := builder.Instructions[2]
+	if cp.Flags["chown"] != "1000:1000" || cp.Rest != "src/ /src/" {
+		t.Errorf("Unexpected COPY flags/args: %+v", cp)
+	}
+
+	final := df.Stages[1]
+	if final.Instructions[1].Flags["from"] != "builder" {
+		t.Errorf("Expected COPY --from=builder, got %+v", final.Instructions[1].Flags)
+	}
+	ep := final.Instructions[2]
+	if !ep.IsJSON() || len(ep.JSON) != 1 || ep.JSON[0] != "/app" {
+		t.Errorf("Expected exec form ENTRYPOINT, got %+v", ep)
+	}
+	if final.Instructions[3].IsJSON() {
+		t.Error("Expected shell form CMD")
+	}
+
+	if idx, ok := df.StageIndex("BUILDER"); !ok || idx != 0 {
+		t.Errorf("Expected stage lookup by name, got %d %v", idx, ok)
+	}
+	if idx, ok := df.StageIndex("1"); !ok || idx != 1 {
+		t.Errorf("Expected stage lookup by index, got %d %v", idx, ok)
+	}
+	if _, ok := df.StageIndex("missing"); ok {
+		t.Error("Expected missing stage not to be found")
+	}
+}
+
+// Test Dockerfile parse errors
+func TestParseDockerfileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"no_from", "RUN echo hi\n"},
+		{"empty", "# only a comment\n"},
+		{"bad_from", "FROM alpine AS\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := build.Parse(strings.NewReader(tt.src)); err == nil {
+				t.Errorf("Expected error for %q", tt.src)
+			}
+		})
+	}
+}
+
+// Test build variable expansion
+func TestExpand(t *testing.T) {
+	vars := map[string]string{"NAME": "boxy", "EMPTY": ""}
+	lookup := func(k string) (string, bool) { v, ok := vars[k]; return v, ok }
+
+	tests := []struct {
+		in       string
+		expected string
+	}{
+		{"$NAME", "boxy"},
+		{"${NAME}-app", "boxy-app"},
+		{"${MISSING:-default}", "default"},
+		{"${EMPTY:-fallback}", "fallback"},
+		{"${NAME:+set}", "set"},
+		{"${MISSING:+set}", ""},
+		{`\$NAME`, "$NAME"},
+		{"cost $5", "cost $5"},
+	}
+	for _, tt := range tests {
+		if got := build.Expand(tt.in, lookup); got != tt.expected {
+			t.Errorf("Expand(%q) = %q, expected %q", tt.in, got, tt.expected)
+		}
+	}
+}
+
+// Test .dockerignore matching
+func TestDockerignore(t *testing.T) {
+	ig, err := build.NewIgnore([]string{
+		"# comment",
+		"node_modules",
+		"**/*.log",
+		"/tmp",
+		"docs/*.md",
+		"!docs/README.md",
+	})
+	if err != nil {
+		t.Fatalf("Expected patterns to compile, got: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		excluded bool
+	}{
+		{"node_modules", true},
+		{"node_modules/pkg/index.js", true},
+		{"app.log", true},
+		{"logs/deep/app.log", true},
+		{"tmp/cache", true},
+		{"docs/guide.md", true},
+		{"docs/README.md", false},
+		{"src/main.go", false},
+		{"Dockerfile", false},
+	}
+	for _, tt := range tests {
+		if got := ig.Excluded(tt.path); got != tt.excluded {
+			t.Errorf("Excluded(%q) = %v, expected %v", tt.path, got, tt.excluded)
+		}
+	}
+	if !ig.HasExceptions() {
+		t.Error("Expected exception patterns to be reported")
+	}
+}
+
+// Test `boxy commit --change` instructions applied to an image config
+func TestApplyChanges(t *testing.T) {
+	state := image.Scratch()
+	state.Config.Config.Cmd = []string{"/bin/sh"}
+	state.Config.Config.Env = []string{"PATH=/usr/bin"}
+
+	err := build.ApplyChanges(state, []string{
+		`CMD ["nginx", "-g", "daemon off;"]`,
+		"ENV=MODE=prod",
+		"EXPOSE 80 443/tcp",
+		"WORKDIR=/srv",
+		"LABEL version=1.0",
+		"USER=www-data",
+		"STOPSIGNAL SIGQUIT",
+	})
+	if err != nil {
+		t.Fatalf("Expected changes to apply, got: %v", err)
+	}
+
+	cfg := state.Config.Config
+	if strings.Join(cfg.Cmd, " ") != "nginx -g daemon off;" {
+		t.Errorf("Unexpected CMD: %v", cfg.Cmd)
+	}
+	if len(cfg.Env) != 2 || cfg.Env[1] != "MODE=prod" {
+		t.Errorf("Unexpected ENV: %v", cfg.Env)
+	}
+	if _, ok := cfg.ExposedPorts["80/tcp"]; !ok || len(cfg.ExposedPorts) != 2 {
+		t.Errorf("Unexpected exposed ports: %v", cfg.ExposedPorts)
+	}
+	if cfg.WorkingDir != "/srv" || cfg.User != "www-data" || cfg.StopSignal != "SIGQUIT" {
+		t.Errorf("Unexpected config: workdir=%q user=%q stopsignal=%q", cfg.WorkingDir, cfg.User, cfg.StopSignal)
+	}
+	if cfg.Labels["version"] != "1.0" {
+		t.Errorf("Unexpected labels: %v", cfg.Labels)
+	}
+	if len(state.Config.History) != 0 {
+		t.Errorf("Expected changes not to add history, got %d entries", len(state.Config.History))
+	}
+
+	if err := build.ApplyChanges(state, []string{"RUN rm -rf /"}); err == nil {
+		t.Error("Expected RUN to be rejected as a change")
+	}
+}
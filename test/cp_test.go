@@ -0,0 +1,112 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/arnab2001/boxy/internal/snapshot"
+)
+
+// Test copying a directory tree through a tar stream
+func TestTarRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "conf", "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "conf", "app.conf"), []byte("port=80\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("app.conf", filepath.Join(src, "conf", "link.conf")); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := snapshot.WriteTar(&buf, src, "/conf", "renamed", snapshot.TarOptions{}); err != nil {
+		t.Fatalf("WriteTar failed: %v", err)
+	}
+
+	dst := t.TempDir()
+	if err := snapshot.ExtractTar(&buf, dst, snapshot.TarOptions{}); err != nil {
+		t.Fatalf("ExtractTar failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dst, "renamed", "app.conf"))
+	if err != nil || string(data) != "port=80\n" {
+		t.Errorf("Expected copied file content, got %q (%v)", data, err)
+	}
+	fi, err := os.Stat(filepath.Join(dst, "renamed", "app.conf"))
+	if err != nil || fi.Mode().Perm() != 0640 {
+		t.Errorf("Expected mode 0640 to be preserved, got %v (%v)", fi.Mode().Perm(), err)
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "renamed", "link.conf")); err != nil || link != "app.conf" {
+		t.Errorf("Expected symlink to be preserved, got %q (%v)", link, err)
+	}
+	if fi, err := os.Stat(filepath.Join(dst, "renamed", "sub")); err != nil || !fi.IsDir() {
+		t.Errorf("Expected sub directory to be copied (%v)", err)
+	}
+}
+
+// Test that symlinks in the source root can't leak files from outside it
+func TestWriteTarSymlinkBounded(t *testing.T) {
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("host"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	root := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err := snapshot.WriteTar(&buf, root, "/escape/secret", "secret", snapshot.TarOptions{})
+	if err == nil {
+		t.Error("Expected path through an escaping symlink not to resolve outside the root")
+	}
+}
+
+// Test that archive entries can't be written outside the destination
+func TestExtractTarEscape(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []*tar.Header
+		file    string
+	}{
+		{
+			name:    "dot_dot",
+			entries: []*tar.Header{{Name: "../../evil", Typeflag: tar.TypeReg, Mode: 0644}},
+			file:    "evil",
+		},
+		{
+			name: "through_symlink",
+			entries: []*tar.Header{
+				{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/tmp", Mode: 0777},
+				{Name: "link/evil", Typeflag: tar.TypeReg, Mode: 0644},
+			},
+			file: "tmp/evil",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			for _, h := range tt.entries {
+				if err := tw.WriteHeader(h); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tw.Close()
+
+			dst := t.TempDir()
+			if err := snapshot.ExtractTar(&buf, dst, snapshot.TarOptions{}); err != nil {
+				t.Fatalf("ExtractTar failed: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(dst, tt.file)); err != nil {
+				t.Errorf("Expected entry to be contained in destination as %s: %v", tt.file, err)
+			}
+		})
+	}
+}
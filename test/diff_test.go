@@ -0,0 +1,117 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/arnab2001/boxy/internal/snapshot"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for name, data := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Test the A/C/D lines of boxy diff
+func TestChanges(t *testing.T) {
+	lower, upper := t.TempDir(), t.TempDir()
+	writeFiles(t, lower, map[string]string{
+		"etc/hostname":  "old",
+		"etc/motd":      "welcome",
+		"usr/bin/tool":  "binary",
+		"var/log/empty": "",
+	})
+	writeFiles(t, upper, map[string]string{
+		"etc/hostname":      "a longer new name",
+		"usr/bin/tool":      "binary",
+		"var/log/empty":     "",
+		"var/log/app/today": "started",
+	})
+	// unchanged files must keep their metadata to compare equal
+	for _, name := range []string{"usr/bin/tool", "var/log/empty"} {
+		st, err := os.Stat(filepath.Join(lower, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(filepath.Join(upper, name), st.ModTime(), st.ModTime()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	err := snapshot.Changes(context.Background(), lower, upper, func(c snapshot.Change) error {
+		got = append(got, c.String())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	expected := []string{"A /var/log/app", "A /var/log/app/today", "C /etc/hostname", "D /etc/motd"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %q; expected %q", got, expected)
+	}
+}
+
+// Test exporting a root filesystem as a tar archive
+func TestExport(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"etc/app.conf": "port=80\n", "bin/run": "#!/bin/sh\n"})
+	if err := os.Chmod(filepath.Join(root, "bin", "run"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(root, "etc", "app.conf"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../etc/app.conf", filepath.Join(root, "bin", "conf")); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := snapshot.Export(context.Background(), root, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	type entry struct {
+		mode   int64
+		link   string
+		output string
+	}
+	got := map[string]entry{}
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(tr)
+		got[hdr.Name] = entry{hdr.Mode & 0777, hdr.Linkname, string(data)}
+	}
+	expected := map[string]entry{
+		"bin/":         {0755, "", ""},
+		"bin/conf":     {0777, "../etc/app.conf", ""},
+		"bin/run":      {0755, "", "#!/bin/sh\n"},
+		"etc/":         {0755, "", ""},
+		"etc/app.conf": {0600, "", "port=80\n"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %+v\nexpected %+v", got, expected)
+	}
+}
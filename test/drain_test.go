@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/arnab2001/boxy/internal/attach"
+)
+
+// writeWithin fails the test when writing data to f blocks for longer than
+// timeout, as a container's write to a full fifo would
+func writeWithin(t *testing.T, f *os.File, data []byte, timeout time.Duration) {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() {
+		_, err := f.Write(data)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	case <-time.After(timeout):
+		t.Fatalf("writing %d bytes blocked for %s", len(data), timeout)
+	}
+}
+
+// Test that output written while detached is drained, and that a client
+// holding the fifo gets everything written meanwhile
+func TestDrainFifo(t *testing.T) {
+	dir := t.TempDir()
+	fifo := filepath.Join(dir, "stdout")
+	if err := syscall.Mkfifo(fifo, 0600); err != nil {
+		t.Fatalf("Mkfifo: %v", err)
+	}
+	// the client's read end, opened first so the writer does not wait
+	client, err := os.OpenFile(fifo, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		t.Fatalf("open reader: %v", err)
+	}
+	defer client.Close()
+	w, err := os.OpenFile(fifo, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("open writer: %v", err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	drained := make(chan error, 1)
+	go func() { drained <- attach.Drain(ctx, dir, fifo, "") }()
+
+	// well beyond the 64 KiB pipe buffer
+	big := bytes.Repeat([]byte("x"), 256<<10)
+	writeWithin(t, w, big, 5*time.Second)
+
+	release, err := attach.Hold(dir)
+	if err != nil {
+		t.Fatalf("Hold: %v", err)
+	}
+	writeWithin(t, w, []byte("hello"), time.Second)
+	var got []byte
+	buf := make([]byte, 64<<10)
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for !bytes.HasSuffix(got, []byte("hello")) {
+		n, err := client.Read(buf)
+		got = append(got, buf[:n]...)
+		if err != nil {
+			t.Fatalf("client read %q...: %v", bytes.TrimLeft(got, "x"), err)
+		}
+	}
+	release()
+
+	writeWithin(t, w, big, 5*time.Second)
+	w.Close()
+	select {
+	case err := <-drained:
+		if err != nil {
+			t.Errorf("Drain: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Drain did not return after the writer closed")
+	}
+}
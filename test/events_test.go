@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/arnab2001/boxy/internal/events"
+	apievents "github.com/containerd/containerd/api/events"
+	ctrevents "github.com/containerd/containerd/events"
+	"github.com/containerd/typeurl/v2"
+)
+
+func envelope(t *testing.T, topic string, v interface{}) *ctrevents.Envelope {
+	a, err := typeurl.MarshalAny(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &ctrevents.Envelope{Timestamp: time.Now(), Namespace: "boxy", Topic: topic, Event: a}
+}
+
+// Test converting containerd events into boxy's event schema
+func TestDecodeEvents(t *testing.T) {
+	tests := []struct {
+		topic    string
+		event    interface{}
+		expected string // type/action/id
+		attrs    map[string]string
+	}{
+		{"/containers/create", &apievents.ContainerCreate{ID: "web", Image: "docker.io/library/nginx:latest"},
+			"container/create/web", map[string]string{"image": "docker.io/library/nginx:latest"}},
+		{"/tasks/start", &apievents.TaskStart{ContainerID: "web", Pid: 42},
+			"container/start/web", map[string]string{"pid": "42"}},
+		{"/tasks/exit", &apievents.TaskExit{ContainerID: "web", ID: "web", Pid: 42, ExitStatus: 137},
+			"container/die/web", map[string]string{"pid": "42", "exitCode": "137"}},
+		{"/tasks/exit", &apievents.TaskExit{ContainerID: "web", ID: "sh-1", Pid: 50},
+			"container/exec_die/web", map[string]string{"pid": "50", "exitCode": "0", "execID": "sh-1"}},
+		{"/tasks/oom", &apievents.TaskOOM{ContainerID: "web"}, "container/oom/web", nil},
+		{"/tasks/delete", &apievents.TaskDelete{ContainerID: "web", ExitStatus: 1},
+			"container/delete/web", map[string]string{"exitCode": "1"}},
+		{"/containers/delete", &apievents.ContainerDelete{ID: "web"}, "container/destroy/web", nil},
+		{"/images/create", &apievents.ImageCreate{Name: "docker.io/library/redis:7"}, "image/create/docker.io/library/redis:7", nil},
+		{"/snapshot/prepare", &apievents.SnapshotPrepare{Key: "web", Parent: "sha256:abc", Snapshotter: "overlayfs"},
+			"snapshot/prepare/web", map[string]string{"parent": "sha256:abc", "snapshotter": "overlayfs"}},
+	}
+	for _, tt := range tests {
+		e, ok, err := events.Decode(envelope(t, tt.topic, tt.event))
+		if err != nil || !ok {
+			t.Errorf("%s: %v, %v", tt.topic, ok, err)
+			continue
+		}
+		if got := e.Type + "/" + e.Action + "/" + e.ID; got != tt.expected {
+			t.Errorf("%s: got %s; expected %s", tt.topic, got, tt.expected)
+		}
+		if len(e.Attributes) != len(tt.attrs) {
+			t.Errorf("%s: attributes %v; expected %v", tt.topic, e.Attributes, tt.attrs)
+		}
+		for k, v := range tt.attrs {
+			if e.Attributes[k] != v {
+				t.Errorf("%s: attribute %s = %q; expected %q", tt.topic, k, e.Attributes[k], v)
+			}
+		}
+	}
+
+	// exec task deletes and unrelated topics are skipped
+	if _, ok, _ := events.Decode(envelope(t, "/tasks/delete", &apievents.TaskDelete{ContainerID: "web", ID: "sh-1"})); ok {
+		t.Error("exec delete should be skipped")
+	}
+	if _, ok, _ := events.Decode(envelope(t, "/namespaces/create", &apievents.NamespaceCreate{Name: "x"})); ok {
+		t.Error("namespace event should be skipped")
+	}
+}
+
+// Test reporting container record updates as renames
+func TestRenameEvents(t *testing.T) {
+	update := func(name string) *events.Event {
+		e, ok, err := events.Decode(envelope(t, "/containers/update",
+			&apievents.ContainerUpdate{ID: "4f2a9c", Labels: map[string]string{"boxy.name": name}}))
+		if err != nil || !ok {
+			t.Fatalf("update: %v, %v", ok, err)
+		}
+		return e
+	}
+
+	e := update("api")
+	if e.Action != "update" || e.Attributes["name"] != "api" {
+		t.Fatalf("got %s %v; expected update with name api", e.Action, e.Attributes)
+	}
+	if !events.Rename(e, "web") || e.Action != "rename" || e.Attributes["oldName"] != "web" {
+		t.Errorf("got %s %v; expected rename from web", e.Action, e.Attributes)
+	}
+
+	// label bookkeeping without a name change, or with an unknown old name
+	if e := update("web"); events.Rename(e, "web") {
+		t.Error("update keeping the name should not be reported")
+	}
+	if e := update("api"); events.Rename(e, "") {
+		t.Error("update of an unknown container should not be reported")
+	}
+}
+
+// Test --filter parsing and matching
+func TestEventFilters(t *testing.T) {
+	f, err := events.ParseFilters([]string{"type=container,event=die", "event=oom"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	die := &events.Event{Type: "container", Action: "die", ID: "web"}
+	oom := &events.Event{Type: "container", Action: "oom", ID: "web"}
+	start := &events.Event{Type: "container", Action: "start", ID: "web"}
+	imgDelete := &events.Event{Type: "image", Action: "delete", ID: "nginx"}
+	if !f.Match(die) || !f.Match(oom) || f.Match(start) || f.Match(imgDelete) {
+		t.Error("type=container,event=die,event=oom matched the wrong events")
+	}
+
+	byName, _ := events.ParseFilters([]string{"container=web"})
+	if !byName.Match(start) || byName.Match(&events.Event{Type: "container", ID: "db"}) || byName.Match(imgDelete) {
+		t.Error("container=web matched the wrong events")
+	}
+	named := &events.Event{Type: "container", ID: "4f2a9c", Attributes: map[string]string{"name": "web"}}
+	if !byName.Match(named) {
+		t.Error("container=web should match the container's name attribute")
+	}
+	if !(events.Filter{}).Match(imgDelete) {
+		t.Error("empty filter should match everything")
+	}
+
+	for _, bad := range []string{"type", "colour=red", "event="} {
+		if _, err := events.ParseFilters([]string{bad}); err == nil {
+			t.Errorf("ParseFilters(%q): expected an error", bad)
+		}
+	}
+}
+
+// Test that container events carry the container's name and image, also
+// after the container is gone, so that image= matches exits
+func TestAnnotateEvents(t *testing.T) {
+	loads := 0
+	known := events.NewContainers(func(id string) (string, string, error) {
+		loads++
+		if id != "4f2a9c" {
+			return "", "", fmt.Errorf("container %s not found", id)
+		}
+		return "web", "docker.io/library/nginx:latest", nil
+	})
+	byImage, err := events.ParseFilters([]string{"image=docker.io/library/nginx:latest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	die, ok, err := events.Decode(envelope(t, "/tasks/exit", &apievents.TaskExit{ContainerID: "4f2a9c", ID: "4f2a9c", Pid: 42, ExitStatus: 137}))
+	if err != nil || !ok {
+		t.Fatalf("Decode: ok=%v, %v", ok, err)
+	}
+	if byImage.Match(die) {
+		t.Error("image= matched an exit before the image was filled in")
+	}
+	if !known.Annotate(die) {
+		t.Fatal("die event dropped")
+	}
+	if die.Attributes["name"] != "web" || die.Attributes["image"] != "docker.io/library/nginx:latest" {
+		t.Errorf("die attributes = %v", die.Attributes)
+	}
+	if !byImage.Match(die) {
+		t.Error("image= should match the exit of a container created from the image")
+	}
+	if other, _ := events.ParseFilters([]string{"image=alpine"}); other.Match(die) {
+		t.Error("image=alpine matched an nginx container")
+	}
+
+	// the destroyed container is no longer loadable; the cache still knows it
+	destroy, _, _ := events.Decode(envelope(t, "/containers/delete", &apievents.ContainerDelete{ID: "4f2a9c"}))
+	known.Annotate(destroy)
+	if !byImage.Match(destroy) || destroy.Attributes["name"] != "web" || loads != 1 {
+		t.Errorf("destroy attributes = %v after %d loads", destroy.Attributes, loads)
+	}
+
+	// containers created while streaming are known from their create event
+	create, _, _ := events.Decode(envelope(t, "/containers/create", &apievents.ContainerCreate{ID: "77b1e0", Image: "docker.io/library/redis:7"}))
+	known.Annotate(create)
+	oom, _, _ := events.Decode(envelope(t, "/tasks/oom", &apievents.TaskOOM{ContainerID: "77b1e0"}))
+	known.Annotate(oom)
+	if oom.Attributes["image"] != "docker.io/library/redis:7" {
+		t.Errorf("oom attributes = %v", oom.Attributes)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/arnab2001/boxy/internal/exitcode"
+)
+
+// Test how task start failures map to docker's exit statuses
+func TestExitCodeFromStart(t *testing.T) {
+	tests := []struct {
+		msg      string
+		expected int
+	}{
+		{`failed to create shim task: OCI runtime create failed: runc create failed: unable to start container process: exec: "nope": executable file not found in $PATH: unknown`, 127},
+		{`OCI runtime create failed: unable to start container process: exec: "/bin/nope": stat /bin/nope: no such file or directory: unknown`, 127},
+		{`OCI runtime create failed: unable to start container process: exec: "/etc/passwd": permission denied: unknown`, 126},
+		{`OCI runtime create failed: unable to start container process: exec: "/etc": is a directory: unknown`, 126},
+		{`OCI runtime create failed: error mounting "/srv/missing": no such file or directory: unknown`, 125},
+		{`snapshot "web-snap" already exists`, 125},
+	}
+	for _, tt := range tests {
+		if got := exitcode.FromStart(errors.New(tt.msg)).Code; got != tt.expected {
+			t.Errorf("FromStart(%q) = %d; expected %d", tt.msg, got, tt.expected)
+		}
+	}
+}
+
+// Test the exit status of command errors
+func TestExitCode(t *testing.T) {
+	plain := errors.New("boom")
+	if got := exitcode.Code(nil); got != 0 {
+		t.Errorf("Code(nil) = %d; expected 0", got)
+	}
+	if got := exitcode.Code(plain); got != 1 {
+		t.Errorf("Code(plain) = %d; expected 1", got)
+	}
+	if got := exitcode.Code(exitcode.Wrap(plain)); got != exitcode.Failed {
+		t.Errorf("Code(Wrap(plain)) = %d; expected %d", got, exitcode.Failed)
+	}
+
+	// a container's own exit status survives wrapping and has no message
+	exited := exitcode.Wrap(&exitcode.Error{Code: 3})
+	if got := exitcode.Code(fmt.Errorf("run: %w", exited)); got != 3 {
+		t.Errorf("Code(exit 3) = %d; expected 3", got)
+	}
+	if exited.Error() != "" {
+		t.Errorf("container exit has message %q", exited.Error())
+	}
+	if !errors.Is(exitcode.Wrap(plain), plain) {
+		t.Error("Wrap hides the original error")
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/arnab2001/boxy/internal/health"
+)
+
+// Test health check configs in the image config format
+func TestHealthConfig(t *testing.T) {
+	var cfg health.Config
+	data := `{"Test":["CMD-SHELL","curl -f http://localhost/ || exit 1"],"Interval":5000000000,"Retries":2}`
+	if err := json.Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Interval != 5*time.Second || !cfg.Enabled() {
+		t.Errorf("decoded %+v", cfg)
+	}
+	args, err := cfg.Args()
+	if err != nil || !reflect.DeepEqual(args, []string{"/bin/sh", "-c", "curl -f http://localhost/ || exit 1"}) {
+		t.Errorf("Args = %q, %v", args, err)
+	}
+	d := cfg.WithDefaults()
+	if d.Interval != 5*time.Second || d.Timeout != health.DefaultTimeout || d.Retries != 2 {
+		t.Errorf("WithDefaults = %+v", d)
+	}
+
+	exec := health.Config{Test: []string{"CMD", "pg_isready", "-U", "postgres"}}
+	if args, _ := exec.Args(); !reflect.DeepEqual(args, []string{"pg_isready", "-U", "postgres"}) {
+		t.Errorf("CMD args = %q", args)
+	}
+	if (&health.Config{Test: []string{"NONE"}}).Enabled() || (*health.Config)(nil).Enabled() {
+		t.Error("NONE and nil configs should be disabled")
+	}
+	if _, err := (&health.Config{Test: []string{"CMD"}}).Args(); err == nil {
+		t.Error("expected an error for CMD without arguments")
+	}
+}
+
+// Test health status transitions
+func TestHealthRecord(t *testing.T) {
+	cfg := health.Config{Retries: 2, StartPeriod: time.Minute}.WithDefaults()
+	started := time.Now()
+	fail := func(at time.Duration) health.Result {
+		return health.Result{Start: started.Add(at), ExitCode: 1, Output: "connection refused"}
+	}
+	ok := func(at time.Duration) health.Result { return health.Result{Start: started.Add(at)} }
+
+	s := &health.State{Status: health.Starting}
+	s.Record(cfg, started, fail(10*time.Second))
+	s.Record(cfg, started, fail(20*time.Second))
+	if s.Status != health.Starting || s.FailingStreak != 0 {
+		t.Errorf("failures in the start period counted: %+v", s)
+	}
+	s.Record(cfg, started, ok(30*time.Second))
+	if s.Status != health.Healthy {
+		t.Errorf("status after success = %s", s.Status)
+	}
+	s.Record(cfg, started, fail(40*time.Second))
+	if s.Status != health.Healthy || s.FailingStreak != 1 {
+		t.Errorf("one failure after healthy: %+v", s)
+	}
+	s.Record(cfg, started, fail(50*time.Second))
+	if s.Status != health.Unhealthy || s.FailingStreak != 2 {
+		t.Errorf("retries exhausted: %+v", s)
+	}
+	for i := 0; i < 10; i++ {
+		s.Record(cfg, started, ok(time.Duration(60+i)*time.Second))
+	}
+	if s.Status != health.Healthy || s.FailingStreak != 0 || len(s.Log) != 5 {
+		t.Errorf("recovered state: status %s, streak %d, %d log entries", s.Status, s.FailingStreak, len(s.Log))
+	}
+
+	// after the start period failures count even before the first success
+	late := &health.State{Status: health.Starting}
+	late.Record(cfg, started, fail(2*time.Minute))
+	late.Record(cfg, started, fail(3*time.Minute))
+	if late.Status != health.Unhealthy {
+		t.Errorf("status after start period = %s", late.Status)
+	}
+}
+
+// Test saving and loading the health state of a container
+func TestHealthState(t *testing.T) {
+	t.Setenv("BOXY_RUN_DIR", t.TempDir())
+	if s, err := health.Load("web"); s != nil || err != nil {
+		t.Errorf("missing state = %+v, %v", s, err)
+	}
+	saved := &health.State{Status: health.Healthy, Log: []health.Result{{ExitCode: 0, Output: "ok"}}}
+	if err := saved.Save("web"); err != nil {
+		t.Fatal(err)
+	}
+	s, err := health.Load("web")
+	if err != nil || s.Status != health.Healthy || len(s.Log) != 1 || s.Log[0].Output != "ok" {
+		t.Errorf("loaded %+v, %v", s, err)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/arnab2001/boxy/internal/metrics"
+	"github.com/arnab2001/boxy/internal/stats"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Test the Prometheus metrics exported for running and exited containers
+func TestMetricsCollector(t *testing.T) {
+	exit := uint32(137)
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(&metrics.Collector{List: func() ([]metrics.Container, error) {
+		return []metrics.Container{
+			{Name: "web", Image: "nginx", Running: true, Restarts: 2, Ports: 1, Sample: &stats.Sample{
+				CPU: 1.5e9, Memory: 64 << 20, Pids: 3, NetRx: 100, NetTx: 50,
+			}},
+			{Name: "job", Image: "alpine", ExitCode: &exit},
+		}, nil
+	}})
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]float64{}
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			name := ""
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "name" {
+					name = l.GetValue()
+				}
+			}
+			v := m.GetGauge().GetValue() + m.GetCounter().GetValue()
+			got[f.GetName()+"/"+name] = v
+		}
+	}
+	expected := map[string]float64{
+		"boxy_container_running/web":                      1,
+		"boxy_container_restarts_total/web":               2,
+		"boxy_container_published_ports/web":              1,
+		"boxy_container_cpu_seconds_total/web":            1.5,
+		"boxy_container_memory_usage_bytes/web":           64 << 20,
+		"boxy_container_pids/web":                         3,
+		"boxy_container_network_receive_bytes_total/web":  100,
+		"boxy_container_network_transmit_bytes_total/web": 50,
+		"boxy_container_running/job":                      0,
+		"boxy_container_exit_code/job":                    137,
+	}
+	for k, v := range expected {
+		if g, ok := got[k]; !ok || g != v {
+			t.Errorf("%s = %v (present: %v); expected %v", k, g, ok, v)
+		}
+	}
+	for _, absent := range []string{"boxy_container_memory_limit_bytes/web", "boxy_container_exit_code/web", "boxy_container_pids/job"} {
+		if _, ok := got[absent]; ok {
+			t.Errorf("%s should not be exported", absent)
+		}
+	}
+
+	failing := prometheus.NewRegistry()
+	failing.MustRegister(&metrics.Collector{List: func() ([]metrics.Container, error) {
+		return nil, errors.New("containerd unavailable")
+	}})
+	if _, err := failing.Gather(); err == nil {
+		t.Error("expected a scrape error when containers cannot be listed")
+	}
+}
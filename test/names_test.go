@@ -0,0 +1,77 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/arnab2001/boxy/internal/names"
+)
+
+// Test --name validation and generated names and IDs
+func TestContainerNames(t *testing.T) {
+	for _, name := range []string{"web", "api-1", "db_primary", "v2.0", "9lives"} {
+		if err := names.Validate(name); err != nil {
+			t.Errorf("Validate(%q) = %v; expected valid", name, err)
+		}
+	}
+	for _, name := range []string{"", "-web", ".hidden", "my web", "a/b", "x:y"} {
+		if err := names.Validate(name); err == nil {
+			t.Errorf("Validate(%q) succeeded; expected an error", name)
+		}
+	}
+
+	generated := regexp.MustCompile(`^[a-z]+_[a-z]+$`)
+	if name := names.Generate(0); !generated.MatchString(name) || names.Validate(name) != nil {
+		t.Errorf("Generate(0) = %q; expected adjective_surname", name)
+	}
+	if name := names.Generate(3); !strings.HasSuffix(name, "3") {
+		t.Errorf("Generate(3) = %q; expected a numbered name", name)
+	}
+
+	id := names.NewID()
+	if !regexp.MustCompile(`^[0-9a-f]{64}$`).MatchString(id) || id == names.NewID() {
+		t.Errorf("NewID() = %q; expected 64 random hex digits", id)
+	}
+}
+
+// Test resolving containers by ID, name and ID prefix
+func TestResolveContainer(t *testing.T) {
+	containers := []names.Container{
+		{ID: "4f2a9c1e7b3d", Name: "web"},
+		{ID: "4f2b0d8a1c55", Name: "db"},
+		{ID: "9e1c3b7a2f40", Name: "4f2a"}, // a name that looks like a prefix
+		{ID: "legacy", Name: "legacy"},     // created when the name was the ID
+	}
+	tests := []struct {
+		ref      string
+		expected string // ID; empty when an error is expected
+	}{
+		{"4f2a9c1e7b3d", "4f2a9c1e7b3d"},
+		{"web", "4f2a9c1e7b3d"},
+		{"4f2a", "9e1c3b7a2f40"}, // names win over prefixes
+		{"4f2a9", "4f2a9c1e7b3d"},
+		{"9e1", "9e1c3b7a2f40"},
+		{"legacy", "legacy"},
+		{"4f2", ""}, // ambiguous
+		{"cache", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, err := names.Resolve(tt.ref, containers)
+		if tt.expected == "" {
+			if err == nil {
+				t.Errorf("Resolve(%q) = %s; expected an error", tt.ref, got.ID)
+			}
+			continue
+		}
+		if err != nil || got.ID != tt.expected {
+			t.Errorf("Resolve(%q) = %s, %v; expected %s", tt.ref, got.ID, err, tt.expected)
+		}
+	}
+
+	_, err := names.Resolve("4f2", containers)
+	if err == nil || !strings.Contains(err.Error(), "web") || !strings.Contains(err.Error(), "db") {
+		t.Errorf("ambiguity error %v should list the matching containers", err)
+	}
+}
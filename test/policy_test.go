@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	boxyoci "github.com/arnab2001/boxy/internal/oci"
+	"github.com/arnab2001/boxy/internal/policy"
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/content/local"
+	refdocker "github.com/containerd/containerd/reference/docker"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func writePolicy(t *testing.T, data string) *policy.Policy {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := policy.LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// Test loading policy files
+func TestLoadPolicy(t *testing.T) {
+	p, err := policy.LoadFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err != nil || len(p.Images.Allowed) != 0 {
+		t.Errorf("missing policy = %+v, %v", p, err)
+	}
+
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"unknown.yaml":   "images:\n  allow: [docker.io]\n",
+		"nokeys.yaml":    "images:\n  signatures:\n    - scope: registry.corp\n",
+		"malformed.yaml": "images: [\n",
+	} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(data), 0644)
+		if _, err := policy.LoadFile(path); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// Test registry, block and digest pinning rules
+func TestCheckReference(t *testing.T) {
+	p := writePolicy(t, `
+images:
+  allowed: [docker.io/library, registry.corp]
+  blocked: [docker.io/library/ubuntu]
+  requireDigest: [registry.corp/prod]
+`)
+	tests := []struct {
+		ref  string
+		rule string // "" when allowed
+	}{
+		{"nginx", ""},
+		{"docker.io/library/nginx:1.27", ""},
+		{"ubuntu:22.04", "images.blocked[docker.io/library/ubuntu]"},
+		{"ubuntu-extra", ""}, // scopes match on path boundaries
+		{"grafana/grafana", "images.allowed"},
+		{"registry.corporate/app", "images.allowed"},
+		{"registry.corp/dev/app:latest", ""},
+		{"registry.corp/prod/app:latest", "images.requireDigest[registry.corp/prod]"},
+		{"registry.corp/prod/app@" + testDigest, ""},
+	}
+	for _, tt := range tests {
+		named, err := refdocker.ParseDockerRef(tt.ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = p.CheckReference(named)
+		var denial *policy.Denial
+		switch {
+		case tt.rule == "" && err != nil:
+			t.Errorf("%s: unexpected denial: %v", tt.ref, err)
+		case tt.rule != "" && !errors.As(err, &denial):
+			t.Errorf("%s: expected denial by %s, got %v", tt.ref, tt.rule, err)
+		case tt.rule != "" && denial.Rule != tt.rule:
+			t.Errorf("%s: denied by %s, expected %s", tt.ref, denial.Rule, tt.rule)
+		}
+	}
+}
+
+type testSigner struct {
+	key     *ecdsa.PrivateKey
+	keyPath string
+}
+
+func newTestSigner(t *testing.T) *testSigner {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "cosign.pub")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return &testSigner{key: key, keyPath: path}
+}
+
+// sign returns a cosign simple-signing payload for repo@dgst and its signature
+func (s *testSigner) sign(t *testing.T, repo, dgst string) ([]byte, []byte) {
+	payload := []byte(`{"critical":{"identity":{"docker-reference":"` + repo + `"},` +
+		`"image":{"docker-manifest-digest":"` + dgst + `"},"type":"cosign container image signature"},"optional":null}`)
+	sum := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, s.key, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payload, sig
+}
+
+// Test cosign payload verification
+func TestVerifyPayload(t *testing.T) {
+	s := newTestSigner(t)
+	key, err := policy.LoadKey(s.keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dgst := digest.Digest(testDigest)
+
+	payload, sig := s.sign(t, "index.docker.io/library/nginx", testDigest)
+	if err := policy.VerifyPayload(payload, sig, key, "docker.io/library/nginx", dgst); err != nil {
+		t.Errorf("valid signature rejected: %v", err)
+	}
+	if err := policy.VerifyPayload(payload, sig, key, "docker.io/library/redis", dgst); err == nil {
+		t.Error("signature accepted for another repository")
+	}
+	if err := policy.VerifyPayload(payload, sig, key, "docker.io/library/nginx", digest.FromString("other")); err == nil {
+		t.Error("signature accepted for another digest")
+	}
+	tampered := bytes.Replace(payload, []byte("nginx"), []byte("nginy"), 1)
+	if err := policy.VerifyPayload(tampered, sig, key, "docker.io/library/nginy", dgst); err == nil {
+		t.Error("tampered payload accepted")
+	}
+
+	other := newTestSigner(t)
+	otherKey, _ := policy.LoadKey(other.keyPath)
+	if err := policy.VerifyPayload(payload, sig, otherKey, "docker.io/library/nginx", dgst); err == nil {
+		t.Error("signature accepted with the wrong key")
+	}
+}
+
+// storeSignature writes a cosign signature manifest into a content store
+func storeSignature(t *testing.T, store content.Store, payload, sig []byte) ocispec.Descriptor {
+	ctx := context.Background()
+	layer := ocispec.Descriptor{
+		MediaType:   policy.SimpleSigningMediaType,
+		Digest:      digest.FromBytes(payload),
+		Size:        int64(len(payload)),
+		Annotations: map[string]string{policy.SignatureAnnotation: base64.StdEncoding.EncodeToString(sig)},
+	}
+	if err := content.WriteBlob(ctx, store, "payload", bytes.NewReader(payload), layer); err != nil {
+		t.Fatal(err)
+	}
+	manifest, _ := json.Marshal(ocispec.Manifest{
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: policy.SignatureArtifactType,
+		Layers:       []ocispec.Descriptor{layer},
+	})
+	desc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Digest:    digest.FromBytes(manifest),
+		Size:      int64(len(manifest)),
+	}
+	if err := content.WriteBlob(ctx, store, "manifest", bytes.NewReader(manifest), desc); err != nil {
+		t.Fatal(err)
+	}
+	return desc
+}
+
+// Test signature rules against signatures stored alongside an image
+func TestVerifySignatures(t *testing.T) {
+	ctx := context.Background()
+	store, err := local.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestSigner(t)
+	p := writePolicy(t, "images:\n  signatures:\n    - scope: registry.corp\n      keys: ["+s.keyPath+"]\n")
+
+	named, _ := refdocker.ParseDockerRef("registry.corp/app:1.0")
+	dgst := digest.Digest(testDigest)
+
+	var denial *policy.Denial
+	err = p.VerifySignatures(ctx, store, named, dgst, nil)
+	if !errors.As(err, &denial) || !strings.Contains(denial.Reason, "no cosign signature") {
+		t.Errorf("unsigned image: %v", err)
+	}
+
+	payload, sig := s.sign(t, "registry.corp/app", testDigest)
+	good := storeSignature(t, store, payload, sig)
+	if err := p.VerifySignatures(ctx, store, named, dgst, []ocispec.Descriptor{good}); err != nil {
+		t.Errorf("signed image denied: %v", err)
+	}
+
+	forged := storeSignature(t, store, payload, []byte("not a signature"))
+	if err := p.VerifySignatures(ctx, store, named, dgst, []ocispec.Descriptor{forged}); !errors.As(err, &denial) {
+		t.Errorf("forged signature: %v", err)
+	}
+
+	// images outside the scope need no signature
+	hub, _ := refdocker.ParseDockerRef("nginx")
+	if err := p.VerifySignatures(ctx, store, hub, dgst, nil); err != nil {
+		t.Errorf("unscoped image denied: %v", err)
+	}
+}
+
+// Test run-time rules against container options
+func TestCheckRun(t *testing.T) {
+	p := writePolicy(t, `run:
+  denyPrivileged: true
+  denyHostNetwork: true
+  allowedMounts: [/srv/data, /home]
+  minHostPort: 1024
+  requireLimits: true
+  deniedCapabilities: [sys_admin, net_raw]
+`)
+	rules := func(r policy.RunRequest) []string {
+		var out []string
+		for _, d := range p.CheckRun(r) {
+			out = append(out, d.Rule)
+		}
+		return out
+	}
+	// effective capabilities, as boxy run computes them
+	caps := func(add, drop []string) []string {
+		c, err := boxyoci.EffectiveCaps(boxyoci.SecurityOptions{CapAdd: add, CapDrop: drop})
+		if err != nil {
+			t.Fatalf("EffectiveCaps(%v, %v): %v", add, drop, err)
+		}
+		return c
+	}
+	limited := policy.RunRequest{Name: "web", Memory: 512 << 20, CPUs: 1, Caps: caps(nil, []string{"net_raw"})}
+
+	if got := rules(limited); len(got) != 0 {
+		t.Errorf("compliant container denied: %v", got)
+	}
+	ok := limited
+	ok.Mounts = []string{"/srv/data", "/home/me/src"}
+	ok.HostPorts = []int{8080}
+	ok.CapAdd = []string{"CAP_NET_ADMIN"}
+	ok.Caps = caps([]string{"net_admin"}, []string{"net_raw"})
+	if got := rules(ok); len(got) != 0 {
+		t.Errorf("allowed options denied: %v", got)
+	}
+
+	tests := []struct {
+		name     string
+		req      policy.RunRequest
+		expected []string
+	}{
+		{"privileged", policy.RunRequest{Privileged: true, Memory: 1, CPUs: 1},
+			[]string{"run.denyPrivileged", "run.deniedCapabilities", "run.deniedCapabilities"}},
+		{"host network", policy.RunRequest{HostNetwork: true, Memory: 1, CPUs: 1},
+			[]string{"run.denyHostNetwork"}},
+		{"mount outside prefixes", policy.RunRequest{Mounts: []string{"/srv/database", "/"}, Memory: 1, CPUs: 1},
+			[]string{"run.allowedMounts", "run.allowedMounts"}},
+		{"low host port", policy.RunRequest{HostPorts: []int{80, 8080}, Memory: 1, CPUs: 1},
+			[]string{"run.minHostPort"}},
+		{"no limits", policy.RunRequest{},
+			[]string{"run.requireLimits", "run.requireLimits"}},
+		{"denied capability", policy.RunRequest{CapAdd: []string{"ALL"}, Caps: caps([]string{"ALL"}, []string{"NET_RAW"}), Memory: 1, CPUs: 1},
+			[]string{"run.deniedCapabilities"}},
+		{"denied default capability", policy.RunRequest{Caps: caps(nil, nil), Memory: 1, CPUs: 1},
+			[]string{"run.deniedCapabilities"}},
+		{"denied capabilities dropped", policy.RunRequest{CapAdd: []string{"ALL"}, Caps: caps([]string{"ALL"}, []string{"SYS_ADMIN", "NET_RAW"}), Memory: 1, CPUs: 1},
+			nil},
+		{"all dropped", policy.RunRequest{Caps: caps([]string{"CHOWN"}, []string{"ALL"}), Memory: 1, CPUs: 1},
+			nil},
+	}
+	for _, tt := range tests {
+		if got := rules(tt.req); strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("%s: denied by %v; expected %v", tt.name, got, tt.expected)
+		}
+	}
+
+	// allowedMounts: [] denies every bind mount, an absent key none
+	none := writePolicy(t, "run:\n  allowedMounts: []\n")
+	if len(none.CheckRun(policy.RunRequest{Mounts: []string{"/tmp"}})) != 1 {
+		t.Error("empty allowedMounts should deny all mounts")
+	}
+	if len(writePolicy(t, "").CheckRun(policy.RunRequest{Mounts: []string{"/tmp"}, Privileged: true})) != 0 {
+		t.Error("empty policy should allow everything")
+	}
+}
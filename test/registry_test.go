@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/arnab2001/boxy/internal/config"
+	"github.com/arnab2001/boxy/internal/registry"
+	"github.com/containerd/containerd/remotes/docker"
+)
+
+// Test loading registry configuration from config.json
+func TestLoadRegistryConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	data := `{
+  "registry": {
+    "configPath": "/etc/boxy/certs.d",
+    "hosts": {
+      "docker.io": {
+        "mirrors": [{"host": "mirror.local:5000", "skipVerify": true}]
+      }
+    }
+  }
+}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.LoadFile(path)
+	if err != nil {
+		t.Fatalf("Expected config to load, got: %v", err)
+	}
+	if cfg.Registry.ConfigPath != "/etc/boxy/certs.d" {
+		t.Errorf("Expected configPath /etc/boxy/certs.d, got %s", cfg.Registry.ConfigPath)
+	}
+	mirrors := cfg.Registry.Hosts["docker.io"].Mirrors
+	if len(mirrors) != 1 || mirrors[0].Host != "mirror.local:5000" || !mirrors[0].SkipVerify {
+		t.Errorf("Unexpected mirrors: %+v", mirrors)
+	}
+
+	// Missing file falls back to defaults
+	cfg, err = config.LoadFile(filepath.Join(dir, "missing.json"))
+	if err != nil {
+		t.Fatalf("Expected defaults for missing config, got: %v", err)
+	}
+	if cfg.Registry.ConfigPath == "" {
+		t.Error("Expected default configPath to be set")
+	}
+
+	// Invalid JSON is reported
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.LoadFile(path); err == nil {
+		t.Error("Expected error for invalid config")
+	}
+}
+
+// Test mirror endpoints are tried before the upstream registry
+func TestRegistryHostsMirrors(t *testing.T) {
+	cfg := &config.Config{Registry: config.Registry{
+		ConfigPath: t.TempDir(),
+		Hosts: map[string]config.RegistryHost{
+			"docker.io": {Mirrors: []config.Endpoint{
+				{Host: "mirror.local:5000", SkipVerify: true},
+				{Host: "http://cache.local/v2", Capabilities: []string{"pull"}},
+			}},
+			"private.io": {
+				Mirrors:     []config.Endpoint{{Host: "private-mirror.local"}},
+				MirrorsOnly: true,
+			},
+		},
+	}}
+	hosts := registry.Hosts(context.Background(), cfg)
+
+	t.Run("docker_io", func(t *testing.T) {
+		got, err := hosts("docker.io")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(got) != 3 {
+			t.Fatalf("Expected 3 endpoints, got %d", len(got))
+		}
+		if got[0].Host != "mirror.local:5000" || got[0].Scheme != "https" || got[0].Path != "/v2" {
+			t.Errorf("Unexpected first mirror: %s://%s%s", got[0].Scheme, got[0].Host, got[0].Path)
+		}
+		if got[1].Scheme != "http" || got[1].Capabilities != docker.HostCapabilityPull {
+			t.Errorf("Unexpected second mirror: %+v", got[1])
+		}
+		if got[2].Host != "registry-1.docker.io" {
+			t.Errorf("Expected upstream last, got %s", got[2].Host)
+		}
+	})
+
+	t.Run("mirrors_only", func(t *testing.T) {
+		got, err := hosts("private.io")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(got) != 1 || got[0].Host != "private-mirror.local" {
+			t.Errorf("Expected only the mirror, got %+v", got)
+		}
+	})
+
+	t.Run("unconfigured_host", func(t *testing.T) {
+		got, err := hosts("ghcr.io")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(got) != 1 || got[0].Host != "ghcr.io" {
+			t.Errorf("Expected default endpoint for ghcr.io, got %+v", got)
+		}
+	})
+
+	t.Run("bad_capability", func(t *testing.T) {
+		bad := &config.Config{Registry: config.Registry{Hosts: map[string]config.RegistryHost{
+			"docker.io": {Mirrors: []config.Endpoint{{Host: "m", Capabilities: []string{"fly"}}}},
+		}}}
+		if _, err := registry.Hosts(context.Background(), bad)("docker.io"); err == nil {
+			t.Error("Expected error for unknown capability")
+		}
+	})
+}
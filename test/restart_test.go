@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arnab2001/boxy/internal/restart"
+)
+
+// Test --restart parsing
+func TestParseRestartPolicy(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected string
+		wantErr  bool
+	}{
+		{"", "no", false},
+		{"no", "no", false},
+		{"always", "always", false},
+		{"unless-stopped", "unless-stopped", false},
+		{"on-failure", "on-failure", false},
+		{"on-failure:5", "on-failure:5", false},
+		{"on-failure:-1", "", true},
+		{"on-failure:x", "", true},
+		{"always:3", "", true},
+		{"sometimes", "", true},
+	}
+	for _, tt := range tests {
+		p, err := restart.Parse(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) error = %v; expected error: %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && p.String() != tt.expected {
+			t.Errorf("Parse(%q) = %s; expected %s", tt.in, p, tt.expected)
+		}
+	}
+}
+
+// Test restart decisions and backoff
+func TestRestartDecisions(t *testing.T) {
+	always, _ := restart.Parse("always")
+	onFailure, _ := restart.Parse("on-failure:2")
+	no, _ := restart.Parse("no")
+
+	if !always.ShouldRestart(0, 100, false) || always.ShouldRestart(1, 0, true) {
+		t.Error("always should restart unless stopped")
+	}
+	if onFailure.ShouldRestart(0, 0, false) || !onFailure.ShouldRestart(1, 1, false) || onFailure.ShouldRestart(1, 2, false) {
+		t.Error("on-failure:2 should restart failures twice")
+	}
+	if no.Enabled() || no.ShouldRestart(1, 0, false) {
+		t.Error("no should never restart")
+	}
+
+	var delays []time.Duration
+	var d time.Duration
+	for i := 0; i < 12; i++ {
+		d = restart.Backoff(d, time.Second)
+		delays = append(delays, d)
+	}
+	if delays[0] != 100*time.Millisecond || delays[1] != 200*time.Millisecond || delays[11] != time.Minute {
+		t.Errorf("backoff delays %v", delays)
+	}
+	if got := restart.Backoff(time.Minute, time.Hour); got != 100*time.Millisecond {
+		t.Errorf("backoff after a long run = %s; expected a reset", got)
+	}
+}
+
+// Test which containers boxy system restore starts and the systemd unit
+func TestRestoreOnBoot(t *testing.T) {
+	tests := []struct {
+		policy   string
+		stopped  bool
+		expected bool
+	}{
+		{"always", false, true},
+		{"always", true, true},
+		{"unless-stopped", false, true},
+		{"unless-stopped", true, false},
+		{"on-failure", false, false},
+		{"no", false, false},
+	}
+	for _, tt := range tests {
+		p, _ := restart.Parse(tt.policy)
+		if got := p.RestoreOnBoot(tt.stopped); got != tt.expected {
+			t.Errorf("%s.RestoreOnBoot(stopped=%v) = %v; expected %v", tt.policy, tt.stopped, got, tt.expected)
+		}
+	}
+
+	unit := restart.SystemdUnit("/usr/local/bin/boxy", "/run/k3s/containerd/containerd.sock")
+	for _, want := range []string{
+		"ExecStart=/usr/local/bin/boxy system restore\n",
+		"Environment=CONTAINERD_SOCK=/run/k3s/containerd/containerd.sock\n",
+		"After=containerd.service",
+		"WantedBy=multi-user.target",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("unit lacks %q:\n%s", want, unit)
+		}
+	}
+	if strings.Contains(restart.SystemdUnit("/usr/local/bin/boxy", ""), "Environment=") {
+		t.Error("unit sets CONTAINERD_SOCK without a socket")
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/arnab2001/boxy/internal/health"
+	boxylabels "github.com/arnab2001/boxy/internal/labels"
+	"github.com/arnab2001/boxy/internal/ports"
+	"github.com/arnab2001/boxy/internal/restart"
+	"github.com/arnab2001/boxy/internal/runopts"
+	"github.com/arnab2001/boxy/internal/signals"
+	"github.com/spf13/pflag"
+)
+
+// runFlags parses args against the run/create flag set
+func runFlags(t *testing.T, args ...string) *pflag.FlagSet {
+	t.Helper()
+	flags := pflag.NewFlagSet("run", pflag.ContinueOnError)
+	runopts.AddFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		t.Fatalf("Parse(%v): %v", args, err)
+	}
+	return flags
+}
+
+// Test that published ports survive Format and Parse
+func TestPortsLabelRoundTrip(t *testing.T) {
+	mappings, err := ports.Parse([]string{"8080:80", "5353:53/udp", "8443:443/TCP"})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	label := ports.Format(mappings)
+	if label != "8080:80/tcp,5353:53/udp,8443:443/tcp" {
+		t.Errorf("Format = %q", label)
+	}
+	back, err := ports.Parse(strings.Split(label, ","))
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", label, err)
+	}
+	if !reflect.DeepEqual(back, mappings) {
+		t.Errorf("round trip = %+v; expected %+v", back, mappings)
+	}
+}
+
+// Test that the run options boxy start, stop and the monitor read back
+// survive being stored as labels
+func TestRunOptionsLabelRoundTrip(t *testing.T) {
+	opts, err := runopts.Parse(runFlags(t,
+		"-d", "--name", "web",
+		"-p", "8080:80", "-p", "5353:53/udp",
+		"--health-cmd", "curl -f http://localhost/", "--health-interval", "10s", "--health-retries", "5",
+		"--restart", "on-failure:3",
+		"--stop-signal", "quit", "--stop-timeout", "45s",
+	))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	labels, err := opts.Labels(opts.Healthcheck(nil), opts.StopSignal)
+	if err != nil {
+		t.Fatalf("Labels: %v", err)
+	}
+
+	if labels[boxylabels.Name] != "web" {
+		t.Errorf("name label = %q", labels[boxylabels.Name])
+	}
+
+	published, err := ports.Parse(strings.Split(labels[boxylabels.Ports], ","))
+	if err != nil {
+		t.Fatalf("ports label %q: %v", labels[boxylabels.Ports], err)
+	}
+	if !reflect.DeepEqual(published, opts.Ports) {
+		t.Errorf("ports = %+v; expected %+v", published, opts.Ports)
+	}
+
+	var cfg health.Config
+	if err := json.Unmarshal([]byte(labels[boxylabels.Healthcheck]), &cfg); err != nil {
+		t.Fatalf("healthcheck label %q: %v", labels[boxylabels.Healthcheck], err)
+	}
+	expected := health.Config{
+		Test:     []string{"CMD-SHELL", "curl -f http://localhost/"},
+		Interval: 10 * time.Second,
+		Retries:  5,
+	}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("healthcheck = %+v; expected %+v", cfg, expected)
+	}
+
+	policy, err := restart.Parse(labels[boxylabels.RestartPolicy])
+	if err != nil {
+		t.Fatalf("restart label %q: %v", labels[boxylabels.RestartPolicy], err)
+	}
+	if policy != opts.Restart {
+		t.Errorf("restart = %s; expected %s", policy, opts.Restart)
+	}
+
+	sig, timeout := signals.Stop(labels)
+	if sig != syscall.SIGQUIT || timeout != 45*time.Second {
+		t.Errorf("Stop = %v, %v; expected SIGQUIT, 45s", sig, timeout)
+	}
+}
+
+// Test that --stop-timeout takes docker's whole seconds
+func TestStopTimeoutSeconds(t *testing.T) {
+	opts, err := runopts.Parse(runFlags(t, "-d", "--stop-timeout", "5"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if opts.StopTimeout == nil || *opts.StopTimeout != 5*time.Second {
+		t.Errorf("StopTimeout = %v; expected 5s", opts.StopTimeout)
+	}
+	if _, err := runopts.Parse(runFlags(t, "--stop-timeout", "-5")); err == nil {
+		t.Error("negative --stop-timeout accepted")
+	}
+}
+
+// Test that options left at their defaults add no labels
+func TestRunOptionsDefaultLabels(t *testing.T) {
+	opts, err := runopts.Parse(runFlags(t, "-d"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	labels, err := opts.Labels(opts.Healthcheck(nil), opts.StopSignal)
+	if err != nil {
+		t.Fatalf("Labels: %v", err)
+	}
+	for _, key := range []string{boxylabels.Ports, boxylabels.Healthcheck, boxylabels.RestartPolicy,
+		boxylabels.AutoRemove, boxylabels.StopSignal, boxylabels.StopTimeout} {
+		if v, ok := labels[key]; ok {
+			t.Errorf("label %s = %q; expected none", key, v)
+		}
+	}
+	sig, timeout := signals.Stop(labels)
+	if sig != signals.DefaultStop || timeout != signals.DefaultTimeout {
+		t.Errorf("Stop = %v, %v; expected the defaults", sig, timeout)
+	}
+}
+
+// Test that create is always detached and --rm only becomes the AutoRemove
+// label of detached containers
+func TestAutoRemoveLabel(t *testing.T) {
+	tests := []struct {
+		name     string
+		create   bool
+		args     []string
+		expected bool
+	}{
+		{"run attached", false, []string{"--rm"}, false},
+		{"run detached", false, []string{"-d", "--rm"}, true},
+		{"run detached without --rm", false, []string{"-d"}, false},
+		{"create", true, []string{"--rm"}, true},
+		{"create -it", true, []string{"-it", "--rm"}, true},
+		{"create without --rm", true, nil, false},
+	}
+	for _, tt := range tests {
+		parse := runopts.Parse
+		if tt.create {
+			parse = runopts.ParseCreate
+		}
+		opts, err := parse(runFlags(t, tt.args...))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if tt.create && !opts.Detach {
+			t.Errorf("%s: not detached", tt.name)
+		}
+		labels, err := opts.Labels(nil, "")
+		if err != nil {
+			t.Errorf("%s: Labels: %v", tt.name, err)
+			continue
+		}
+		if got := labels[boxylabels.AutoRemove] == "true"; got != tt.expected {
+			t.Errorf("%s: AutoRemove label = %v; expected %v", tt.name, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/arnab2001/boxy/internal/seccomp"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// Test the built-in default profile parses and resolves for this host
+func TestDefaultSeccompProfile(t *testing.T) {
+	if errs := seccomp.Validate(seccomp.DefaultJSON()); len(errs) > 0 {
+		t.Fatalf("Expected built-in profile to be valid, got: %v", errs)
+	}
+
+	p := seccomp.Default()
+	sc, err := p.Build([]string{"CAP_CHOWN"})
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if sc.DefaultAction != specs.ActErrno {
+		t.Errorf("Expected default action SCMP_ACT_ERRNO, got %s", sc.DefaultAction)
+	}
+	if len(sc.Syscalls) == 0 {
+		t.Fatal("Expected syscall rules")
+	}
+
+	// CAP_SYS_ADMIN gated rules only apply when the capability is present
+	withoutAdmin := allowed(sc, "mount")
+	sc, err = p.Build([]string{"CAP_SYS_ADMIN"})
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if withoutAdmin || !allowed(sc, "mount") {
+		t.Errorf("Expected mount to be allowed only with CAP_SYS_ADMIN (without=%v)", withoutAdmin)
+	}
+}
+
+// Test profile validation errors
+func TestValidateSeccompProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		errors  int
+		contain string
+	}{
+		{
+			name:    "valid",
+			profile: `{"defaultAction": "SCMP_ACT_ALLOW", "syscalls": [{"names": ["ptrace"], "action": "SCMP_ACT_ERRNO"}]}`,
+		},
+		{
+			name:    "syntax_error",
+			profile: "{\n  \"defaultAction\": \"SCMP_ACT_ALLOW\",\n}",
+			errors:  1,
+			contain: "line 3",
+		},
+		{
+			name:    "unknown_field",
+			profile: `{"defaultAction": "SCMP_ACT_ALLOW", "syscals": []}`,
+			errors:  1,
+			contain: "syscals",
+		},
+		{
+			name: "multiple_errors",
+			profile: `{"defaultAction": "SCMP_ACT_NOPE", "syscalls": [
+				{"name": "read", "names": ["write"], "action": "SCMP_ACT_ALLOW"},
+				{"names": ["kill"], "action": "SCMP_ACT_ALLOW", "args": [{"index": 9, "value": 1, "op": "SCMP_CMP_XX"}]},
+				{"names": ["bpf"], "action": "SCMP_ACT_ALLOW", "includes": {"minKernel": "five"}}
+			]}`,
+			errors: 5,
+		},
+		{
+			name:    "missing_default_action",
+			profile: `{"syscalls": []}`,
+			errors:  1,
+			contain: "defaultAction",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := seccomp.Validate([]byte(tt.profile))
+			if len(errs) != tt.errors {
+				t.Fatalf("Expected %d errors, got %d: %v", tt.errors, len(errs), errs)
+			}
+			if tt.contain != "" && !strings.Contains(errs[0].Error(), tt.contain) {
+				t.Errorf("Expected error to mention %q, got: %v", tt.contain, errs[0])
+			}
+		})
+	}
+}
+
+func allowed(sc *specs.LinuxSeccomp, name string) bool {
+	for _, rule := range sc.Syscalls {
+		if rule.Action != specs.ActAllow || len(rule.Args) > 0 {
+			continue
+		}
+		for _, n := range rule.Names {
+			if n == name {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	boxyoci "github.com/arnab2001/boxy/internal/oci"
+	"github.com/containerd/containerd/containers"
+	"github.com/containerd/containerd/namespaces"
+	"github.com/containerd/containerd/oci"
+)
+
+// Test capability name normalisation
+func TestNormalizeCap(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected string
+		wantErr  bool
+	}{
+		{"net_admin", "CAP_NET_ADMIN", false},
+		{"CAP_SYS_TIME", "CAP_SYS_TIME", false},
+		{"all", "ALL", false},
+		{"FLY", "", true},
+	}
+	for _, tt := range tests {
+		got, err := boxyoci.NormalizeCap(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.expected {
+			t.Errorf("NormalizeCap(%q) = %q, %v; expected %q (error: %v)", tt.in, got, err, tt.expected, tt.wantErr)
+		}
+	}
+}
+
+// Test --device parsing
+func TestParseDevice(t *testing.T) {
+	tests := []struct {
+		in                     string
+		host, container, perms string
+		wantErr                bool
+	}{
+		{"/dev/fuse", "/dev/fuse", "/dev/fuse", "rwm", false},
+		{"/dev/sda:/dev/xvda", "/dev/sda", "/dev/xvda", "rwm", false},
+		{"/dev/sda:r", "/dev/sda", "/dev/sda", "r", false},
+		{"/dev/sda:/dev/xvda:rw", "/dev/sda", "/dev/xvda", "rw", false},
+		{"/dev/sda:/dev/xvda:rwx", "", "", "", true},
+		{"/dev/sda:xvda", "", "", "", true},
+		{"", "", "", "", true},
+	}
+	for _, tt := range tests {
+		host, container, perms, err := boxyoci.ParseDevice(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseDevice(%q) error = %v, expected error: %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if host != tt.host || container != tt.container || perms != tt.perms {
+			t.Errorf("ParseDevice(%q) = %q %q %q", tt.in, host, container, perms)
+		}
+	}
+}
+
+// Test --security-opt parsing
+func TestParseSecurityOpt(t *testing.T) {
+	var o boxyoci.SecurityOptions
+	if err := boxyoci.ParseSecurityOpt(&o, "no-new-privileges"); err != nil || !o.NoNewPrivileges {
+		t.Errorf("Expected no-new-privileges to be enabled (%v)", err)
+	}
+	if err := boxyoci.ParseSecurityOpt(&o, "no-new-privileges:false"); err != nil || o.NoNewPrivileges {
+		t.Errorf("Expected no-new-privileges:false to disable it (%v)", err)
+	}
+	if err := boxyoci.ParseSecurityOpt(&o, "no-new-privileges=maybe"); err == nil {
+		t.Error("Expected error for invalid boolean")
+	}
+	if err := boxyoci.ParseSecurityOpt(&o, "seccomp=/etc/boxy/strict.json"); err != nil || o.Seccomp != "/etc/boxy/strict.json" {
+		t.Errorf("Expected seccomp profile path to be recorded (%v)", err)
+	}
+	if err := boxyoci.ParseSecurityOpt(&o, "seccomp"); err == nil {
+		t.Error("Expected error for seccomp without a value")
+	}
+	if err := boxyoci.ParseSecurityOpt(&o, "label=disable"); err == nil {
+		t.Error("Expected error for unsupported option")
+	}
+}
+
+// Test that security flags end up in the generated OCI spec
+func TestWithSecurity(t *testing.T) {
+	opts, err := boxyoci.WithSecurity(boxyoci.SecurityOptions{
+		CapAdd:          []string{"NET_ADMIN"},
+		CapDrop:         []string{"chown"},
+		NoNewPrivileges: true,
+		ReadOnly:        true,
+	})
+	if err != nil {
+		t.Fatalf("WithSecurity failed: %v", err)
+	}
+
+	spec := generateSpec(t, opts...)
+	caps := spec.Process.Capabilities
+	if !contains(caps.Effective, "CAP_NET_ADMIN") || !contains(caps.Bounding, "CAP_NET_ADMIN") {
+		t.Errorf("Expected CAP_NET_ADMIN to be added, got %v", caps.Effective)
+	}
+	if contains(caps.Effective, "CAP_CHOWN") || contains(caps.Bounding, "CAP_CHOWN") {
+		t.Errorf("Expected CAP_CHOWN to be dropped, got %v", caps.Effective)
+	}
+	if !spec.Process.NoNewPrivileges {
+		t.Error("Expected NoNewPrivileges to be set")
+	}
+	if !spec.Root.Readonly {
+		t.Error("Expected read-only rootfs")
+	}
+
+	t.Run("drop_all", func(t *testing.T) {
+		opts, err := boxyoci.WithSecurity(boxyoci.SecurityOptions{
+			CapDrop: []string{"ALL"},
+			CapAdd:  []string{"NET_BIND_SERVICE"},
+		})
+		if err != nil {
+			t.Fatalf("WithSecurity failed: %v", err)
+		}
+		caps := generateSpec(t, opts...).Process.Capabilities
+		if len(caps.Effective) != 1 || caps.Effective[0] != "CAP_NET_BIND_SERVICE" {
+			t.Errorf("Expected only CAP_NET_BIND_SERVICE, got %v", caps.Effective)
+		}
+	})
+
+	t.Run("privileged_ignores_cap_drop", func(t *testing.T) {
+		opts, err := boxyoci.WithSecurity(boxyoci.SecurityOptions{
+			Privileged: true,
+			CapDrop:    []string{"NET_RAW", "ALL"},
+		})
+		if err != nil {
+			t.Fatalf("WithSecurity failed: %v", err)
+		}
+		caps := generateSpec(t, opts...).Process.Capabilities
+		for _, c := range []string{"CAP_NET_RAW", "CAP_SYS_ADMIN", "CAP_CHOWN"} {
+			if !contains(caps.Effective, c) || !contains(caps.Bounding, c) {
+				t.Errorf("Expected %s to be kept under --privileged, got %v", c, caps.Effective)
+			}
+		}
+	})
+
+	t.Run("seccomp", func(t *testing.T) {
+		if spec.Linux.Seccomp == nil {
+			t.Error("Expected the default seccomp profile to be applied")
+		}
+		opts, err := boxyoci.WithSecurity(boxyoci.SecurityOptions{Seccomp: "unconfined"})
+		if err != nil {
+			t.Fatalf("WithSecurity failed: %v", err)
+		}
+		if generateSpec(t, opts...).Linux.Seccomp != nil {
+			t.Error("Expected seccomp=unconfined to remove the filter")
+		}
+		if _, err := boxyoci.WithSecurity(boxyoci.SecurityOptions{Seccomp: "/does/not/exist.json"}); err == nil {
+			t.Error("Expected error for missing profile file")
+		}
+	})
+
+	t.Run("bad_capability", func(t *testing.T) {
+		if _, err := boxyoci.WithSecurity(boxyoci.SecurityOptions{CapAdd: []string{"NOPE"}}); err == nil {
+			t.Error("Expected error for unknown capability")
+		}
+	})
+}
+
+func generateSpec(t *testing.T, opts ...oci.SpecOpts) *oci.Spec {
+	t.Helper()
+	ctx := namespaces.WithNamespace(context.Background(), "boxy")
+	spec, err := oci.GenerateSpec(ctx, nil, &containers.Container{ID: "test"}, opts...)
+	if err != nil {
+		t.Fatalf("GenerateSpec failed: %v", err)
+	}
+	return spec
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Test --memory sizes and -v bind mounts
+func TestParseResources(t *testing.T) {
+	sizes := map[string]int64{"512m": 512 << 20, "2g": 2 << 30, "64KiB": 64 << 10, "1048576": 1 << 20, "1.5G": 3 << 29}
+	for in, expected := range sizes {
+		if got, err := boxyoci.ParseMemory(in); err != nil || got != expected {
+			t.Errorf("ParseMemory(%q) = %d, %v; expected %d", in, got, err, expected)
+		}
+	}
+	for _, in := range []string{"", "m", "-1g", "lots"} {
+		if _, err := boxyoci.ParseMemory(in); err == nil {
+			t.Errorf("ParseMemory(%q): expected an error", in)
+		}
+	}
+
+	dir := t.TempDir()
+	m, err := boxyoci.ParseVolume(dir + ":/data:ro")
+	if err != nil || m.Destination != "/data" || m.Options[1] != "ro" {
+		t.Errorf("ParseVolume = %+v, %v", m, err)
+	}
+	for _, in := range []string{dir, dir + ":data", dir + ":/data:rx", dir + "/missing:/data"} {
+		if _, err := boxyoci.ParseVolume(in); err == nil {
+			t.Errorf("ParseVolume(%q): expected an error", in)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/arnab2001/boxy/internal/selector"
+)
+
+// Test --filter parsing and matching for stop, rm and prune
+func TestContainerFilters(t *testing.T) {
+	web := selector.Container{ID: "4f2a9c", Name: "web", Image: "docker.io/library/nginx:1.25",
+		Status: selector.Running, Labels: map[string]string{"tier": "web", "team": "core"}}
+	db := selector.Container{ID: "9b1e07", Name: "db", Image: "docker.io/library/postgres:16",
+		Status: selector.Exited, Labels: map[string]string{"tier": "db"}}
+
+	tests := []struct {
+		filters []string
+		web, db bool
+	}{
+		{nil, true, true},
+		{[]string{"label=tier"}, true, true},
+		{[]string{"label=tier=web"}, true, false},
+		{[]string{"label=team"}, true, false},
+		{[]string{"status=exited"}, false, true},
+		{[]string{"status=exited,status=running"}, true, true},
+		{[]string{"status=running", "label=tier=db"}, false, false},
+		{[]string{"ancestor=nginx"}, true, false},
+		{[]string{"ancestor=nginx:1.25"}, true, false},
+		{[]string{"ancestor=nginx:1.24"}, false, false},
+		{[]string{"ancestor=docker.io/library/postgres"}, false, true},
+	}
+	for _, tt := range tests {
+		f, err := selector.ParseFilters(tt.filters)
+		if err != nil {
+			t.Errorf("ParseFilters(%q): %v", tt.filters, err)
+			continue
+		}
+		if f.Match(web) != tt.web || f.Match(db) != tt.db {
+			t.Errorf("%q: web %v db %v; expected %v %v", tt.filters, f.Match(web), f.Match(db), tt.web, tt.db)
+		}
+	}
+
+	for _, bad := range []string{"label", "label=", "status=dead", "ancestor=UPPER", "name=web"} {
+		if _, err := selector.ParseFilters([]string{bad}); err == nil {
+			t.Errorf("ParseFilters(%q): expected an error", bad)
+		}
+	}
+}
+
+// Test the bounded parallelism and error aggregation of multi-container commands
+func TestEach(t *testing.T) {
+	items := []string{"a", "b", "c", "d", "e", "f"}
+	var (
+		mu            sync.Mutex
+		running, peak int
+		calls         atomic.Int32
+	)
+	err := selector.Each(items, 2, func(string) error {
+		calls.Add(1)
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != int32(len(items)) {
+		t.Errorf("%d calls; expected %d", calls.Load(), len(items))
+	}
+	if peak > 2 {
+		t.Errorf("%d calls ran at once; expected at most 2", peak)
+	}
+
+	boom := errors.New("boom")
+	err = selector.Each(items, 3, func(item string) error {
+		if item == "b" || item == "e" {
+			return boom
+		}
+		return nil
+	})
+	if err == nil || !errors.Is(err, boom) {
+		t.Fatalf("expected joined errors, got %v", err)
+	}
+	if msg := err.Error(); !strings.HasPrefix(msg, "2 of 6 failed:") ||
+		!strings.Contains(msg, "b: boom") || !strings.Contains(msg, "e: boom") {
+		t.Errorf("unexpected message %q", msg)
+	}
+
+	// a single failure is reported as is
+	err = selector.Each([]string{"web"}, 8, func(string) error { return boom })
+	if err == nil || err.Error() != "web: boom" {
+		t.Errorf("got %v; expected web: boom", err)
+	}
+}
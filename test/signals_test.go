@@ -0,0 +1,91 @@
+package main
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	boxylabels "github.com/arnab2001/boxy/internal/labels"
+	"github.com/arnab2001/boxy/internal/signals"
+)
+
+// Test parsing and naming signals for kill, --stop-signal and STOPSIGNAL
+func TestParseSignal(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected syscall.Signal
+	}{
+		{"SIGQUIT", syscall.SIGQUIT},
+		{"quit", syscall.SIGQUIT},
+		{"KILL", syscall.SIGKILL},
+		{"Sigterm", syscall.SIGTERM},
+		{"1", syscall.SIGHUP},
+		{"RTMIN+3", syscall.Signal(37)},
+	}
+	for _, tt := range tests {
+		sig, err := signals.Parse(tt.in)
+		if err != nil || sig != tt.expected {
+			t.Errorf("Parse(%q) = %v, %v; expected %v", tt.in, sig, err, tt.expected)
+		}
+	}
+	for _, bad := range []string{"", "0", "SIGBOGUS", "99"} {
+		if _, err := signals.Parse(bad); err == nil {
+			t.Errorf("Parse(%q): expected an error", bad)
+		}
+	}
+
+	if name := signals.Name(syscall.SIGQUIT); name != "SIGQUIT" {
+		t.Errorf("Name(SIGQUIT) = %q", name)
+	}
+}
+
+// Test the stop signal and timeout boxy stop takes from container labels
+func TestStopSettings(t *testing.T) {
+	sig, timeout := signals.Stop(nil)
+	if sig != syscall.SIGTERM || timeout != 10*time.Second {
+		t.Errorf("defaults: %v %v; expected SIGTERM 10s", sig, timeout)
+	}
+
+	sig, timeout = signals.Stop(map[string]string{
+		boxylabels.StopSignal:  "SIGQUIT",
+		boxylabels.StopTimeout: "30s",
+	})
+	if sig != syscall.SIGQUIT || timeout != 30*time.Second {
+		t.Errorf("got %v %v; expected SIGQUIT 30s", sig, timeout)
+	}
+
+	// a zero timeout kills right away
+	if _, timeout = signals.Stop(map[string]string{boxylabels.StopTimeout: "0s"}); timeout != 0 {
+		t.Errorf("timeout %v; expected 0", timeout)
+	}
+
+	sig, timeout = signals.Stop(map[string]string{
+		boxylabels.StopSignal:  "BOGUS",
+		boxylabels.StopTimeout: "-5s",
+	})
+	if sig != syscall.SIGTERM || timeout != 10*time.Second {
+		t.Errorf("invalid labels: %v %v; expected the defaults", sig, timeout)
+	}
+}
+
+// Test that stop timeouts are read as whole seconds, like docker's -t, or
+// as durations
+func TestParseTimeout(t *testing.T) {
+	valid := map[string]time.Duration{
+		"5":      5 * time.Second,
+		"0":      0,
+		"30s":    30 * time.Second,
+		"1m":     time.Minute,
+		"1500ms": 1500 * time.Millisecond,
+	}
+	for s, expected := range valid {
+		if got, err := signals.ParseTimeout(s); err != nil || got != expected {
+			t.Errorf("ParseTimeout(%q) = %v, %v; expected %v", s, got, err, expected)
+		}
+	}
+	for _, s := range []string{"", "-5", "-1s", "soon", "1.5"} {
+		if got, err := signals.ParseTimeout(s); err == nil {
+			t.Errorf("ParseTimeout(%q) = %v; expected an error", s, got)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arnab2001/boxy/internal/stats"
+	v1 "github.com/containerd/cgroups/v3/cgroup1/stats"
+	v2 "github.com/containerd/cgroups/v3/cgroup2/stats"
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/api/types"
+	"github.com/containerd/containerd/protobuf"
+	"github.com/containerd/typeurl/v2"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func testMetric(t *testing.T, data interface{}) *types.Metric {
+	a, err := typeurl.MarshalAny(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &types.Metric{
+		Timestamp: timestamppb.Now(),
+		Data:      protobuf.FromAny(a),
+	}
+}
+
+// Test decoding cgroup v1 and v2 task metrics
+func TestDecodeMetrics(t *testing.T) {
+	s, err := stats.Decode(testMetric(t, &v1.Metrics{
+		CPU:    &v1.CPUStat{Usage: &v1.CPUUsage{Total: 5e9}},
+		Memory: &v1.MemoryStat{TotalInactiveFile: 10 << 20, Usage: &v1.MemoryEntry{Usage: 60 << 20, Limit: 1<<63 - 4096}},
+		Pids:   &v1.PidsStat{Current: 3},
+		Blkio: &v1.BlkIOStat{IoServiceBytesRecursive: []*v1.BlkIOEntry{
+			{Op: "Read", Value: 100}, {Op: "Write", Value: 20}, {Op: "Total", Value: 120}, {Op: "Read", Value: 1},
+		}},
+	}))
+	expected := stats.Sample{CPU: 5e9, Memory: 50 << 20, Pids: 3, BlkRead: 101, BlkWrite: 20}
+	s.Time = time.Time{}
+	if err != nil || s != expected {
+		t.Errorf("v1: got %+v, %v; expected %+v", s, err, expected)
+	}
+
+	s, err = stats.Decode(testMetric(t, &v2.Metrics{
+		CPU:    &v2.CPUStat{UsageUsec: 2500},
+		Memory: &v2.MemoryStat{Usage: 8 << 20, InactiveFile: 1 << 20, UsageLimit: 256 << 20},
+		Pids:   &v2.PidsStat{Current: 7},
+		Io:     &v2.IOStat{Usage: []*v2.IOEntry{{Rbytes: 4096, Wbytes: 512}, {Rbytes: 1}}},
+	}))
+	expected = stats.Sample{CPU: 2500000, Memory: 7 << 20, Limit: 256 << 20, Pids: 7, BlkRead: 4097, BlkWrite: 512}
+	s.Time = time.Time{}
+	if err != nil || s != expected {
+		t.Errorf("v2: got %+v, %v; expected %+v", s, err, expected)
+	}
+
+	if _, err := stats.Decode(testMetric(t, &v2.PidsStat{})); err == nil {
+		t.Error("expected an error for an unknown metrics type")
+	}
+}
+
+// Test summing interface counters from /proc/<pid>/net/dev
+func TestParseNetDev(t *testing.T) {
+	const netDev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:    1000      10    0    0    0     0          0         0     1000      10    0    0    0     0       0          0
+  eth0:    5120      40    0    0    0     0          0         0     2048      20    0    0    0     0       0          0
+  eth1:     100       1    0    0    0     0          0         0       10       1    0    0    0     0       0          0
+`
+	rx, tx, err := stats.ParseNetDev(strings.NewReader(netDev))
+	if err != nil || rx != 5220 || tx != 2058 {
+		t.Errorf("ParseNetDev = %d, %d, %v; expected 5220, 2058", rx, tx, err)
+	}
+	if _, _, err := stats.ParseNetDev(strings.NewReader("eth0: 1 2 3\n")); err == nil {
+		t.Error("expected an error for a truncated line")
+	}
+}
+
+// Test CPU percentages and byte formatting
+func TestStatsFormatting(t *testing.T) {
+	now := time.Now()
+	prev := stats.Sample{Time: now, CPU: 1e9}
+	cur := stats.Sample{Time: now.Add(time.Second), CPU: 2.5e9}
+	if got := stats.CPUPercent(prev, cur); got != 150 {
+		t.Errorf("CPUPercent = %.2f; expected 150", got)
+	}
+	if got := stats.CPUPercent(cur, prev); got != 0 {
+		t.Errorf("CPUPercent backwards = %.2f; expected 0", got)
+	}
+
+	for n, expected := range map[uint64]string{0: "0B", 1023: "1023B", 1536: "1.5KiB", 50 << 20: "50.0MiB", 3 << 30: "3.0GiB"} {
+		if got := stats.Bytes(n); got != expected {
+			t.Errorf("Bytes(%d) = %q; expected %q", n, got, expected)
+		}
+	}
+}
+
+// Test which containers boxy stats samples when some have exited
+func TestSampledContainers(t *testing.T) {
+	targets := []stats.Target{
+		{ID: "1", Name: "web", Status: containerd.Running},
+		{ID: "2", Name: "job", Status: containerd.Stopped},
+		{ID: "3", Name: "db", Status: containerd.Paused},
+		{ID: "4", Name: "new", Status: containerd.Created},
+		{ID: "5", Name: "gone"}, // no task
+	}
+	got, err := stats.Sampled(targets, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Name != "web" || got[1].Name != "db" {
+		t.Errorf("got %+v; expected web and db", got)
+	}
+
+	// containers named on the command line must be running
+	if _, err := stats.Sampled(targets[:1], true); err != nil {
+		t.Errorf("running web: %v", err)
+	}
+	_, err = stats.Sampled(targets[:2], true)
+	if err == nil || err.Error() != "container job is not running" {
+		t.Errorf("got %v; expected job is not running", err)
+	}
+}
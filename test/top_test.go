@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arnab2001/boxy/internal/top"
+)
+
+func writeProc(t *testing.T, root, name, data string) {
+	path := filepath.Join(root, name)
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Test reading process details from a procfs tree
+func TestReadProcess(t *testing.T) {
+	root := t.TempDir()
+	writeProc(t, root, "uptime", "1000.00 3000.00\n")
+	writeProc(t, root, "meminfo", "MemTotal:       1048576 kB\nMemFree:          524288 kB\n")
+	// comm with spaces and parentheses, utime+stime = 5000 ticks, started at
+	// 500s, 256 pages resident
+	writeProc(t, root, "42/stat", "42 (my (odd) app) S 1 42 42 0 -1 4194560 100 0 0 0 3000 2000 0 0 20 0 1 0 50000 10485760 256 18446744073709551615\n")
+	writeProc(t, root, "42/status", "Name:\tapp\nUid:\t0\t0\t0\t0\nGid:\t0\t0\t0\t0\n")
+	writeProc(t, root, "42/cmdline", "nginx\x00-g\x00daemon off;\x00")
+
+	p, err := top.Read(root, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rss := uint64(256 * os.Getpagesize())
+	if p.PPID != 1 || p.State != "S" || p.RSS != rss || p.UID != 0 || p.User != "root" {
+		t.Errorf("got %+v", p)
+	}
+	if p.Time != 50*time.Second || p.CPU != 10 {
+		t.Errorf("cpu time %v, %.2f%%; expected 50s, 10%%", p.Time, p.CPU)
+	}
+	if p.Command != "nginx -g daemon off;" {
+		t.Errorf("command %q", p.Command)
+	}
+
+	// kernel threads have an empty cmdline
+	writeProc(t, root, "42/cmdline", "")
+	if p, _ := top.Read(root, 42); p.Command != "[my (odd) app]" {
+		t.Errorf("command without cmdline %q", p.Command)
+	}
+	if _, err := top.Read(root, 7); err == nil {
+		t.Error("expected an error for a missing process")
+	}
+}
+
+// Test keeping the container's rows of ps output
+func TestFilterPS(t *testing.T) {
+	ps := `UID          PID    PPID  C STIME TTY          TIME CMD
+root           1       0  0 10:00 ?        00:00:01 /sbin/init
+root        2419    2400  0 10:05 ?        00:00:00 nginx: master process
+www-data    2450    2419  0 10:05 ?        00:00:00 nginx: worker process
+`
+	out, err := top.FilterPS([]byte(ps), []int{2419, 2450})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "UID") || !strings.Contains(lines[2], "worker") {
+		t.Errorf("filtered output:\n%s", out)
+	}
+
+	if _, err := top.FilterPS([]byte("USER COMMAND\nroot init\n"), []int{1}); err == nil {
+		t.Error("expected an error without a PID column")
+	}
+	if got := top.FormatTime(90*time.Minute + 5*time.Second); got != "01:30:05" {
+		t.Errorf("FormatTime = %q", got)
+	}
+}
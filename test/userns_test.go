@@ -0,0 +1,253 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/arnab2001/boxy/internal/config"
+	"github.com/arnab2001/boxy/internal/userns"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// Test /etc/subuid parsing
+func TestSubIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "subuid")
+	data := "# comment\nalice:100000:65536\nboxy:200000:131072\n1001:400000:65536\nboxy:600000:65536\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := userns.SubIDs(path, &user.User{Username: "boxy", Uid: "999"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []userns.Range{{Start: 200000, Size: 131072}, {Start: 600000, Size: 65536}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("SubIDs = %+v, expected %+v", got, expected)
+	}
+
+	// numeric entries match the user's uid
+	got, err = userns.SubIDs(path, &user.User{Username: "bob", Uid: "1001"})
+	if err != nil || len(got) != 1 || got[0].Start != 400000 {
+		t.Errorf("SubIDs(uid 1001) = %+v, %v", got, err)
+	}
+
+	if _, err := userns.SubIDs(path, &user.User{Username: "nobody"}); err == nil {
+		t.Error("expected an error for a user without ranges")
+	}
+
+	bad := filepath.Join(t.TempDir(), "subgid")
+	os.WriteFile(bad, []byte("boxy:abc:65536\n"), 0644)
+	if _, err := userns.SubIDs(bad, &user.User{Username: "boxy"}); err == nil {
+		t.Error("expected an error for an invalid start")
+	}
+}
+
+// Test range allocation around blocks held by other containers
+func TestAllocate(t *testing.T) {
+	ranges := []userns.Range{{Start: 100000, Size: 131072}, {Start: 500000, Size: 65536}}
+	tests := []struct {
+		name     string
+		used     []userns.Range
+		expected uint32
+		wantErr  bool
+	}{
+		{"empty", nil, 100000, false},
+		{"first taken", []userns.Range{{Start: 100000, Size: 65536}}, 165536, false},
+		{"overlap", []userns.Range{{Start: 150000, Size: 10}}, 150010, false},
+		{"next range", []userns.Range{{Start: 100000, Size: 65536}, {Start: 165536, Size: 1}}, 500000, false},
+		{"full", []userns.Range{{Start: 100000, Size: 131072}, {Start: 500000, Size: 1}}, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := userns.Allocate(ranges, tt.used, 65536)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, expected error: %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (got.Start != tt.expected || got.Size != 65536) {
+			t.Errorf("%s: Allocate = %+v, expected start %d", tt.name, got, tt.expected)
+		}
+	}
+}
+
+// Test keep-id mappings and host id translation
+func TestKeepID(t *testing.T) {
+	r := userns.Range{Start: 100000, Size: 65536}
+	m, err := userns.Keep(r, r, 1000, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []specs.LinuxIDMapping{
+		{ContainerID: 0, HostID: 100000, Size: 1000},
+		{ContainerID: 1000, HostID: 1000, Size: 1},
+		{ContainerID: 1001, HostID: 101000, Size: 64535},
+	}
+	if !reflect.DeepEqual(m.UIDs, expected) {
+		t.Errorf("Keep uids = %+v, expected %+v", m.UIDs, expected)
+	}
+
+	for id, host := range map[uint32]uint32{0: 100000, 999: 100999, 1000: 1000, 1001: 101000, 65535: 165534} {
+		if got, ok := userns.ToHost(m.UIDs, id); !ok || got != host {
+			t.Errorf("ToHost(%d) = %d, %v; expected %d", id, got, ok, host)
+		}
+	}
+	if _, ok := userns.ToHost(m.UIDs, 65536); ok {
+		t.Error("ToHost(65536) should be unmapped")
+	}
+
+	if _, err := userns.Keep(r, r, 70000, 70000); err == nil {
+		t.Error("expected an error for a uid outside the range")
+	}
+}
+
+// Test the label encoding of mappings and mode defaults
+func TestMappingsLabel(t *testing.T) {
+	m := userns.Remap(userns.Range{Start: 100000, Size: 65536}, userns.Range{Start: 200000, Size: 65536})
+	s := userns.FormatMappings(m.UIDs)
+	if s != "0:100000:65536" {
+		t.Errorf("FormatMappings = %q", s)
+	}
+	back, err := userns.ParseMappings(s)
+	if err != nil || !reflect.DeepEqual(back, m.UIDs) {
+		t.Errorf("ParseMappings(%q) = %+v, %v", s, back, err)
+	}
+	if _, err := userns.ParseMappings("0:1"); err == nil {
+		t.Error("expected an error for a short mapping")
+	}
+
+	if _, err := userns.ParseMode("private"); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+	if got := userns.Effective("", config.UserNS{}); got != userns.Host {
+		t.Errorf("Effective without remap = %q", got)
+	}
+	if got := userns.Effective("", config.UserNS{Remap: "default"}); got != userns.Auto {
+		t.Errorf("Effective with remap = %q", got)
+	}
+	if got := userns.Effective(userns.Host, config.UserNS{Remap: "default"}); got != userns.Host {
+		t.Errorf("Effective(host) with remap = %q", got)
+	}
+}
+
+// Test that archives of remapped containers are owned by container ids
+func TestUnshiftTar(t *testing.T) {
+	m := userns.Remap(userns.Range{Start: 100000, Size: 65536}, userns.Range{Start: 200000, Size: 65536})
+
+	var in bytes.Buffer
+	tw := tar.NewWriter(&in)
+	entries := []struct {
+		name     string
+		uid, gid int
+	}{
+		{"etc/", 100000, 200000},      // root inside the container
+		{"home/app/", 101000, 201000}, // uid 1000
+		{".wh.gone", 0, 0},            // whiteouts are written as host root
+	}
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Typeflag: tar.TypeDir, Mode: 0755, Uid: e.uid, Gid: e.gid, Uname: "boxy"}
+		if e.name == ".wh.gone" {
+			hdr.Typeflag = tar.TypeReg
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	content := []byte("hello")
+	if err := tw.WriteHeader(&tar.Header{Name: "home/app/greeting", Mode: 0640, Size: int64(len(content)), Uid: 101000, Gid: 201000}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write(content)
+	tw.Close()
+
+	var out bytes.Buffer
+	if err := userns.UnshiftTar(&out, &in, m); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][2]int{"etc/": {0, 0}, "home/app/": {1000, 1000}, ".wh.gone": {0, 0}, "home/app/greeting": {1000, 1000}}
+	tr := tar.NewReader(&out)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := [2]int{hdr.Uid, hdr.Gid}; got != expected[hdr.Name] || hdr.Uname != "" {
+			t.Errorf("%s: owner %v %q; expected %v", hdr.Name, got, hdr.Uname, expected[hdr.Name])
+		}
+		delete(expected, hdr.Name)
+		if hdr.Name == "home/app/greeting" {
+			if data, _ := io.ReadAll(tr); string(data) != "hello" {
+				t.Errorf("content %q; expected hello", data)
+			}
+		}
+	}
+	if len(expected) > 0 {
+		t.Errorf("missing entries %v", expected)
+	}
+
+	// the shifted stream of a producer is copied the same way
+	var piped bytes.Buffer
+	err := userns.Unshifted(&piped, m, func(w io.Writer) error {
+		tw := tar.NewWriter(w)
+		tw.WriteHeader(&tar.Header{Name: "x", Typeflag: tar.TypeDir, Uid: 100005, Gid: 200005})
+		return tw.Close()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr, err := tar.NewReader(&piped).Next(); err != nil || hdr.Uid != 5 || hdr.Gid != 5 {
+		t.Errorf("Unshifted: got %+v, %v; expected owner 5:5", hdr, err)
+	}
+	if err := userns.Unshifted(io.Discard, m, func(io.Writer) error { return io.ErrUnexpectedEOF }); err == nil {
+		t.Error("expected the producer's error")
+	}
+
+	if got, err := userns.FromLabels("", ""); got != nil || err != nil {
+		t.Errorf("FromLabels without labels = %v, %v; expected nil", got, err)
+	}
+	got, err := userns.FromLabels(userns.FormatMappings(m.UIDs), userns.FormatMappings(m.GIDs))
+	if err != nil || !reflect.DeepEqual(got.UIDs, m.UIDs) || !reflect.DeepEqual(got.GIDs, m.GIDs) {
+		t.Errorf("FromLabels = %+v, %v; expected %+v", got, err, m)
+	}
+}
+
+// Test that id allocation is serialised between creates
+func TestAllocationLock(t *testing.T) {
+	t.Setenv("BOXY_RUN_DIR", t.TempDir())
+	unlock, err := userns.Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		unlock2, err := userns.Lock()
+		if err != nil {
+			t.Error(err)
+		} else {
+			unlock2()
+		}
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("second Lock did not wait for the first")
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+	unlock() // releasing twice is harmless
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("second Lock not acquired after release")
+	}
+}